@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/vovanwin/configgen/internal/generator"
+	"github.com/vovanwin/configgen/internal/textdiff"
+)
+
+// version is set at build time: go build -ldflags "-X main.version=v1.2.3"
+var version = ""
+
+// configgenVersion returns the version for --version and generated headers: the -ldflags
+// value, the module version of go install, or "dev". --check and writes ignore the version
+// line, so code generated by `go run` and by a built binary is the same (see generator.Equal)
+func configgenVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// inputsHash fingerprints input files (by path relative to the configs directory, so the hash
+// is the same on every machine) and extra settings that affect the generated code
+func inputsHash(configsDir string, files []string, extra string) (string, error) {
+	type input struct {
+		name string
+		path string
+	}
+	var inputs []input
+	seen := make(map[string]bool)
+	for _, path := range files {
+		name, err := filepath.Rel(configsDir, path)
+		if err != nil {
+			name = path
+		}
+		name = filepath.ToSlash(name)
+		if !seen[name] {
+			seen[name] = true
+			inputs = append(inputs, input{name, path})
+		}
+	}
+	sort.Slice(inputs, func(i, j int) bool { return inputs[i].name < inputs[j].name })
+
+	h := sha256.New()
+	for _, in := range inputs {
+		b, err := os.ReadFile(in.path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "file %s %d\n", in.name, len(b))
+		h.Write(b)
+	}
+	fmt.Fprintln(h, extra)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readGenerated reads the configgen_*.go files in dir; a missing dir means nothing was generated yet
+func readGenerated(dir string) ([]generator.File, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "configgen_*.go"))
+	if err != nil {
+		return nil, err
+	}
+	var files []generator.File
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, generator.File{Name: filepath.Base(path), Content: b})
+	}
+	return files, nil
+}
+
+// diffGenerated returns a unified diff per file that differs between the files in dir (old)
+// and the rendered ones. Generated files that would not be written anymore
+// (e.g. flags.toml was removed) are shown as deleted
+func diffGenerated(dir string, old, files []generator.File) []string {
+	existing := make(map[string][]byte, len(old))
+	for _, f := range old {
+		existing[f.Name] = f.Content
+	}
+
+	var diffs []string
+	rendered := make(map[string]bool, len(files))
+	for _, f := range files {
+		rendered[f.Name] = true
+		path := filepath.ToSlash(filepath.Join(dir, f.Name))
+		oldName := "a/" + path
+		content, ok := existing[f.Name]
+		if !ok {
+			oldName = "/dev/null"
+		} else if generator.Equal(content, f.Content) {
+			continue
+		}
+		if d := textdiff.Unified(oldName, "b/"+path, content, f.Content); d != "" {
+			diffs = append(diffs, d)
+		}
+	}
+	for _, f := range old {
+		if rendered[f.Name] || !bytes.HasPrefix(f.Content, generator.GeneratedMarker) {
+			continue
+		}
+		path := filepath.ToSlash(filepath.Join(dir, f.Name))
+		diffs = append(diffs, textdiff.Unified("a/"+path, "/dev/null", f.Content, nil))
+	}
+	return diffs
+}
+
+// ANSI colors for --diff on a terminal
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+)
+
+// useColor reports whether w is a terminal and NO_COLOR is not set
+func useColor(w *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	st, err := w.Stat()
+	return err == nil && st.Mode()&os.ModeCharDevice != 0
+}
+
+// colorizeDiff colors a unified diff: headers bold, hunks cyan, removals red, additions green
+func colorizeDiff(d string) string {
+	lines := strings.SplitAfter(d, "\n")
+	for i, l := range lines {
+		var c string
+		switch {
+		case strings.HasPrefix(l, "--- "), strings.HasPrefix(l, "+++ "):
+			c = colorBold
+		case strings.HasPrefix(l, "@@"):
+			c = colorCyan
+		case strings.HasPrefix(l, "-"):
+			c = colorRed
+		case strings.HasPrefix(l, "+"):
+			c = colorGreen
+		default:
+			continue
+		}
+		body := strings.TrimSuffix(l, "\n")
+		lines[i] = c + body + colorReset + l[len(body):]
+	}
+	return strings.Join(lines, "")
+}
+
+// writeSummary prints added, removed, renamed and retyped config fields and feature flags
+func writeSummary(w io.Writer, s generator.Summary) {
+	if s.Empty() {
+		fmt.Fprintln(w, "Summary: no field or flag changes")
+		return
+	}
+	fmt.Fprintln(w, "Summary:")
+	for _, group := range []struct {
+		title   string
+		changes []generator.SchemaChange
+	}{{"fields", s.Fields}, {"flags", s.Flags}} {
+		if len(group.changes) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "  %s:\n", group.title)
+		for _, c := range group.changes {
+			switch c.Kind {
+			case generator.ChangeAdded:
+				fmt.Fprintf(w, "    + %s (%s)\n", c.Key, c.Type)
+			case generator.ChangeRemoved:
+				fmt.Fprintf(w, "    - %s (%s)\n", c.Key, c.Type)
+			case generator.ChangeRenamed:
+				fmt.Fprintf(w, "    > %s -> %s (%s)\n", c.OldKey, c.Key, c.Type)
+			case generator.ChangeType:
+				fmt.Fprintf(w, "    ~ %s: %s -> %s\n", c.Key, c.OldType, c.Type)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vovanwin/configgen/internal/diag"
+)
+
+// testSettings returns the default settings for a configs directory and an output directory
+func testSettings(configs, output string) settings {
+	s := addGenFlags(flag.NewFlagSet("configgen", flag.ContinueOnError)).settings()
+	s.ConfigsDir, s.OutDir = configs, output
+	return s
+}
+
+func TestCheckDoesNotDependOnVersion(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"configs/config_dev.toml":  "[server]\nport = 8080\n",
+		"configs/config_prod.toml": "[server]\nport = 80\n",
+	})
+	set := testSettings(filepath.Join(dir, "configs"), filepath.Join(dir, "config"))
+
+	// Generated with `go run` (dev), checked by a binary built from a tagged module version
+	saved := version
+	t.Cleanup(func() { version = saved })
+	version = "dev"
+	var out bytes.Buffer
+	if diags, ok := runTarget(set, runOptions{}, &out); !ok {
+		t.Fatalf("generate failed: %v\n%s", diags, out.String())
+	}
+	b, err := os.ReadFile(filepath.Join(set.OutDir, "configgen_config.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "\n// configgen version: dev\n// configgen inputs: sha256:") {
+		t.Errorf("header should contain the version and the inputs hash:\n%s", b)
+	}
+	version = "v1.5.0-0.20261018120000-0123456789ab"
+	out.Reset()
+	diags, ok := runTarget(set, runOptions{Check: true}, &out)
+	if !ok || diags.Count(diag.SeverityError) > 0 {
+		t.Fatalf("--check with another configgen version reports stale code: %v\n%s", diags, out.String())
+	}
+}
+
+func TestEditRewritesOnlyAffectedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"configs/config_dev.toml": "[server]\nport = 8080\n",
+		"configs/flags.toml":      "[flags]\nnew_ui = { type = \"bool\", default = false }\n",
+	})
+	set := testSettings(filepath.Join(dir, "configs"), filepath.Join(dir, "config"))
+	var out bytes.Buffer
+	if diags, ok := runTarget(set, runOptions{}, &out); !ok {
+		t.Fatalf("generate failed: %v\n%s", diags, out.String())
+	}
+
+	// A flag default changes only the feature flag files
+	writeFiles(t, dir, map[string]string{"configs/flags.toml": "[flags]\nnew_ui = { type = \"bool\", default = true }\n"})
+	out.Reset()
+	if diags, ok := runTarget(set, runOptions{}, &out); !ok {
+		t.Fatalf("generate failed: %v\n%s", diags, out.String())
+	}
+	for _, name := range []string{"configgen_config.go", "configgen_loader.go"} {
+		if !strings.Contains(out.String(), name+" (unchanged)") {
+			t.Errorf("%s should not be rewritten:\n%s", name, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), "configgen_flags.go\n") {
+		t.Errorf("configgen_flags.go should be rewritten:\n%s", out.String())
+	}
+}
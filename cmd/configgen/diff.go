@@ -0,0 +1,272 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vovanwin/configgen/internal/model"
+	"github.com/vovanwin/configgen/internal/parser"
+)
+
+const diffUsage = `usage: configgen diff --base=<git-ref> [flags]
+
+Renders the effective config of every environment at <git-ref> and in the working tree
+and prints the changed keys per environment. Override layers are off by default
+(usually untracked local files); environment variables are simulated as in render.
+Only files under --configs are exported from git: includes outside it fail at the base revision.
+
+Example:
+  configgen diff --configs=./configs --base=origin/main --format=markdown
+
+Flags:
+`
+
+// envDiff changes of one environment between the base revision and the working tree
+type envDiff struct {
+	Env     string          `json:"env"`
+	Status  string          `json:"status,omitempty"` // "added" or "removed" if the environment exists on one side only
+	Changes []parser.Change `json:"changes"`
+}
+
+// runDiff implements `configgen diff --base=<ref>`
+func runDiff(fs *flag.FlagSet, args []string, out io.Writer) error {
+	rf := addResolveFlags(fs, false)
+	ef := addEnvFlags(fs)
+	base := fs.String("base", "", "git revision to compare the working tree with (required)")
+	format := fs.String("format", "text", "output format: text, json or markdown")
+	showSensitive := fs.Bool("show-sensitive", false, "print sensitive values instead of masking them")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), diffUsage)
+		fs.PrintDefaults()
+	}
+
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 || *base == "" {
+		fs.Usage()
+		return fmt.Errorf("--base is required and no positional arguments are accepted")
+	}
+	switch *format {
+	case "text", "json", "markdown":
+	default:
+		return fmt.Errorf("unknown format: %s (use text, json or markdown)", *format)
+	}
+
+	lookup, err := ef.lookup()
+	if err != nil {
+		return err
+	}
+
+	baseDir, err := os.MkdirTemp("", "configgen-diff-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(baseDir)
+	if err := exportGitDir(*rf.configsDir, *base, baseDir); err != nil {
+		return err
+	}
+
+	diffs, err := diffEnvironments(rf, baseDir, *rf.configsDir, lookup, !*showSensitive)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "json":
+		b, err := json.MarshalIndent(map[string]any{"base": *base, "environments": diffs}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s\n", b)
+	case "markdown":
+		writeDiffMarkdown(out, *base, diffs)
+	default:
+		writeDiffText(out, diffs)
+	}
+	return nil
+}
+
+// diffEnvironments resolves every environment found in either directory and compares them
+func diffEnvironments(rf *resolveFlags, baseDir, headDir string, lookup func(string) (string, bool), mask bool) ([]envDiff, error) {
+	type side struct {
+		name    string
+		opts    parser.ResolveOptions
+		targets map[string]bool
+	}
+	sides := []*side{{name: "base"}, {name: "working tree"}}
+	for i, dir := range []string{baseDir, headDir} {
+		opts, err := rf.optionsFor(dir)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", sides[i].name, err)
+		}
+		opts.LookupEnv = lookup
+		names, err := discoverTargetNames(dir, opts.Layers)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", sides[i].name, err)
+		}
+		sides[i].opts = opts
+		sides[i].targets = make(map[string]bool)
+		for _, n := range names {
+			sides[i].targets[n] = true
+		}
+	}
+
+	var all []string
+	for _, s := range sides {
+		for t := range s.targets {
+			all = append(all, t)
+		}
+	}
+	sort.Strings(all)
+
+	var diffs []envDiff
+	for i, target := range all {
+		if i > 0 && all[i-1] == target {
+			continue
+		}
+		values := make([]map[string]any, len(sides))
+		for j, s := range sides {
+			if !s.targets[target] {
+				continue
+			}
+			v, err := parser.Resolve(s.opts, target)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", s.name, target, err)
+			}
+			values[j] = v
+		}
+
+		d := envDiff{Env: target, Changes: parser.DiffValues(values[0], values[1])}
+		switch {
+		case values[0] == nil:
+			d.Status = "added"
+		case values[1] == nil:
+			d.Status = "removed"
+		}
+		if mask {
+			for k, c := range d.Changes {
+				if model.IsSensitive(sides[0].opts.Schema, c.Key) || model.IsSensitive(sides[1].opts.Schema, c.Key) {
+					if c.Old != nil {
+						d.Changes[k].Old = sensitiveMask
+					}
+					if c.New != nil {
+						d.Changes[k].New = sensitiveMask
+					}
+				}
+			}
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs, nil
+}
+
+// exportGitDir writes the tracked files of dir at revision ref into out using git archive
+func exportGitDir(dir, ref, out string) error {
+	cmd := exec.Command("git", "archive", "--format=tar", ref, ".")
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	data, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("git archive %s: %v: %s", ref, err, strings.TrimSpace(stderr.String()))
+	}
+
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("git archive %s: %w", ref, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		path := filepath.Join(out, filepath.FromSlash(hdr.Name))
+		if !strings.HasPrefix(path, filepath.Clean(out)+string(filepath.Separator)) {
+			return fmt.Errorf("git archive %s: unexpected path %s", ref, hdr.Name)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			return err
+		}
+	}
+}
+
+// writeDiffText prints changes per environment: ~ changed, + added, - removed
+func writeDiffText(out io.Writer, diffs []envDiff) {
+	for _, d := range diffs {
+		title := d.Env
+		if d.Status != "" {
+			title += " (environment " + d.Status + ")"
+		}
+		if len(d.Changes) == 0 {
+			fmt.Fprintf(out, "%s: no changes\n", title)
+			continue
+		}
+		fmt.Fprintf(out, "%s: %d changes\n", title, len(d.Changes))
+		for _, c := range d.Changes {
+			switch c.Kind {
+			case parser.ChangeAdded:
+				fmt.Fprintf(out, "  + %s = %s\n", c.Key, formatValue(c.New))
+			case parser.ChangeRemoved:
+				fmt.Fprintf(out, "  - %s = %s\n", c.Key, formatValue(c.Old))
+			default:
+				fmt.Fprintf(out, "  ~ %s: %s -> %s\n", c.Key, formatValue(c.Old), formatValue(c.New))
+			}
+		}
+	}
+}
+
+// writeDiffMarkdown prints changes as markdown tables for a PR comment
+func writeDiffMarkdown(out io.Writer, base string, diffs []envDiff) {
+	fmt.Fprintf(out, "### Effective config changes (base: `%s`)\n\n", base)
+	var unchanged []string
+	for _, d := range diffs {
+		if len(d.Changes) == 0 {
+			unchanged = append(unchanged, "`"+d.Env+"`")
+			continue
+		}
+		title := d.Env
+		if d.Status != "" {
+			title += " (environment " + d.Status + ")"
+		}
+		fmt.Fprintf(out, "**%s**\n\n", title)
+		fmt.Fprintln(out, "| | Key | Base | Working tree |")
+		fmt.Fprintln(out, "|---|---|---|---|")
+		for _, c := range d.Changes {
+			sign := map[parser.ChangeKind]string{parser.ChangeAdded: "+", parser.ChangeRemoved: "-", parser.ChangeChanged: "~"}[c.Kind]
+			fmt.Fprintf(out, "| %s | `%s` | %s | %s |\n", sign, c.Key, markdownValue(c.Old, c.Kind != parser.ChangeAdded), markdownValue(c.New, c.Kind != parser.ChangeRemoved))
+		}
+		fmt.Fprintln(out)
+	}
+	if len(unchanged) > 0 {
+		fmt.Fprintf(out, "No changes: %s\n", strings.Join(unchanged, ", "))
+	}
+}
+
+// markdownValue formats a value for a table cell; absent values are left empty
+func markdownValue(v any, present bool) string {
+	if !present {
+		return ""
+	}
+	return "`" + strings.ReplaceAll(formatValue(v), "|", `\|`) + "`"
+}
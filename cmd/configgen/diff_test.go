@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/vovanwin/configgen/internal/parser"
+)
+
+// writeFiles creates files relative to dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// gitRepo creates a git repository in a temp directory with one commit of files
+func gitRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "base"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return dir
+}
+
+func TestExportGitDir(t *testing.T) {
+	repo := gitRepo(t, map[string]string{
+		"configs/config_dev.toml":  "[db]\nhost = \"localhost\"\npassword = \"dev-secret\"\n",
+		"configs/config_prod.toml": "[db]\nhost = \"db.prod\"\npassword = \"prod-secret\"\n",
+		"configs/sub/common.toml":  "[log]\nlevel = \"info\"\n",
+		"README.md":                "outside of configs\n",
+	})
+	configs := filepath.Join(repo, "configs")
+
+	// The working tree changes after the commit: exported files must come from HEAD
+	writeFiles(t, configs, map[string]string{
+		"config_prod.toml": "[db]\nhost = \"db2.prod\"\npassword = \"new-secret\"\nport = 5432\n",
+		"config_stg.toml":  "[db]\nhost = \"db.stg\"\npassword = \"stg-secret\"\n",
+	})
+	if err := os.Remove(filepath.Join(configs, "config_dev.toml")); err != nil {
+		t.Fatal(err)
+	}
+
+	base := t.TempDir()
+	if err := exportGitDir(configs, "HEAD", base); err != nil {
+		t.Fatalf("exportGitDir: %v", err)
+	}
+	for name, want := range map[string]string{
+		"config_dev.toml":  "[db]\nhost = \"localhost\"\npassword = \"dev-secret\"\n",
+		"config_prod.toml": "[db]\nhost = \"db.prod\"\npassword = \"prod-secret\"\n",
+		"sub/common.toml":  "[log]\nlevel = \"info\"\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(base, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("%s not exported: %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	for _, name := range []string{"config_stg.toml", "README.md", "configs"} {
+		if _, err := os.Stat(filepath.Join(base, name)); err == nil {
+			t.Errorf("%s should not be exported", name)
+		}
+	}
+
+	if err := exportGitDir(configs, "no-such-ref", t.TempDir()); err == nil {
+		t.Error("expected an error for an unknown revision")
+	}
+
+	// Comparing the revisions: changed, added and removed environments, masked secrets
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	rf := addResolveFlags(fs, false)
+	if _, err := parseArgs(fs, []string{"--configs=" + configs, "--mode=union", "--project=none"}); err != nil {
+		t.Fatal(err)
+	}
+	lookup := func(string) (string, bool) { return "", false }
+	diffs, err := diffEnvironments(rf, base, configs, lookup, true)
+	if err != nil {
+		t.Fatalf("diffEnvironments: %v", err)
+	}
+	got := make(map[string]envDiff)
+	for _, d := range diffs {
+		got[d.Env] = d
+	}
+	if len(got) != 3 {
+		t.Fatalf("want dev, prod and stg, got %+v", diffs)
+	}
+	if got["dev"].Status != "removed" || got["stg"].Status != "added" || got["prod"].Status != "" {
+		t.Errorf("statuses: dev=%q stg=%q prod=%q", got["dev"].Status, got["stg"].Status, got["prod"].Status)
+	}
+	changes := make(map[string]parser.Change)
+	for _, c := range got["prod"].Changes {
+		changes[c.Key] = c
+	}
+	if c := changes["db.host"]; c.Kind != parser.ChangeChanged || c.Old != "db.prod" || c.New != "db2.prod" {
+		t.Errorf("db.host change = %+v", c)
+	}
+	if c := changes["db.password"]; c.Old != sensitiveMask || c.New != sensitiveMask {
+		t.Errorf("db.password should be masked, got %+v", c)
+	}
+	if c := changes["db.port"]; c.Kind != parser.ChangeAdded {
+		t.Errorf("db.port change = %+v", c)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vovanwin/configgen/internal/model"
+	"github.com/vovanwin/configgen/internal/parser"
+)
+
+const diffEnvUsage = `usage: configgen diff-env [flags] <env> <env> [<env>...]
+
+Merges the runtime layers of every <env> (or env.region) like render and prints
+the values side by side. Keys that differ are marked with ~; by default only they are shown.
+Sensitive values are masked, but still compared on their real values.
+
+Example:
+  configgen diff-env --configs=./configs prod stg
+  configgen diff-env --format=markdown --all prod stg prod.eu
+
+Flags:
+`
+
+// envMatrix values of several environments by key
+type envMatrix struct {
+	Envs []string
+	Rows []matrixRow
+}
+
+// matrixRow one key of the matrix; Values[i] is nil if the key is not set in Envs[i]
+type matrixRow struct {
+	Key    string
+	Values []any
+	Set    []bool
+	Differ bool
+}
+
+// runDiffEnv implements `configgen diff-env <env> <env>...`
+func runDiffEnv(fs *flag.FlagSet, args []string, out io.Writer) error {
+	rf := addResolveFlags(fs, false)
+	ef := addEnvFlags(fs)
+	format := fs.String("format", "text", "output format: text, markdown or html")
+	all := fs.Bool("all", false, "also print keys with the same value in every environment")
+	showSensitive := fs.Bool("show-sensitive", false, "print sensitive values instead of masking them")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), diffEnvUsage)
+		fs.PrintDefaults()
+	}
+
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) < 2 {
+		fs.Usage()
+		return fmt.Errorf("expected at least two environments, got %d", len(pos))
+	}
+	switch *format {
+	case "text", "markdown", "html":
+	default:
+		return fmt.Errorf("unknown format: %s (use text, markdown or html)", *format)
+	}
+
+	opts, err := rf.options()
+	if err != nil {
+		return err
+	}
+	if opts.LookupEnv, err = ef.lookup(); err != nil {
+		return err
+	}
+
+	values := make([]map[string]any, len(pos))
+	for i, target := range pos {
+		if values[i], err = parser.Resolve(opts, target); err != nil {
+			return fmt.Errorf("%s: %w", target, err)
+		}
+	}
+	m := buildMatrix(pos, values, *all)
+	if !*showSensitive {
+		for _, row := range m.Rows {
+			if model.IsSensitive(opts.Schema, row.Key) {
+				for i := range row.Values {
+					if row.Set[i] {
+						row.Values[i] = sensitiveMask
+					}
+				}
+			}
+		}
+	}
+
+	switch *format {
+	case "markdown":
+		writeMatrixMarkdown(out, m)
+	case "html":
+		writeMatrixHTML(out, m)
+	default:
+		writeMatrixText(out, m)
+	}
+	return nil
+}
+
+// buildMatrix puts the values of all environments side by side, rows sorted by key.
+// A key differs if it is unset in some environment or its values are not equal
+func buildMatrix(envs []string, values []map[string]any, all bool) envMatrix {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, v := range values {
+		for k := range v {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	m := envMatrix{Envs: envs}
+	for _, k := range keys {
+		row := matrixRow{Key: k, Values: make([]any, len(envs)), Set: make([]bool, len(envs))}
+		for i, v := range values {
+			row.Values[i], row.Set[i] = v[k]
+			if i > 0 && (row.Set[i] != row.Set[0] || !reflect.DeepEqual(row.Values[i], row.Values[0])) {
+				row.Differ = true
+			}
+		}
+		if row.Differ || all {
+			m.Rows = append(m.Rows, row)
+		}
+	}
+	return m
+}
+
+// cell formats the value of environment i, unset values are shown as "-"
+func (r matrixRow) cell(i int) string {
+	if !r.Set[i] {
+		return "-"
+	}
+	return formatValue(r.Values[i])
+}
+
+// writeMatrixText prints the matrix as aligned columns, differing keys are marked with ~
+func writeMatrixText(out io.Writer, m envMatrix) {
+	if len(m.Rows) == 0 {
+		fmt.Fprintf(out, "no differences between %s\n", strings.Join(m.Envs, ", "))
+		return
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "  key\t%s\n", strings.Join(m.Envs, "\t"))
+	for _, row := range m.Rows {
+		mark := " "
+		if row.Differ {
+			mark = "~"
+		}
+		cells := make([]string, len(m.Envs))
+		for i := range m.Envs {
+			cells[i] = row.cell(i)
+		}
+		fmt.Fprintf(w, "%s %s\t%s\n", mark, row.Key, strings.Join(cells, "\t"))
+	}
+	w.Flush()
+}
+
+// writeMatrixMarkdown prints the matrix as a markdown table, differing values are bold
+func writeMatrixMarkdown(out io.Writer, m envMatrix) {
+	if len(m.Rows) == 0 {
+		fmt.Fprintf(out, "No differences between %s\n", strings.Join(m.Envs, ", "))
+		return
+	}
+	fmt.Fprintf(out, "| Key | %s |\n", strings.Join(m.Envs, " | "))
+	fmt.Fprintf(out, "|---%s|\n", strings.Repeat("|---", len(m.Envs)))
+	for _, row := range m.Rows {
+		cells := make([]string, len(m.Envs))
+		for i := range m.Envs {
+			cells[i] = "—"
+			if row.Set[i] {
+				cells[i] = markdownValue(row.Values[i], true)
+			}
+			if row.Differ {
+				cells[i] = "**" + cells[i] + "**"
+			}
+		}
+		fmt.Fprintf(out, "| `%s` | %s |\n", row.Key, strings.Join(cells, " | "))
+	}
+}
+
+// writeMatrixHTML prints the matrix as a standalone HTML table, differing rows are highlighted
+func writeMatrixHTML(out io.Writer, m envMatrix) {
+	fmt.Fprintln(out, `<style>.configgen-diff-env tr.differ td { background: #fff5b1; } .configgen-diff-env td.unset { color: #999; }</style>`)
+	fmt.Fprintln(out, `<table class="configgen-diff-env">`)
+	fmt.Fprint(out, "<tr><th>Key</th>")
+	for _, env := range m.Envs {
+		fmt.Fprintf(out, "<th>%s</th>", html.EscapeString(env))
+	}
+	fmt.Fprintln(out, "</tr>")
+	for _, row := range m.Rows {
+		if row.Differ {
+			fmt.Fprint(out, `<tr class="differ">`)
+		} else {
+			fmt.Fprint(out, "<tr>")
+		}
+		fmt.Fprintf(out, "<td><code>%s</code></td>", html.EscapeString(row.Key))
+		for i := range m.Envs {
+			if !row.Set[i] {
+				fmt.Fprint(out, `<td class="unset">not set</td>`)
+				continue
+			}
+			fmt.Fprintf(out, "<td><code>%s</code></td>", html.EscapeString(row.cell(i)))
+		}
+		fmt.Fprintln(out, "</tr>")
+	}
+	fmt.Fprintln(out, "</table>")
+}
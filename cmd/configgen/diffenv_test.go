@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestRunDiffEnv(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config_dev.toml":  "[db]\nhost = \"localhost\"\nport = 5432\npassword = \"dev-secret\"\ndebug = true\n",
+		"config_prod.toml": "[db]\nhost = \"db.prod\"\nport = 5432\npassword = \"prod-secret\"\nreplica = \"db-ro.prod\"\n",
+		"config_stg.toml":  "[db]\nhost = \"db.prod\"\nport = 5432\npassword = \"prod-secret\"\nreplica = \"db-ro.prod\"\n",
+		// A developer's local override must not hide the real differences
+		"config_local.toml": "[db]\nhost = \"127.0.0.1\"\n",
+	})
+	common := []string{"--configs=" + dir, "--mode=union", "--project=none"}
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    string // Full output
+		wantErr string
+	}{
+		{
+			name: "changed, added and removed keys",
+			args: []string{"dev", "prod"},
+			want: `  key          dev          prod
+~ db.debug     true         -
+~ db.host      "localhost"  "db.prod"
+~ db.password  "******"     "******"
+~ db.replica   -            "db-ro.prod"
+`,
+		},
+		{
+			name: "override layers on request",
+			args: []string{"--override", "dev", "prod"},
+			want: `  key          dev       prod
+~ db.debug     true      -
+~ db.password  "******"  "******"
+~ db.replica   -         "db-ro.prod"
+`,
+		},
+		{
+			name: "show sensitive",
+			args: []string{"--show-sensitive", "dev", "prod"},
+			want: `  key          dev           prod
+~ db.debug     true          -
+~ db.host      "localhost"   "db.prod"
+~ db.password  "dev-secret"  "prod-secret"
+~ db.replica   -             "db-ro.prod"
+`,
+		},
+		{
+			name: "masked values are compared on real values",
+			args: []string{"prod", "stg"},
+			want: "no differences between prod, stg\n",
+		},
+		{
+			name: "all keys",
+			args: []string{"--all", "prod", "stg"},
+			want: `  key          prod          stg
+  db.host      "db.prod"     "db.prod"
+  db.password  "******"      "******"
+  db.port      5432          5432
+  db.replica   "db-ro.prod"  "db-ro.prod"
+`,
+		},
+		{
+			name: "markdown",
+			args: []string{"--format=markdown", "dev", "prod"},
+			want: "| Key | dev | prod |\n|---|---|---|\n" +
+				"| `db.debug` | **`true`** | **—** |\n" +
+				"| `db.host` | **`\"localhost\"`** | **`\"db.prod\"`** |\n" +
+				"| `db.password` | **`\"******\"`** | **`\"******\"`** |\n" +
+				"| `db.replica` | **—** | **`\"db-ro.prod\"`** |\n",
+		},
+		{name: "one environment", args: []string{"dev"}, wantErr: "expected at least two environments, got 1"},
+		{name: "unknown format", args: []string{"--format=pdf", "dev", "prod"}, wantErr: "unknown format: pdf"},
+		{name: "unknown environment", args: []string{"dev", "qa"}, wantErr: "qa:"},
+		{name: "bad --env", args: []string{"--env", "NOVALUE", "dev", "prod"}, wantErr: "NOVALUE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := runDiffEnv(flag.NewFlagSet("diff-env", flag.ContinueOnError), append(append([]string{}, common...), tt.args...), &out)
+			if tt.wantErr != "" {
+				// A returned error makes main exit with status 1
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("runDiffEnv: %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output:\n%s\nwant:\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRunDiffEnvHTML(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config_dev.toml":  "[app]\nname = \"<svc>\"\ntoken = \"dev-token\"\n",
+		"config_prod.toml": "[app]\nname = \"svc\"\ntoken = \"prod-token\"\n",
+	})
+	var out bytes.Buffer
+	if err := runDiffEnv(flag.NewFlagSet("diff-env", flag.ContinueOnError), []string{"--configs=" + dir, "--project=none", "--format=html", "dev", "prod"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	html := out.String()
+	for _, want := range []string{
+		`<tr class="differ"><td><code>app.name</code></td><td><code>&#34;&lt;svc&gt;&#34;</code></td>`,
+		`<td><code>&#34;******&#34;</code></td><td><code>&#34;******&#34;</code></td></tr>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("html should contain %s, got:\n%s", want, html)
+		}
+	}
+	if strings.Contains(html, "token&") || strings.Contains(html, "prod-token") {
+		t.Errorf("sensitive value leaked:\n%s", html)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/vovanwin/configgen/internal/diag"
+	"github.com/vovanwin/configgen/internal/model"
+	"github.com/vovanwin/configgen/internal/parser"
+)
+
+// driftDiagnostics reports keys that differ between environments as diagnostics and counts
+// new drift of severity failOn or higher. Drift listed in the baseline file is reported as info,
+// drift below the threshold at most as warning: an error in the report always fails the run
+func driftDiagnostics(schema *parser.Schema, failOn, baselinePath string) (diag.List, int, error) {
+	var threshold diag.Severity
+	if failOn != "none" {
+		var err error
+		if threshold, err = diag.ParseSeverity(failOn); err != nil {
+			return nil, 0, fmt.Errorf("--fail-on: %w", err)
+		}
+	}
+
+	accepted := map[string]bool{}
+	if baselinePath != "" {
+		var err error
+		if accepted, err = readDriftBaseline(baselinePath); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	paths := make(map[string]string, len(schema.Envs))
+	for i, env := range schema.Envs {
+		paths[env] = schema.EnvPaths[i]
+	}
+
+	var list diag.List
+	failed := 0
+	for _, d := range detectDrift(schema) {
+		out := diag.Diagnostic{
+			Code:     diag.CodeDrift + "-" + string(d.Kind),
+			Severity: d.Severity,
+			Key:      d.Key,
+			Message:  d.Message(),
+		}
+		// Point at the file to fix: the one lacking the key or the one that differs
+		switch {
+		case len(d.Envs) > 0:
+			out.File = paths[d.Envs[0]]
+		case len(d.Types) > 0:
+			out.File = paths[oddType(schema.Envs, d.Types)]
+		case len(schema.Envs) > 0:
+			out.File = paths[schema.Envs[0]]
+		}
+		if out.File != "" {
+			pos := keyPosition(out.File, d.Key)
+			if pos.File != "" {
+				out.File = pos.File
+			}
+			out.Line, out.Column = pos.Line, pos.Column
+		}
+		switch {
+		case accepted[d.ID()]:
+			out.Severity = diag.SeverityInfo
+			out.Message += " (baseline)"
+		case failOn != "none" && d.Severity >= threshold:
+			failed++
+		case d.Severity > diag.SeverityWarning:
+			out.Severity = diag.SeverityWarning
+		}
+		list = append(list, out)
+	}
+	return list, failed, nil
+}
+
+// oddType returns the first environment whose type of the key is the least common one
+func oddType(envs []string, types map[string]string) string {
+	count := make(map[string]int)
+	for _, typ := range types {
+		count[typ]++
+	}
+	odd := ""
+	for _, env := range envs {
+		typ, ok := types[env]
+		if ok && (odd == "" || count[typ] < count[types[odd]]) {
+			odd = env
+		}
+	}
+	return odd
+}
+
+// keyPosition returns the position of key in a config file, or of its nearest section that
+// is there (a missing key is pointed at its section); the zero Position if there is none
+func keyPosition(path, key string) diag.Position {
+	doc, err := parser.ReadDocument(path)
+	if err != nil {
+		return diag.Position{}
+	}
+	for k := key; k != ""; {
+		if pos, ok := doc.Lines[k]; ok {
+			return pos
+		}
+		i := strings.LastIndexByte(k, '.')
+		if i < 0 {
+			break
+		}
+		k = k[:i]
+	}
+	return diag.Position{}
+}
+
+// detectDrift finds drift between environments; keys from value.toml are shared by all
+// environments, overriding them is not drift
+func detectDrift(schema *parser.Schema) []parser.Drift {
+	var drift []parser.Drift
+	for _, d := range parser.DetectDrift(schema.Envs, schema.EnvTrees) {
+		if model.Lookup(schema.Base, d.Key) == nil {
+			drift = append(drift, d)
+		}
+	}
+	return drift
+}
+
+// readDriftBaseline reads accepted drift ids ("missing db.max_idle_time"), # starts a comment
+func readDriftBaseline(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]bool{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ids := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ids[line] = true
+	}
+	return ids, scanner.Err()
+}
+
+// writeDriftBaseline writes the ids of the current drift, sorted
+func writeDriftBaseline(path string, drift []parser.Drift) error {
+	ids := make([]string, len(drift))
+	for i, d := range drift {
+		ids[i] = d.ID()
+	}
+	sort.Strings(ids)
+
+	var b strings.Builder
+	b.WriteString("# configgen drift baseline: accepted differences between environments\n")
+	b.WriteString("# regenerate with: configgen --validate --drift-baseline=<this file> --update-drift-baseline\n")
+	for _, id := range ids {
+		b.WriteString(id + "\n")
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vovanwin/configgen/internal/diag"
+	"github.com/vovanwin/configgen/internal/model"
+	"github.com/vovanwin/configgen/internal/parser"
+)
+
+func TestDriftDiagnosticsFailOn(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config_dev.toml":  "[db]\nhost = \"localhost\"\nport = 5432\npool = 2\n",
+		"config_prod.toml": "[db]\nhost = \"db.prod\"\nport = 5432\npool = 10\n",
+		"config_stg.toml":  "[db]\nhost = \"db.stg\"\nport = \"5432\"\n",
+	})
+	schema, err := parser.BuildSchema(dir, model.DefaultLayers(false), parser.ModeIntersect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseline := filepath.Join(dir, "drift.baseline")
+	if err := os.WriteFile(baseline, []byte("type db.port\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Drift in the test configs: type db.port (error) and missing db.pool (warning)
+	tests := []struct {
+		failOn   string
+		baseline string
+		failed   int
+		want     map[string]diag.Severity
+	}{
+		{failOn: "error", failed: 1, want: map[string]diag.Severity{"db.port": diag.SeverityError, "db.pool": diag.SeverityWarning}},
+		{failOn: "warning", failed: 2, want: map[string]diag.Severity{"db.port": diag.SeverityError, "db.pool": diag.SeverityWarning}},
+		{failOn: "none", failed: 0, want: map[string]diag.Severity{"db.port": diag.SeverityWarning, "db.pool": diag.SeverityWarning}},
+		{failOn: "warning", baseline: baseline, failed: 1, want: map[string]diag.Severity{"db.port": diag.SeverityInfo, "db.pool": diag.SeverityWarning}},
+	}
+	for _, tt := range tests {
+		list, failed, err := driftDiagnostics(schema, tt.failOn, tt.baseline)
+		if err != nil {
+			t.Fatalf("--fail-on=%s: %v", tt.failOn, err)
+		}
+		if failed != tt.failed {
+			t.Errorf("--fail-on=%s baseline=%v: failed = %d, want %d", tt.failOn, tt.baseline != "", failed, tt.failed)
+		}
+		// An error in the report must mean a failed run
+		if failed == 0 && list.Count(diag.SeverityError) > 0 {
+			t.Errorf("--fail-on=%s: errors reported with exit status 0: %v", tt.failOn, list)
+		}
+		for _, d := range list {
+			if want, ok := tt.want[d.Key]; ok && d.Severity != want {
+				t.Errorf("--fail-on=%s baseline=%v: %s severity = %s, want %s", tt.failOn, tt.baseline != "", d.Key, d.Severity, want)
+			}
+		}
+	}
+
+	// Diagnostics point at the key, or at the section of a missing key
+	list, _, err := driftDiagnostics(schema, "error", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stg := filepath.Join(dir, "config_stg.toml")
+	for _, d := range list {
+		want := map[string]diag.Position{
+			"db.port": {File: stg, Line: 3, Column: 1},
+			"db.pool": {File: stg, Line: 1, Column: 1},
+		}[d.Key]
+		if d.Pos() != want {
+			t.Errorf("%s position = %s, want %s", d.Key, d.Pos(), want)
+		}
+	}
+
+	if _, _, err := driftDiagnostics(schema, "fatal", ""); err == nil {
+		t.Error("expected an error for an unknown --fail-on")
+	}
+}
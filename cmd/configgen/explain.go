@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vovanwin/configgen/internal/model"
+	"github.com/vovanwin/configgen/internal/parser"
+)
+
+const explainUsage = `usage: configgen explain [flags] <env> <key>
+
+Replays the runtime layer merge offline and shows where the value of <key> comes from.
+<env> is an environment name or env.region for a region overlay (prod.eu).
+Environment variables are simulated: only --env and --env-file values are used.
+Sensitive values (# sensitive, or names like password/token/secret) are masked.
+
+Example:
+  configgen explain --configs=./configs --with-env-override --env-var-prefix=APP_ stg db.host
+
+Flags:
+`
+
+// runExplain implements `configgen explain <env> <key>`
+func runExplain(fs *flag.FlagSet, args []string, out io.Writer) error {
+	rf := addResolveFlags(fs, true)
+	showSensitive := fs.Bool("show-sensitive", false, "print sensitive values instead of masking them")
+	ef := addEnvFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), explainUsage)
+		fs.PrintDefaults()
+	}
+
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected <env> and <key>, got %d arguments", len(pos))
+	}
+
+	opts, err := rf.options()
+	if err != nil {
+		return err
+	}
+	if opts.LookupEnv, err = ef.lookup(); err != nil {
+		return err
+	}
+	tr, err := parser.Explain(opts, pos[0], pos[1])
+	if err != nil {
+		return err
+	}
+	if !*showSensitive && model.IsSensitive(opts.Schema, tr.Key) {
+		maskTrace(tr)
+	}
+	printTrace(out, tr)
+	return nil
+}
+
+// maskTrace replaces the values of a sensitive key with sensitiveMask in every layer
+func maskTrace(tr *parser.Trace) {
+	for i := range tr.Steps {
+		if tr.Steps[i].Set {
+			tr.Steps[i].Value = sensitiveMask
+		}
+	}
+	if tr.Winner >= 0 {
+		tr.Raw, tr.Value = sensitiveMask, sensitiveMask
+	}
+}
+
+// printTrace prints the per-layer history of a key, marking the winning layer with *
+func printTrace(out io.Writer, tr *parser.Trace) {
+	fmt.Fprintf(out, "%s (%s) in %s\n", tr.Key, tr.Type, tr.Target)
+	if tr.Comment != "" {
+		fmt.Fprintf(out, "  %s\n", strings.Join(strings.Fields(tr.Comment), " "))
+	}
+	fmt.Fprintln(out)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for i, step := range tr.Steps {
+		mark := " "
+		if i == tr.Winner {
+			mark = "*"
+		}
+		source := step.Source
+		if step.Name != "" {
+			if source != "" {
+				source += " (" + step.Name + ")"
+			} else {
+				source = step.Name
+			}
+		}
+		var status string
+		switch {
+		case step.Skip != "":
+			status = "skipped: " + step.Skip
+		case step.Set:
+			status = formatValue(step.Value)
+		default:
+			status = "not set"
+		}
+		fmt.Fprintf(w, "%s %2d. %s\t%s\t%s\n", mark, i+1, step.Layer, source, status)
+	}
+	w.Flush()
+	fmt.Fprintln(out)
+
+	if tr.Winner < 0 {
+		fmt.Fprintln(out, "value: not set (zero value)")
+		return
+	}
+	if s, ok := tr.Raw.(string); ok && strings.Contains(s, "${") {
+		fmt.Fprintf(out, "raw:   %s\n", formatValue(tr.Raw))
+	}
+	fmt.Fprintf(out, "value: %s\n", formatValue(tr.Value))
+}
+
+// formatValue formats a config value the way it would be written in TOML
+func formatValue(v any) string {
+	switch val := v.(type) {
+	case string:
+		return strconv.Quote(val)
+	case time.Duration:
+		return strconv.Quote(val.String())
+	case []any:
+		items := make([]string, len(val))
+		for i, item := range val {
+			items[i] = formatValue(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	default:
+		return fmt.Sprint(val)
+	}
+}
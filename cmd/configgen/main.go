@@ -1,160 +1,324 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
+	"github.com/vovanwin/configgen/internal/diag"
 	"github.com/vovanwin/configgen/internal/generator"
-	"github.com/vovanwin/configgen/internal/model"
-	"github.com/vovanwin/configgen/internal/parser"
 )
 
 func main() {
-	configsDir := flag.String("configs", "./configs", "directory with config files")
-	outDir := flag.String("output", "./internal/config", "output directory for generated code")
-	pkgName := flag.String("package", "config", "package name for generated code")
-	envPrefix := flag.String("env-prefix", "APP_ENV", "env variable name for environment detection")
-	withLoader := flag.Bool("with-loader", true, "generate configgen_loader.go for runtime loading")
-	withFlags := flag.Bool("with-flags", true, "generate feature flags if flags.toml found")
-	withEnvOverride := flag.Bool("with-env-override", false, "enable env var override in loader")
-	envVarPrefix := flag.String("env-var-prefix", "", "prefix for env var override (e.g., APP_)")
-	mode := flag.String("mode", "intersect", "schema mode: intersect (common fields) or union (all fields)")
+	if len(os.Args) > 1 {
+		subcommands := map[string]func(*flag.FlagSet, []string, io.Writer) error{
+			"explain":  runExplain,
+			"render":   runRender,
+			"diff":     runDiff,
+			"diff-env": runDiffEnv,
+		}
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(runSubcommand(os.Args[1], run, os.Args[2:]))
+		}
+	}
+
+	gf := addGenFlags(flag.CommandLine)
 	initFlag := flag.Bool("init", false, "create initial config files in --configs directory")
 	validateFlag := flag.Bool("validate", false, "validate all TOML files without generating code")
+	watchFlag := flag.Bool("watch", false, "watch --configs and regenerate code when TOML files change")
+	checkFlag := flag.Bool("check", false, "fail with a unified diff if the files in --output are not up to date")
+	diffFlag := flag.Bool("diff", false, "dry run: print a diff and a summary of field and flag changes without writing files")
+	failOn := flag.String("fail-on", "error", "with --validate: fail on new drift of this severity or higher: info, warning, error or none")
+	updateBaseline := flag.Bool("update-drift-baseline", false, "with --validate: write the current drift to --drift-baseline")
+	format := flag.String("format", "text", "diagnostics format: "+strings.Join(diag.Formats, ", "))
+	targetNames := flag.String("target", "", "with [[targets]] in the project config: comma-separated targets to run (default: all)")
+	versionFlag := flag.Bool("version", false, "print the configgen version and exit")
+	addProjectFlag(flag.CommandLine)
 
 	flag.Parse()
 
-	if *initFlag {
-		fmt.Println("Initializing config files...")
-		if err := generator.Init(*configsDir); err != nil {
-			log.Fatalf("init: %v", err)
-		}
-		fmt.Println("Done! Edit the files and run configgen without --init to generate code.")
+	if *versionFlag {
+		fmt.Println("configgen " + configgenVersion())
 		return
 	}
 
-	// Parse value.toml (constants) separately
-	var valueFields map[string]*model.Field
-	valuePath := filepath.Join(*configsDir, "value.toml")
-	if _, err := os.Stat(valuePath); err == nil {
-		valueFields, err = parser.ParseFile(valuePath)
-		if err != nil {
-			log.Fatalf("parse value.toml: %v", err)
-		}
-		fmt.Printf("parsed: value.toml (%d top-level fields)\n", len(valueFields))
+	if !slices.Contains(diag.Formats, *format) {
+		log.Fatalf("unknown format: %s (use %s)", *format, strings.Join(diag.Formats, ", "))
+	}
+	// With a machine-readable --format stdout carries only the diagnostics
+	out := os.Stdout
+	if *format != "text" {
+		out = os.Stderr
 	}
 
-	// Find config_*.toml files (environment configs)
-	envConfigs, err := filepath.Glob(filepath.Join(*configsDir, "config_*.toml"))
-	if err != nil {
-		log.Fatalf("glob: %v", err)
+	// Errors and warnings of all files are collected and printed together in --format
+	var diags diag.List
+	fail := func(err error) {
+		diags.Add(err)
+		writeDiagnostics(*format, diags)
+		os.Exit(1)
 	}
 
-	configFiles := envConfigs
+	project, cfg, err := loadProject(flag.CommandLine)
+	if err != nil {
+		fail(err)
+	}
+	if project != "" {
+		fmt.Fprintf(out, "project: %s\n", project)
+	}
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
 
-	if len(configFiles) == 0 && valueFields == nil {
-		log.Fatalf("need at least value.toml or config_*.toml files in %s", *configsDir)
+	ro := runOptions{
+		Validate:       *validateFlag,
+		Check:          *checkFlag,
+		Diff:           *diffFlag,
+		FailOn:         *failOn,
+		UpdateBaseline: *updateBaseline,
+		Color:          *diffFlag && out == os.Stdout && useColor(os.Stdout),
 	}
 
-	// Parse environment configs
-	var envAsts []map[string]*model.Field
-	for _, f := range configFiles {
-		m, err := parser.ParseFile(f)
+	// A project with targets runs all of them, unless --configs picks a single directory
+	if cfg != nil && len(cfg.Targets) > 0 && !explicit["configs"] {
+		if *initFlag || *watchFlag {
+			fail(fmt.Errorf("--init and --watch work with a single configs directory: pass --configs"))
+		}
+		targets, err := projectTargets(flag.CommandLine, project, cfg, *targetNames)
 		if err != nil {
-			log.Fatalf("parse %s: %v", f, err)
+			fail(err)
+		}
+		if *updateBaseline && explicit["drift-baseline"] && len(targets) > 1 {
+			fail(fmt.Errorf("--update-drift-baseline with several targets needs drift_baseline per target in %s, not --drift-baseline", project))
+		}
+		targetDiags, ok := runTargets(targets, ro, out)
+		diags = append(diags, targetDiags...)
+		writeDiagnostics(*format, diags)
+		if !ok {
+			os.Exit(1)
 		}
-		envAsts = append(envAsts, m)
-		fmt.Printf("parsed: %s (%d top-level fields)\n", filepath.Base(f), len(m))
+		return
+	}
+	if *targetNames != "" {
+		fail(fmt.Errorf("--target requires [[targets]] in the project config"))
+	}
+	// Flags not given on the command line come from .configgen.toml
+	if cfg != nil {
+		if err := applyValues(flag.CommandLine, project, cfg.flagValues(relDir(filepath.Dir(project)))); err != nil {
+			fail(err)
+		}
+	}
+	set := gf.settings()
+
+	if *initFlag {
+		fmt.Fprintln(out, "Initializing config files...")
+		if err := generator.Init(set.ConfigsDir); err != nil {
+			fail(fmt.Errorf("init: %w", err))
+		}
+		fmt.Fprintln(out, "Done! Edit the files and run configgen without --init to generate code.")
+		return
 	}
 
-	// Build schema for environment configs
-	var envSchema map[string]*model.Field
-	if len(envAsts) > 0 {
-		switch *mode {
-		case "intersect":
-			envSchema = parser.Intersect(envAsts...)
-		case "union":
-			envSchema = parser.Union(envAsts...)
-		default:
-			log.Fatalf("unknown mode: %s (use 'intersect' or 'union')", *mode)
+	if *watchFlag {
+		if *validateFlag || *checkFlag || *diffFlag {
+			fail(fmt.Errorf("--watch cannot be combined with --validate, --check or --diff"))
+		}
+		if err := set.check(); err != nil {
+			fail(err)
 		}
+		if err := runWatch(set, out); err != nil {
+			fail(err)
+		}
+		return
 	}
 
-	// Merge value.toml fields with environment config fields
-	var s map[string]*model.Field
-	if valueFields != nil && envSchema != nil {
-		s = parser.Union(valueFields, envSchema)
-	} else if valueFields != nil {
-		s = valueFields
-	} else {
-		s = envSchema
+	targetDiags, ok := runTarget(set, ro, out)
+	diags = append(diags, targetDiags...)
+	writeDiagnostics(*format, diags)
+	if !ok {
+		os.Exit(1)
 	}
+}
 
-	if len(s) == 0 {
-		log.Fatalf("empty schema — no fields found")
+// runSubcommand runs a subcommand and returns the exit status. Stdout carries the output
+// of the subcommand, so errors are printed to stderr in --diag-format
+func runSubcommand(name string, run func(*flag.FlagSet, []string, io.Writer) error, args []string) int {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	format := addDiagFormatFlag(fs)
+	err := run(fs, args, os.Stdout)
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	var diags diag.List
+	diags.Add(err)
+	if werr := diag.Write(os.Stderr, *format, diags); werr != nil {
+		log.Fatalf("write diagnostics: %v", werr)
 	}
+	return 1
+}
 
-	// Parse flags.toml if present
-	var flagDefs []*model.FlagDef
-	flagsPath := filepath.Join(*configsDir, "flags.toml")
-	if _, err := os.Stat(flagsPath); err == nil {
-		flagDefs, err = parser.ParseFlagsFile(flagsPath)
-		if err != nil {
-			log.Fatalf("parse flags.toml: %v", err)
+// addDiagFormatFlag adds --diag-format to a subcommand: its own --format selects the output
+func addDiagFormatFlag(fs *flag.FlagSet) *string {
+	format := "text"
+	fs.Func("diag-format", "errors format: "+strings.Join(diag.Formats, ", ")+" (default text)", func(s string) error {
+		if !slices.Contains(diag.Formats, s) {
+			return fmt.Errorf("unknown format: %s (use %s)", s, strings.Join(diag.Formats, ", "))
 		}
-		fmt.Printf("parsed: flags.toml (%d flags)\n", len(flagDefs))
+		format = s
+		return nil
+	})
+	return &format
+}
+
+// runOptions are the modes of a run, the same for every target
+type runOptions struct {
+	Validate       bool
+	Check          bool
+	Diff           bool
+	FailOn         string // --validate: drift severity to fail on
+	UpdateBaseline bool   // --validate: rewrite the drift baseline
+	Color          bool   // --diff: colorize the diff
+}
+
+// runTarget generates code for one configs directory (or validates, checks or diffs it),
+// writing its report to out. It returns the diagnostics and false if the run failed
+func runTarget(set settings, ro runOptions, out io.Writer) (diag.List, bool) {
+	gen, diags := prepare(set)
+	if gen == nil {
+		return diags, false
+	}
+	fail := func(err error) (diag.List, bool) {
+		diags.Add(err)
+		return diags, false
+	}
+	schema, flagDefs, opts := gen.Schema, gen.FlagDefs, gen.Options
+
+	for _, p := range schema.Parsed {
+		fmt.Fprintf(out, "parsed: %s (%d top-level fields)\n", p.Name, p.Fields)
+	}
+	if len(flagDefs) > 0 {
+		fmt.Fprintf(out, "parsed: flags.toml (%d flags)\n", len(flagDefs))
 	}
+	s := schema.Fields
 
-	// Validate mode: just check everything parses
-	if *validateFlag {
-		fmt.Println()
-		fmt.Println("Validation passed:")
-		fmt.Printf("  - config schema: %d top-level fields\n", len(s))
+	// Validate mode: check everything parses and report drift between environments
+	if ro.Validate {
+		if ro.UpdateBaseline {
+			if set.DriftBaseline == "" {
+				return fail(fmt.Errorf("--update-drift-baseline requires --drift-baseline"))
+			}
+			drift := detectDrift(schema)
+			if err := writeDriftBaseline(set.DriftBaseline, drift); err != nil {
+				return fail(err)
+			}
+			fmt.Fprintf(out, "drift baseline written: %s (%d issues)\n", set.DriftBaseline, len(drift))
+		}
+		drift, failed, err := driftDiagnostics(schema, ro.FailOn, set.DriftBaseline)
+		if err != nil {
+			return fail(err)
+		}
+		diags = append(diags, drift...)
+		if failed > 0 {
+			return fail(fmt.Errorf("%d new drift issue(s) at severity %s or higher (accept them with --update-drift-baseline)", failed, ro.FailOn))
+		}
+
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Validation passed:")
+		fmt.Fprintf(out, "  - config schema: %d top-level fields\n", len(s))
 		if len(flagDefs) > 0 {
-			fmt.Printf("  - flags: %d feature flags\n", len(flagDefs))
+			fmt.Fprintf(out, "  - flags: %d feature flags\n", len(flagDefs))
 		}
-		return
+		return diags, true
 	}
 
 	// Generate code
-	hasFlags := *withFlags && len(flagDefs) > 0
+	if err := set.check(); err != nil {
+		return fail(err)
+	}
+	files, err := generator.Render(opts, s)
+	if err != nil {
+		return fail(fmt.Errorf("generate: %w", err))
+	}
 
-	if *withEnvOverride && *envVarPrefix == "" {
-		log.Fatalf("--env-var-prefix is required when --with-env-override is enabled")
+	// Check and diff modes: compare the rendered files with the ones in --output
+	if ro.Check || ro.Diff {
+		old, err := readGenerated(set.OutDir)
+		if err != nil {
+			return fail(err)
+		}
+		diffs := diffGenerated(set.OutDir, old, files)
+		for _, d := range diffs {
+			if ro.Color {
+				d = colorizeDiff(d)
+			}
+			fmt.Fprint(out, d)
+		}
+		if ro.Diff {
+			fmt.Fprintln(out)
+			writeSummary(out, generator.Summarize(old, files))
+		}
+		if ro.Check && len(diffs) > 0 {
+			return fail(&diag.Error{Code: diag.CodeStale, File: set.OutDir, Err: fmt.Errorf("generated code is out of date: %d file(s) differ, run configgen to regenerate", len(diffs))})
+		}
+		fmt.Fprintln(out)
+		if len(diffs) == 0 {
+			fmt.Fprintf(out, "Generated code in %s is up to date\n", set.OutDir)
+		} else {
+			fmt.Fprintf(out, "%d file(s) in %s would change (dry run, nothing written)\n", len(diffs), set.OutDir)
+		}
+		return diags, true
 	}
 
-	opts := generator.Options{
-		OutputDir:       *outDir,
-		PackageName:     *pkgName,
-		EnvPrefix:       *envPrefix,
-		WithLoader:      *withLoader,
-		WithFlags:       hasFlags,
-		FlagDefs:        flagDefs,
-		WithEnvOverride: *withEnvOverride,
-		EnvVarPrefix:    *envVarPrefix,
+	changes, err := generator.Write(set.OutDir, files)
+	if err != nil {
+		return fail(fmt.Errorf("generate: %w", err))
 	}
 
-	if err := generator.Generate(opts, s); err != nil {
-		log.Fatalf("generate: %v", err)
+	unchanged := make(map[string]bool, len(changes.Unchanged))
+	for _, name := range changes.Unchanged {
+		unchanged[name] = true
 	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Generated files:")
+	for _, f := range files {
+		if unchanged[f.Name] {
+			fmt.Fprintf(out, "  - %s/%s (unchanged)\n", set.OutDir, f.Name)
+		} else {
+			fmt.Fprintf(out, "  - %s/%s\n", set.OutDir, f.Name)
+		}
+	}
+	if len(changes.Removed) > 0 {
+		fmt.Fprintln(out, "Removed stale files:")
+		for _, name := range changes.Removed {
+			fmt.Fprintf(out, "  - %s/%s\n", set.OutDir, name)
+		}
+	}
+	if set.WithLoader {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Config layers order (runtime):")
+		for i, l := range opts.Layers {
+			fmt.Fprintf(out, "  %d. %s\n", i+1, l.Describe())
+		}
+	}
+	return diags, true
+}
 
-	fmt.Println()
-	fmt.Println("Generated files:")
-	fmt.Printf("  - %s/configgen_config.go\n", *outDir)
-	if *withLoader {
-		fmt.Printf("  - %s/configgen_loader.go\n", *outDir)
+// writeDiagnostics prints diagnostics: text to stderr, machine-readable formats to stdout
+// (json and sarif are printed even when empty so CI always gets a report)
+func writeDiagnostics(format string, list diag.List) {
+	w := os.Stdout
+	if format == "text" {
+		if len(list) == 0 {
+			return
+		}
+		w = os.Stderr
 	}
-	if hasFlags {
-		fmt.Printf("  - %s/configgen_flags.go\n", *outDir)
-		fmt.Printf("  - %s/configgen_flagstore.go\n", *outDir)
-		fmt.Printf("  - %s/configgen_flags_test_helpers.go\n", *outDir)
+	if err := diag.Write(w, format, list); err != nil {
+		log.Fatalf("write diagnostics: %v", err)
 	}
-	fmt.Println()
-	fmt.Println("Config files order (runtime):")
-	fmt.Println("  1. value.toml        - base constants (optional)")
-	fmt.Println("  2. config_{env}.toml - environment-specific")
-	fmt.Println("  3. config_local.toml - local overrides (optional)")
 }
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/vovanwin/configgen/internal/diag"
+	"github.com/vovanwin/configgen/internal/model"
+)
+
+// projectFileName is the project config looked up from the working directory upward
+const projectFileName = ".configgen.toml"
+
+// projectConfig is .configgen.toml: defaults for the command-line flags, so that
+// a project runs plain `configgen`. Flags given on the command line override it
+type projectConfig struct {
+	projectSettings
+	Targets []projectTarget `toml:"targets"`
+}
+
+// projectTarget is one configs directory of a monorepo: its settings override the top-level ones
+type projectTarget struct {
+	Name string `toml:"name"` // Defaults to the configs directory
+	projectSettings
+}
+
+// projectSettings are the settings of the top level and of every target
+type projectSettings struct {
+	Configs       *string `toml:"configs"`
+	Output        *string `toml:"output"`
+	Package       *string `toml:"package"`
+	Mode          *string `toml:"mode"`
+	EnvPrefix     *string `toml:"env_prefix"`
+	RegionEnv     *string `toml:"region_env"`
+	EnvVarPrefix  *string `toml:"env_var_prefix"`
+	Layers        *string `toml:"layers"`
+	DriftBaseline *string `toml:"drift_baseline"`
+
+	Features struct {
+		Loader      *bool `toml:"loader"`
+		Flags       *bool `toml:"flags"`
+		EnvOverride *bool `toml:"env_override"`
+		PFlag       *bool `toml:"pflag"`
+	} `toml:"features"`
+
+	Annotations struct {
+		Sensitive []string `toml:"sensitive"` // Key patterns (db.dsn, *.token) treated as # sensitive
+	} `toml:"annotations"`
+}
+
+// findProjectFile returns the nearest .configgen.toml in dir or its parents, or ""
+func findProjectFile(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		p := filepath.Join(dir, projectFileName)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// readProjectFile decodes a project config; unknown settings are errors so that typos
+// do not silently fall back to defaults
+func readProjectFile(p string) (*projectConfig, error) {
+	var cfg projectConfig
+	md, err := toml.DecodeFile(p, &cfg)
+	if err != nil {
+		return nil, &diag.Error{Code: diag.CodeProject, File: p, Err: err}
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		var errs diag.List
+		for _, key := range undecoded {
+			errs.Add(&diag.Error{Code: diag.CodeProject, File: p, Key: key.String(), Err: fmt.Errorf("unknown setting")})
+		}
+		return nil, errs
+	}
+	names := make(map[string]bool)
+	for i, t := range cfg.Targets {
+		if t.Configs == nil {
+			return nil, &diag.Error{Code: diag.CodeProject, File: p, Key: fmt.Sprintf("targets[%d]", i), Err: fmt.Errorf("configs is required")}
+		}
+		name := t.name()
+		if names[name] {
+			return nil, &diag.Error{Code: diag.CodeProject, File: p, Key: fmt.Sprintf("targets[%d]", i), Err: fmt.Errorf("duplicate target %q", name)}
+		}
+		names[name] = true
+	}
+	for _, s := range append([]projectSettings{cfg.projectSettings}, targetSettings(cfg.Targets)...) {
+		for _, pattern := range s.Annotations.Sensitive {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, &diag.Error{Code: diag.CodeProject, File: p, Key: "annotations.sensitive", Err: fmt.Errorf("bad pattern %q: %w", pattern, err)}
+			}
+		}
+	}
+	return &cfg, nil
+}
+
+func targetSettings(targets []projectTarget) []projectSettings {
+	out := make([]projectSettings, len(targets))
+	for i, t := range targets {
+		out[i] = t.projectSettings
+	}
+	return out
+}
+
+// name is the target name shown in reports and selected with --target
+func (t projectTarget) name() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return filepath.ToSlash(filepath.Clean(*t.Configs))
+}
+
+// flagValues maps the settings to flag values; paths are relative to the project file
+func (c *projectSettings) flagValues(dir string) map[string][]string {
+	values := make(map[string][]string)
+	str := func(name string, v *string) {
+		if v != nil {
+			values[name] = []string{*v}
+		}
+	}
+	dirPath := func(name string, v *string) {
+		if v != nil {
+			p := *v
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(dir, p)
+			}
+			values[name] = []string{p}
+		}
+	}
+	boolean := func(name string, v *bool) {
+		if v != nil {
+			values[name] = []string{strconv.FormatBool(*v)}
+		}
+	}
+
+	dirPath("configs", c.Configs)
+	dirPath("output", c.Output)
+	str("package", c.Package)
+	str("mode", c.Mode)
+	str("env-prefix", c.EnvPrefix)
+	str("region-env", c.RegionEnv)
+	str("env-var-prefix", c.EnvVarPrefix)
+	str("layers", c.Layers)
+	dirPath("drift-baseline", c.DriftBaseline)
+	boolean("with-loader", c.Features.Loader)
+	boolean("with-flags", c.Features.Flags)
+	boolean("with-env-override", c.Features.EnvOverride)
+	boolean("with-pflag", c.Features.PFlag)
+	if len(c.Annotations.Sensitive) > 0 {
+		values["sensitive"] = c.Annotations.Sensitive
+	}
+	return values
+}
+
+// addProjectFlag adds --project to a flag set
+func addProjectFlag(fs *flag.FlagSet) *string {
+	return fs.String("project", "", "project config file (default: "+projectFileName+" found from the working directory upward, none to disable)")
+}
+
+// loadProject reads the project config: --project, or .configgen.toml found from
+// the working directory upward. The path is "" if there is none or --project=none
+func loadProject(fs *flag.FlagSet) (string, *projectConfig, error) {
+	p := ""
+	if f := fs.Lookup("project"); f != nil {
+		p = f.Value.String()
+	}
+	switch p {
+	case "none":
+		return "", nil, nil
+	case "":
+		var err error
+		if p, err = findProjectFile("."); err != nil || p == "" {
+			return "", nil, err
+		}
+	}
+	cfg, err := readProjectFile(p)
+	if err != nil {
+		return "", nil, err
+	}
+	return p, cfg, nil
+}
+
+// applyProject fills the flags of fs that were not set on the command line from
+// the top-level settings of the project config and returns its path
+func applyProject(fs *flag.FlagSet) (string, error) {
+	p, cfg, err := loadProject(fs)
+	if err != nil || cfg == nil {
+		return "", err
+	}
+	return p, applyValues(fs, p, cfg.flagValues(relDir(filepath.Dir(p))))
+}
+
+// applyValues sets the flags of fs that are not set yet; names unknown to fs are skipped
+// (subcommands have only some of the flags)
+func applyValues(fs *flag.FlagSet, project string, values map[string][]string) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if set[name] || fs.Lookup(name) == nil {
+			continue
+		}
+		for _, v := range values[name] {
+			if err := fs.Set(name, v); err != nil {
+				return &diag.Error{Code: diag.CodeProject, File: project, Err: fmt.Errorf("%s: %w", name, err)}
+			}
+		}
+	}
+	return nil
+}
+
+// relDir makes dir relative to the working directory when it is below it, for shorter output
+func relDir(dir string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return dir
+	}
+	rel, err := filepath.Rel(wd, dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return dir
+	}
+	return rel
+}
+
+// patternList collects repeated --sensitive flags
+type patternList []string
+
+func (p *patternList) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patternList) Set(s string) error {
+	if _, err := path.Match(s, ""); err != nil {
+		return fmt.Errorf("bad pattern %q: %w", s, err)
+	}
+	*p = append(*p, s)
+	return nil
+}
+
+// markSensitive marks fields whose key (db.password) matches one of the patterns as sensitive
+func markSensitive(fields map[string]*model.Field, patterns []string, prefix string) {
+	for name, f := range fields {
+		key := prefix + name
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, key); ok {
+				f.Sensitive = true
+				break
+			}
+		}
+		if f.Kind == model.KindObject {
+			markSensitive(f.Children, patterns, key+".")
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vovanwin/configgen/internal/diag"
+	"github.com/vovanwin/configgen/internal/model"
+)
+
+// chdir changes the working directory for the test
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestFindProjectFile(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		projectFileName:                      "package = \"root\"\n",
+		"services/api/internal/.keep":        "",
+		"services/worker/" + projectFileName: "package = \"worker\"\n",
+		"services/worker/cmd/.keep":          "",
+	})
+
+	tests := []struct {
+		dir  string
+		want string
+	}{
+		{dir: ".", want: projectFileName},
+		{dir: "services/api/internal", want: projectFileName},
+		{dir: "services/worker/cmd", want: "services/worker/" + projectFileName},
+	}
+	for _, tt := range tests {
+		got, err := findProjectFile(filepath.Join(root, tt.dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join(root, filepath.FromSlash(tt.want)); got != want {
+			t.Errorf("findProjectFile(%s) = %s, want %s", tt.dir, got, want)
+		}
+	}
+
+	// No project file up to the filesystem root
+	if got, err := findProjectFile(t.TempDir()); err != nil || got != "" {
+		t.Errorf("findProjectFile without a project file = %q, %v", got, err)
+	}
+}
+
+func TestProjectPrecedence(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		projectFileName: `configs = "./svc/configs"
+package = "svcconfig"
+env_var_prefix = "SVC_"
+
+[features]
+env_override = true
+`,
+	})
+	chdir(t, root)
+
+	tests := []struct {
+		name string
+		args []string
+		want func(settings) string // Empty if the settings are right
+	}{
+		{
+			name: "project over defaults",
+			want: func(s settings) string {
+				if s.ConfigsDir != filepath.Join("svc", "configs") || s.Package != "svcconfig" || s.EnvVarPrefix != "SVC_" || !s.WithEnvOverride {
+					return "project settings not applied"
+				}
+				if s.OutDir != "./internal/config" || s.Mode != "intersect" {
+					return "defaults lost"
+				}
+				return ""
+			},
+		},
+		{
+			name: "command line over project",
+			args: []string{"--package=cli", "--with-env-override=false", "--configs=other"},
+			want: func(s settings) string {
+				if s.Package != "cli" || s.WithEnvOverride || s.ConfigsDir != "other" {
+					return "command-line flags overridden by the project"
+				}
+				if s.EnvVarPrefix != "SVC_" {
+					return "project setting not given on the command line lost"
+				}
+				return ""
+			},
+		},
+		{
+			name: "project disabled",
+			args: []string{"--project=none"},
+			want: func(s settings) string {
+				if s.Package != "config" || s.ConfigsDir != "./configs" {
+					return "--project=none should use defaults"
+				}
+				return ""
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("configgen", flag.ContinueOnError)
+			gf := addGenFlags(fs)
+			addProjectFlag(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := applyProject(fs); err != nil {
+				t.Fatal(err)
+			}
+			s := gf.settings()
+			if msg := tt.want(s); msg != "" {
+				t.Errorf("%s: %+v", msg, s)
+			}
+		})
+	}
+}
+
+func TestReadProjectFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		content string
+		keys    []string // Keys of the expected diagnostics
+		message string
+	}{
+		{content: "packge = \"x\"\n[features]\nlodaer = true\n", keys: []string{"packge", "features.lodaer"}, message: "unknown setting"},
+		{content: "[[targets]]\nname = \"api\"\n", keys: []string{"targets[0]"}, message: "configs is required"},
+		{content: "[[targets]]\nconfigs = \"a\"\n[[targets]]\nconfigs = \"./a\"\n", keys: []string{"targets[1]"}, message: `duplicate target "a"`},
+		{content: "[annotations]\nsensitive = [\"db.[\"]\n", keys: []string{"annotations.sensitive"}, message: "bad pattern"},
+		{content: "package = \n", message: "expected value"},
+	}
+	for i, tt := range tests {
+		p := filepath.Join(dir, "project.toml")
+		if err := os.WriteFile(p, []byte(tt.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := readProjectFile(p)
+		list := diag.FromError(err)
+		if len(list) == 0 || len(tt.keys) > 0 && len(list) != len(tt.keys) {
+			t.Errorf("case %d: diagnostics = %v, want keys %v", i, list, tt.keys)
+			continue
+		}
+		for j, d := range list {
+			if d.Code != diag.CodeProject || d.File != p || !strings.Contains(d.Message, tt.message) {
+				t.Errorf("case %d: diagnostic %+v, want %s in %s", i, d, tt.message, p)
+			}
+			if len(tt.keys) > 0 && d.Key != tt.keys[j] {
+				t.Errorf("case %d: key = %q, want %q", i, d.Key, tt.keys[j])
+			}
+		}
+	}
+}
+
+func TestMarkSensitive(t *testing.T) {
+	fields := map[string]*model.Field{
+		"db": {Kind: model.KindObject, Children: map[string]*model.Field{
+			"dsn":  {Kind: model.KindString},
+			"host": {Kind: model.KindString},
+		}},
+		"stripe": {Kind: model.KindObject, Children: map[string]*model.Field{
+			"token": {Kind: model.KindString},
+			"url":   {Kind: model.KindString},
+		}},
+		"vault": {Kind: model.KindObject, Children: map[string]*model.Field{
+			"role": {Kind: model.KindString},
+		}},
+	}
+	markSensitive(fields, []string{"db.dsn", "*.token", "vault"}, "")
+
+	for key, want := range map[string]bool{
+		"db.dsn":       true,
+		"db.host":      false,
+		"stripe.token": true,
+		"stripe.url":   false,
+		"vault":        true,
+	} {
+		if got := model.Lookup(fields, key).Sensitive; got != want {
+			t.Errorf("%s sensitive = %v, want %v", key, got, want)
+		}
+	}
+	// A sensitive section masks its keys
+	if !model.IsSensitive(fields, "vault.role") {
+		t.Error("vault.role should be sensitive through its section")
+	}
+}
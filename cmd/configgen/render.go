@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/vovanwin/configgen/internal/model"
+	"github.com/vovanwin/configgen/internal/parser"
+)
+
+const renderUsage = `usage: configgen render [flags] <env>
+
+Merges the runtime layers offline and prints the effective config of <env> (or env.region).
+Environment variables are simulated: only --env and --env-file values are used.
+Sensitive values (# sensitive, or names like password/token/secret) are masked.
+
+Example:
+  configgen render --configs=./configs --format=json stg
+  DB_HOST=$(configgen render --key=db.host prod)
+
+Flags:
+`
+
+// sensitiveMask replaces sensitive values in render output
+const sensitiveMask = "******"
+
+// runRender implements `configgen render <env>`
+func runRender(fs *flag.FlagSet, args []string, out io.Writer) error {
+	rf := addResolveFlags(fs, true)
+	format := fs.String("format", "toml", "output format: toml, json or flat (key=value)")
+	key := fs.String("key", "", "print a single value (section.key) for shell scripts")
+	showSensitive := fs.Bool("show-sensitive", false, "print sensitive values instead of masking them")
+	ef := addEnvFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), renderUsage)
+		fs.PrintDefaults()
+	}
+
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected <env>, got %d arguments", len(pos))
+	}
+	switch *format {
+	case "toml", "json", "flat":
+	default:
+		return fmt.Errorf("unknown format: %s (use toml, json or flat)", *format)
+	}
+
+	opts, err := rf.options()
+	if err != nil {
+		return err
+	}
+	if opts.LookupEnv, err = ef.lookup(); err != nil {
+		return err
+	}
+
+	values, err := parser.Resolve(opts, pos[0])
+	if err != nil {
+		return err
+	}
+	if !*showSensitive {
+		maskSensitive(opts.Schema, values)
+	}
+
+	if *key != "" {
+		if f := model.Lookup(opts.Schema, *key); f == nil || f.Kind == model.KindObject {
+			return fmt.Errorf("key %s not found in schema", *key)
+		}
+		v, ok := values[*key]
+		if !ok {
+			return fmt.Errorf("key %s is not set in %s", *key, pos[0])
+		}
+		fmt.Fprintln(out, plainValue(v))
+		return nil
+	}
+	return writeValues(out, *format, values)
+}
+
+// writeValues prints flat values as TOML, JSON or sorted key=value lines
+func writeValues(out io.Writer, format string, values map[string]any) error {
+	switch format {
+	case "flat":
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(out, "%s=%s\n", k, plainValue(values[k]))
+		}
+		return nil
+
+	case "json":
+		b, err := json.MarshalIndent(nestValues(values), "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "%s\n", b)
+		return err
+
+	default:
+		var buf bytes.Buffer
+		enc := toml.NewEncoder(&buf)
+		enc.Indent = ""
+		if err := enc.Encode(nestValues(values)); err != nil {
+			return err
+		}
+		_, err := out.Write(buf.Bytes())
+		return err
+	}
+}
+
+// nestValues turns flat section.key values back into nested tables
+func nestValues(values map[string]any) map[string]any {
+	root := make(map[string]any)
+	for key, v := range values {
+		parts := strings.Split(key, ".")
+		m := root
+		for _, p := range parts[:len(parts)-1] {
+			child, ok := m[p].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				m[p] = child
+			}
+			m = child
+		}
+		m[parts[len(parts)-1]] = v
+	}
+	return root
+}
+
+// plainValue formats a value for shell use: strings as is, lists comma-separated
+func plainValue(v any) string {
+	if items, ok := v.([]any); ok {
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i] = plainValue(item)
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// maskSensitive replaces values of sensitive keys with sensitiveMask
+func maskSensitive(schema map[string]*model.Field, values map[string]any) {
+	for k := range values {
+		if model.IsSensitive(schema, k) {
+			values[k] = sensitiveMask
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/vovanwin/configgen/internal/model"
+	"github.com/vovanwin/configgen/internal/parser"
+)
+
+// runtimeLayers returns layers from --layers or the default order
+func runtimeLayers(spec string, withEnv bool) ([]model.Layer, error) {
+	if spec == "" {
+		return model.DefaultLayers(withEnv), nil
+	}
+	layers, err := parser.ParseLayers(spec)
+	if err != nil {
+		return nil, fmt.Errorf("layers: %w", err)
+	}
+	return layers, nil
+}
+
+// resolveFlags are the flags shared by explain and render: they must match the ones
+// used for generation so that the schema and layer order are the same
+type resolveFlags struct {
+	configsDir      *string
+	layersSpec      *string
+	mode            *string
+	withEnvOverride *bool
+	envVarPrefix    *string
+	override        *bool
+	sensitive       patternList
+}
+
+func addResolveFlags(fs *flag.FlagSet, override bool) *resolveFlags {
+	addProjectFlag(fs)
+	rf := &resolveFlags{
+		configsDir:      fs.String("configs", "./configs", "directory with config files"),
+		layersSpec:      fs.String("layers", "", "runtime layer order, same as for generation"),
+		mode:            fs.String("mode", "intersect", "schema mode: intersect or union, same as for generation"),
+		withEnvOverride: fs.Bool("with-env-override", false, "apply .env and environment variables like the generated loader"),
+		envVarPrefix:    fs.String("env-var-prefix", "", "prefix for env var override (e.g., APP_)"),
+		override:        fs.Bool("override", override, "apply override layers (LoadOptions.EnableOverride)"),
+	}
+	fs.Var(&rf.sensitive, "sensitive", "key `pattern` (db.dsn, *.token) treated as # sensitive (repeatable)")
+	return rf
+}
+
+// options builds the schema and returns options for parser.Resolve / parser.Explain
+func (rf *resolveFlags) options() (parser.ResolveOptions, error) {
+	return rf.optionsFor(*rf.configsDir)
+}
+
+// optionsFor is options for a copy of the configs directory (e.g. exported from git)
+func (rf *resolveFlags) optionsFor(dir string) (parser.ResolveOptions, error) {
+	prefix := ""
+	if *rf.withEnvOverride {
+		if *rf.envVarPrefix == "" {
+			return parser.ResolveOptions{}, fmt.Errorf("--env-var-prefix is required when --with-env-override is enabled")
+		}
+		prefix = *rf.envVarPrefix
+	}
+
+	layers, err := runtimeLayers(*rf.layersSpec, *rf.withEnvOverride)
+	if err != nil {
+		return parser.ResolveOptions{}, err
+	}
+	schema, err := parser.BuildSchema(dir, layers, *rf.mode)
+	if err != nil {
+		return parser.ResolveOptions{}, err
+	}
+	markSensitive(schema.Fields, rf.sensitive, "")
+	return parser.ResolveOptions{
+		Dir:            dir,
+		Layers:         layers,
+		Schema:         schema.Fields,
+		EnvVarPrefix:   prefix,
+		EnableOverride: *rf.override,
+	}, nil
+}
+
+// parseArgs parses flags that may go before, between or after the positional arguments;
+// flags not given are then taken from the project config
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			if _, err := applyProject(fs); err != nil {
+				return nil, err
+			}
+			return pos, nil
+		}
+		pos = append(pos, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// envFlags simulate environment variables for render and diff: the process
+// environment is never read so that output does not depend on the machine
+type envFlags struct {
+	file *string
+	vars envList
+}
+
+func addEnvFlags(fs *flag.FlagSet) *envFlags {
+	ef := &envFlags{vars: envList{}}
+	ef.file = fs.String("env-file", "", "dotenv file with simulated environment variables")
+	fs.Var(ef.vars, "env", "simulated environment variable `NAME=VALUE` (repeatable)")
+	return ef
+}
+
+// lookup returns the simulated environment: --env-file overridden by --env
+func (ef *envFlags) lookup() (func(string) (string, bool), error) {
+	vars := map[string]string{}
+	if *ef.file != "" {
+		var err error
+		if vars, err = parser.ReadDotEnv(*ef.file); err != nil {
+			return nil, err
+		}
+	}
+	for name, value := range ef.vars {
+		vars[name] = value
+	}
+	return func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}, nil
+}
+
+// envList collects repeated --env NAME=VALUE flags
+type envList map[string]string
+
+func (e envList) String() string {
+	return ""
+}
+
+func (e envList) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected NAME=VALUE, got %q", s)
+	}
+	e[name] = value
+	return nil
+}
+
+// discoverTargetNames returns environments and region overlays (prod, prod.eu) found in dir
+func discoverTargetNames(dir string, layers []model.Layer) ([]string, error) {
+	envFiles, err := parser.DiscoverEnvFiles(dir, layers)
+	if err != nil {
+		return nil, err
+	}
+	overlays, err := parser.DiscoverOverlays(dir, layers, envFiles)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range append(envFiles, overlays...) {
+		names = append(names, f.Name())
+	}
+	return names, nil
+}
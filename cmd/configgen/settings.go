@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/vovanwin/configgen/internal/diag"
+	"github.com/vovanwin/configgen/internal/generator"
+	"github.com/vovanwin/configgen/internal/model"
+	"github.com/vovanwin/configgen/internal/parser"
+)
+
+// settings are the generation options of one configs directory
+type settings struct {
+	ConfigsDir      string
+	OutDir          string
+	Package         string
+	EnvPrefix       string
+	RegionEnv       string
+	WithLoader      bool
+	WithFlags       bool
+	WithEnvOverride bool
+	EnvVarPrefix    string
+	WithPFlag       bool
+	Layers          string // --layers spec, empty for the default order
+	Mode            string
+	DriftBaseline   string // --validate: file with accepted drift
+}
+
+// genFlags are the flags behind settings; the project config can set them too
+type genFlags struct {
+	configsDir      *string
+	outDir          *string
+	pkgName         *string
+	envPrefix       *string
+	regionEnv       *string
+	withLoader      *bool
+	withFlags       *bool
+	withEnvOverride *bool
+	envVarPrefix    *string
+	withPFlag       *bool
+	layersSpec      *string
+	mode            *string
+	driftBaseline   *string
+}
+
+func addGenFlags(fs *flag.FlagSet) *genFlags {
+	return &genFlags{
+		configsDir:      fs.String("configs", "./configs", "directory with config files"),
+		outDir:          fs.String("output", "./internal/config", "output directory for generated code"),
+		pkgName:         fs.String("package", "config", "package name for generated code"),
+		envPrefix:       fs.String("env-prefix", "APP_ENV", "env variable name for environment detection"),
+		regionEnv:       fs.String("region-env", "APP_REGION", "env variable name for region overlay detection"),
+		withLoader:      fs.Bool("with-loader", true, "generate configgen_loader.go for runtime loading"),
+		withFlags:       fs.Bool("with-flags", true, "generate feature flags if flags.toml found"),
+		withEnvOverride: fs.Bool("with-env-override", false, "enable env var override in loader"),
+		envVarPrefix:    fs.String("env-var-prefix", "", "prefix for env var override (e.g., APP_)"),
+		withPFlag:       fs.Bool("with-pflag", false, "generate BindPFlags for github.com/spf13/pflag in configgen_pflag.go"),
+		layersSpec:      fs.String("layers", "", "runtime layer order, e.g. value.toml?,config_{env}.toml,+config_local.toml?,$env (default depends on --with-env-override)"),
+		mode:            fs.String("mode", "intersect", "schema mode: intersect (common fields) or union (all fields)"),
+		driftBaseline:   fs.String("drift-baseline", "", "with --validate: file with accepted drift, one issue per line"),
+	}
+}
+
+func (g *genFlags) settings() settings {
+	return settings{
+		ConfigsDir:      *g.configsDir,
+		OutDir:          *g.outDir,
+		Package:         *g.pkgName,
+		EnvPrefix:       *g.envPrefix,
+		RegionEnv:       *g.regionEnv,
+		WithLoader:      *g.withLoader,
+		WithFlags:       *g.withFlags,
+		WithEnvOverride: *g.withEnvOverride,
+		EnvVarPrefix:    *g.envVarPrefix,
+		WithPFlag:       *g.withPFlag,
+		Layers:          *g.layersSpec,
+		Mode:            *g.mode,
+		DriftBaseline:   *g.driftBaseline,
+	}
+}
+
+// check reports option combinations that cannot generate code
+func (s settings) check() error {
+	if s.WithEnvOverride && s.EnvVarPrefix == "" {
+		return fmt.Errorf("--env-var-prefix is required when --with-env-override is enabled")
+	}
+	return nil
+}
+
+// generation is a parsed configs directory ready to be rendered
+type generation struct {
+	Schema   *parser.Schema
+	FlagDefs []*model.FlagDef
+	Options  generator.Options
+}
+
+// prepare parses the configs directory and flags.toml and builds generator options.
+// Diagnostics of all files are returned together; the generation is nil if any of them is an error
+func prepare(s settings) (*generation, diag.List) {
+	var diags diag.List
+
+	// Runtime layers: the same list drives env discovery here and merge order in the loader
+	layers, err := runtimeLayers(s.Layers, s.WithEnvOverride)
+	if err != nil {
+		diags.Add(err)
+		return nil, diags
+	}
+
+	schema, err := parser.BuildSchema(s.ConfigsDir, layers, s.Mode)
+	diags.Add(err)
+
+	// Parse flags.toml if present
+	var flagDefs []*model.FlagDef
+	var flagInputs []string
+	flagsPath := filepath.Join(s.ConfigsDir, "flags.toml")
+	if _, err := os.Stat(flagsPath); err == nil {
+		flagDefs, err = parser.ParseFlagsFile(flagsPath)
+		diags.Add(err)
+		flagInputs = append(flagInputs, flagsPath)
+	}
+	if diags.Count(diag.SeverityError) > 0 {
+		return nil, diags
+	}
+
+	// Each generated file is hashed with its own inputs: flags.toml does not touch config and loader
+	schemaHash, err := inputsHash(s.ConfigsDir, schema.Inputs, "mode="+s.Mode)
+	if err != nil {
+		diags.Add(err)
+		return nil, diags
+	}
+	flagsHash, err := inputsHash(s.ConfigsDir, flagInputs, "")
+	if err != nil {
+		diags.Add(err)
+		return nil, diags
+	}
+	schema.Inputs = append(schema.Inputs, flagInputs...)
+
+	opts := generator.Options{
+		OutputDir:       s.OutDir,
+		PackageName:     s.Package,
+		EnvPrefix:       s.EnvPrefix,
+		RegionEnv:       s.RegionEnv,
+		WithLoader:      s.WithLoader,
+		WithFlags:       s.WithFlags && len(flagDefs) > 0,
+		FlagDefs:        flagDefs,
+		WithEnvOverride: s.WithEnvOverride,
+		EnvVarPrefix:    s.EnvVarPrefix,
+		WithPFlag:       s.WithLoader && s.WithPFlag,
+		Layers:          layers,
+		Version:         configgenVersion(),
+		SchemaHash:      schemaHash,
+		FlagsHash:       flagsHash,
+	}
+	return &generation{Schema: schema, FlagDefs: flagDefs, Options: opts}, diags
+}
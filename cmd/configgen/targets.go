@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vovanwin/configgen/internal/diag"
+)
+
+// target is one configs directory of a multi-target project
+type target struct {
+	Name     string
+	Settings settings
+}
+
+// projectTargets builds the settings of the [[targets]] of the project config, all of them or
+// the comma-separated selected ones. Flags given on the command line override the target
+// settings, which override the top-level ones
+func projectTargets(fs *flag.FlagSet, project string, cfg *projectConfig, selected string) ([]target, error) {
+	want := make(map[string]bool)
+	for _, name := range strings.Split(selected, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			want[name] = true
+		}
+	}
+
+	dir := relDir(filepath.Dir(project))
+	if len(want) == 0 {
+		want = targetsAt(dir, cfg.Targets)
+	}
+	top := cfg.flagValues(dir)
+	found := make(map[string]bool)
+	var targets []target
+	for _, pt := range cfg.Targets {
+		name := pt.name()
+		if len(want) > 0 && !want[name] {
+			continue
+		}
+		found[name] = true
+
+		// A fresh flag set per target: the command line first, then target and top-level values
+		tfs := flag.NewFlagSet(name, flag.ContinueOnError)
+		gf := addGenFlags(tfs)
+		var err error
+		fs.Visit(func(f *flag.Flag) {
+			if err == nil && tfs.Lookup(f.Name) != nil {
+				err = tfs.Set(f.Name, f.Value.String())
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		values := pt.flagValues(dir)
+		for flagName, v := range top {
+			if _, ok := values[flagName]; !ok {
+				values[flagName] = v
+			}
+		}
+		if err := applyValues(tfs, project, values); err != nil {
+			return nil, err
+		}
+		targets = append(targets, target{Name: name, Settings: gf.settings()})
+	}
+
+	var unknown []string
+	for name := range want {
+		if !found[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown target(s): %s", strings.Join(unknown, ", "))
+	}
+
+	// Targets run in parallel: one --output for all of them would mix their files,
+	// and the stale-file cleanup of each target would delete the others'
+	if len(targets) > 1 {
+		var shared []string
+		fs.Visit(func(f *flag.Flag) {
+			if perTargetFlags[f.Name] {
+				shared = append(shared, "--"+f.Name)
+			}
+		})
+		if len(shared) > 0 {
+			return nil, fmt.Errorf("%s cannot be shared by %d targets: set it in [[targets]] or select one target with --target", strings.Join(shared, ", "), len(targets))
+		}
+	}
+	return targets, nil
+}
+
+// perTargetFlags are paths and names that must differ between targets
+// (--configs does not reach here: it runs one directory instead of the targets)
+var perTargetFlags = map[string]bool{
+	"output":         true,
+	"package":        true,
+	"drift-baseline": true,
+}
+
+// targetsAt selects the targets of the service the working directory is in, so that
+// `go generate` in a service directory regenerates only that service. A target's service
+// directory is the parent of its configs directory; in the project directory itself all
+// targets run
+func targetsAt(projectDir string, targets []projectTarget) map[string]bool {
+	want := make(map[string]bool)
+	wd, err := os.Getwd()
+	if err != nil {
+		return want
+	}
+	root, err := filepath.Abs(projectDir)
+	if err != nil || root == wd {
+		return want
+	}
+	for _, t := range targets {
+		configs := *t.Configs
+		if !filepath.IsAbs(configs) {
+			configs = filepath.Join(root, configs)
+		}
+		rel, err := filepath.Rel(filepath.Dir(configs), wd)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			want[t.name()] = true
+		}
+	}
+	return want
+}
+
+// runTargets runs the targets in parallel. Reports are printed per target in the order of
+// the project config, followed by a summary; the diagnostics of all targets are returned
+// together and ok is false if any target failed
+func runTargets(targets []target, ro runOptions, out io.Writer) (diag.List, bool) {
+	type result struct {
+		out     bytes.Buffer
+		diags   diag.List
+		ok      bool
+		elapsed time.Duration
+	}
+	results := make([]result, len(targets))
+
+	// Parsed files are shared through the parser cache: common includes are parsed once
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			start := time.Now()
+			r := &results[i]
+			r.diags, r.ok = runTarget(t.Settings, ro, &r.out)
+			r.elapsed = time.Since(start)
+		}()
+	}
+	wg.Wait()
+
+	var diags diag.List
+	failed := 0
+	for i, t := range targets {
+		r := &results[i]
+		if filepath.Clean(t.Settings.ConfigsDir) == filepath.FromSlash(t.Name) {
+			fmt.Fprintf(out, "\n=== %s\n", t.Name)
+		} else {
+			fmt.Fprintf(out, "\n=== %s (%s)\n", t.Name, t.Settings.ConfigsDir)
+		}
+		out.Write(r.out.Bytes())
+		diags = append(diags, r.diags...)
+		if !r.ok {
+			failed++
+		}
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Targets:")
+	for i, t := range targets {
+		r := &results[i]
+		status := "ok"
+		if !r.ok {
+			status = "FAILED"
+		}
+		detail := ""
+		if n := r.diags.Count(diag.SeverityError); n > 0 {
+			detail = fmt.Sprintf(", %d error(s)", n)
+		}
+		if n := r.diags.Count(diag.SeverityWarning) - r.diags.Count(diag.SeverityError); n > 0 {
+			detail += fmt.Sprintf(", %d warning(s)", n)
+		}
+		fmt.Fprintf(out, "  %-6s %s (%s%s)\n", status, t.Name, r.elapsed.Round(time.Millisecond), detail)
+	}
+	fmt.Fprintf(out, "%d target(s): %d ok, %d failed\n", len(targets), len(targets)-failed, failed)
+	return diags, failed == 0
+}
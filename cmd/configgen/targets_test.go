@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vovanwin/configgen/internal/diag"
+)
+
+// targetsProject creates a project with two services and returns its project file
+func targetsProject(t *testing.T, broken bool) string {
+	t.Helper()
+	root := t.TempDir()
+	payments := "[db]\nport = 5432\n"
+	if broken {
+		payments = "[db]\nport = \n"
+	}
+	writeFiles(t, root, map[string]string{
+		projectFileName: `package = "config"
+
+[[targets]]
+name = "api"
+configs = "services/api/configs"
+output = "services/api/config"
+
+[[targets]]
+configs = "services/payments/configs"
+output = "services/payments/config"
+package = "paymentsconfig"
+`,
+		"services/api/configs/config_dev.toml":      "[server]\nport = 8080\n",
+		"services/payments/configs/config_dev.toml": payments,
+	})
+	return filepath.Join(root, projectFileName)
+}
+
+// loadTargets selects targets of the project like main does
+func loadTargets(t *testing.T, project string, args []string, selected string) ([]target, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("configgen", flag.ContinueOnError)
+	addGenFlags(fs)
+	addProjectFlag(fs)
+	if err := fs.Parse(append([]string{"--project=" + project}, args...)); err != nil {
+		t.Fatal(err)
+	}
+	p, cfg, err := loadProject(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return projectTargets(fs, p, cfg, selected)
+}
+
+func TestProjectTargetsSelection(t *testing.T) {
+	project := targetsProject(t, false)
+	root := filepath.Dir(project)
+
+	tests := []struct {
+		selected string
+		dir      string // Working directory relative to the project
+		want     []string
+		wantErr  string
+	}{
+		{want: []string{"api", "services/payments/configs"}},
+		{selected: "api", want: []string{"api"}},
+		{selected: " services/payments/configs ,api", want: []string{"api", "services/payments/configs"}},
+		{selected: "api,web,db", wantErr: "unknown target(s): db, web"},
+		// go generate in a service directory runs only that service
+		{dir: "services/api", want: []string{"api"}},
+	}
+	for _, tt := range tests {
+		chdir(t, filepath.Join(root, filepath.FromSlash(tt.dir)))
+		targets, err := loadTargets(t, project, nil, tt.selected)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("--target=%q: error = %v, want %q", tt.selected, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("--target=%q: %v", tt.selected, err)
+		}
+		var names []string
+		for _, tg := range targets {
+			names = append(names, tg.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("--target=%q in %q: targets = %v, want %v", tt.selected, tt.dir, names, tt.want)
+		}
+	}
+
+	// Target settings override the top level, the command line overrides both
+	chdir(t, root)
+	targets, err := loadTargets(t, project, []string{"--env-var-prefix=CLI_"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if targets[0].Settings.Package != "config" || targets[1].Settings.Package != "paymentsconfig" {
+		t.Errorf("packages = %s, %s", targets[0].Settings.Package, targets[1].Settings.Package)
+	}
+	for _, tg := range targets {
+		if tg.Settings.EnvVarPrefix != "CLI_" {
+			t.Errorf("%s: command-line flag not applied: %+v", tg.Name, tg.Settings)
+		}
+	}
+
+	// Paths of one target cannot be given to several: they would generate into one directory
+	_, err = loadTargets(t, project, []string{"--output=./out", "--package=shared"}, "")
+	if err == nil || !strings.Contains(err.Error(), "--output, --package cannot be shared by 2 targets") {
+		t.Errorf("shared --output: error = %v", err)
+	}
+	targets, err = loadTargets(t, project, []string{"--output=./out"}, "api")
+	if err != nil {
+		t.Fatalf("--output with one target: %v", err)
+	}
+	if targets[0].Settings.OutDir != "./out" {
+		t.Errorf("--output not applied to the selected target: %+v", targets[0].Settings)
+	}
+}
+
+func TestRunTargetsOneFails(t *testing.T) {
+	project := targetsProject(t, true)
+	root := filepath.Dir(project)
+	chdir(t, root)
+	targets, err := loadTargets(t, project, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	diags, ok := runTargets(targets, runOptions{}, &out)
+	if ok {
+		t.Errorf("a failed target must fail the run:\n%s", out.String())
+	}
+
+	// The healthy target is generated anyway
+	if _, err := os.Stat(filepath.Join(root, "services/api/config/configgen_config.go")); err != nil {
+		t.Errorf("api was not generated: %v\n%s", err, out.String())
+	}
+	if _, err := os.Stat(filepath.Join(root, "services/payments/config")); !os.IsNotExist(err) {
+		t.Errorf("payments should not be generated: %v", err)
+	}
+
+	// Errors of the failed target are reported with its file
+	if diags.Count(diag.SeverityError) != 1 || !strings.HasSuffix(diags[0].File, filepath.FromSlash("payments/configs/config_dev.toml")) {
+		t.Errorf("diagnostics = %v", diags)
+	}
+	for _, want := range []string{
+		"=== api",
+		"=== services/payments/configs",
+		"FAILED services/payments/configs",
+		"2 target(s): 1 ok, 1 failed",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report should contain %q:\n%s", want, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), "ok     api") {
+		t.Errorf("api should be reported as ok:\n%s", out.String())
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vovanwin/configgen/internal/diag"
+	"github.com/vovanwin/configgen/internal/generator"
+)
+
+// Input files are polled: no platform-specific notification API, and editors that save
+// through a temp file and rename are handled the same way as in-place writes
+const (
+	watchInterval = 200 * time.Millisecond
+	// watchDebounce is the quiet period after the last change: a burst of saves regenerates once
+	watchDebounce = 300 * time.Millisecond
+)
+
+// fileStamp is what the watcher compares to detect a change
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// snapshot stamps the TOML files of the configs directory (so new config_*.toml are noticed)
+// and the inputs of the last parse, which include files outside the directory
+func snapshot(dir string, inputs []string) map[string]fileStamp {
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.toml"))
+	paths = append(paths, inputs...)
+	stamps := make(map[string]fileStamp, len(paths))
+	for _, path := range paths {
+		if st, err := os.Stat(path); err == nil {
+			stamps[filepath.Clean(path)] = fileStamp{st.ModTime(), st.Size()}
+		} else {
+			stamps[filepath.Clean(path)] = fileStamp{size: -1}
+		}
+	}
+	return stamps
+}
+
+// sameStamps reports whether no watched file was added, removed or changed
+func sameStamps(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, st := range a {
+		if other, ok := b[path]; !ok || !other.modTime.Equal(st.modTime) || other.size != st.size {
+			return false
+		}
+	}
+	return true
+}
+
+// watcher regenerates code once the watched files stop changing for the debounce period
+type watcher struct {
+	dir        string
+	debounce   time.Duration
+	regenerate func() []string // Returns the inputs to watch, nil if generation failed
+
+	inputs    []string
+	last      map[string]fileStamp
+	changedAt time.Time // Zero when no change is pending
+}
+
+// newWatcher generates code and stamps its inputs
+func newWatcher(dir string, debounce time.Duration, regenerate func() []string) *watcher {
+	w := &watcher{dir: dir, debounce: debounce, regenerate: regenerate}
+	w.inputs = regenerate()
+	w.last = snapshot(dir, w.inputs)
+	return w
+}
+
+// step polls the files at time now and reports whether the code was regenerated
+func (w *watcher) step(now time.Time) bool {
+	cur := snapshot(w.dir, w.inputs)
+	if !sameStamps(w.last, cur) {
+		w.last = cur
+		w.changedAt = now
+		return false
+	}
+	if w.changedAt.IsZero() || now.Sub(w.changedAt) < w.debounce {
+		return false
+	}
+	w.changedAt = time.Time{}
+	// After a failed generation the previous inputs stay watched
+	if inputs := w.regenerate(); inputs != nil {
+		w.inputs = inputs
+	}
+	// Inputs may have changed: an include was added or removed
+	w.last = snapshot(w.dir, w.inputs)
+	return true
+}
+
+// run polls on every tick until ctx is done
+func (w *watcher) run(ctx context.Context, ticks <-chan time.Time) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticks:
+			w.step(now)
+		}
+	}
+}
+
+// regenerator returns the generation of one watch step: errors are printed and watching
+// continues, so a broken save does not stop the loop
+func regenerator(s settings, out io.Writer) func() []string {
+	return func() []string {
+		stamp := time.Now().Format("15:04:05")
+		gen, diags := prepare(s)
+		if gen == nil {
+			fmt.Fprintf(out, "%s error: %d problem(s), waiting for changes\n", stamp, diags.Count(diag.SeverityError))
+			diag.Write(os.Stderr, "text", diags)
+			return nil
+		}
+		diag.Write(os.Stderr, "text", diags)
+		files, err := generator.Render(gen.Options, gen.Schema.Fields)
+		if err != nil {
+			fmt.Fprintf(out, "%s error: generate: %v\n", stamp, err)
+			return nil
+		}
+		ch, err := generator.Write(s.OutDir, files)
+		if err != nil {
+			fmt.Fprintf(out, "%s error: %v\n", stamp, err)
+			return nil
+		}
+		switch {
+		case len(ch.Written) == 0 && len(ch.Removed) == 0:
+			fmt.Fprintf(out, "%s up to date\n", stamp)
+		case len(ch.Written) > 0:
+			fmt.Fprintf(out, "%s regenerated: %s\n", stamp, strings.Join(ch.Written, ", "))
+		}
+		if len(ch.Removed) > 0 {
+			fmt.Fprintf(out, "%s removed: %s\n", stamp, strings.Join(ch.Removed, ", "))
+		}
+		return gen.Schema.Inputs
+	}
+}
+
+// runWatch generates code and regenerates it whenever an input changes, until interrupted
+func runWatch(s settings, out io.Writer) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	w := newWatcher(s.ConfigsDir, watchDebounce, regenerator(s, out))
+	fmt.Fprintf(out, "watching %s for changes (Ctrl+C to stop)\n", s.ConfigsDir)
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	w.run(ctx, ticker.C)
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatcherDebouncesBurst(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"config_dev.toml": "[server]\nport = 1\n"})
+
+	runs := 0
+	w := newWatcher(dir, 300*time.Millisecond, func() []string {
+		runs++
+		return []string{filepath.Join(dir, "config_dev.toml")}
+	})
+	if runs != 1 {
+		t.Fatalf("initial generation: %d runs, want 1", runs)
+	}
+
+	// Three saves 200ms apart, then quiet: one regeneration 300ms after the last save
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	steps := []struct {
+		ms    int
+		write string // Content saved before the poll
+		regen bool
+	}{
+		{ms: 200, write: "[server]\nport = 22\n"},
+		{ms: 400, write: "[server]\nport = 333\n"},
+		{ms: 600, write: "[server]\nport = 4444\n"},
+		{ms: 800},
+		{ms: 900, regen: true},
+		{ms: 1100},
+		{ms: 5000},
+	}
+	for _, s := range steps {
+		if s.write != "" {
+			writeFiles(t, dir, map[string]string{"config_dev.toml": s.write})
+		}
+		if got := w.step(at(s.ms)); got != s.regen {
+			t.Errorf("step at %dms: regenerated = %v, want %v", s.ms, got, s.regen)
+		}
+	}
+	if runs != 2 {
+		t.Errorf("burst of saves: %d runs, want 2 (initial and one regeneration)", runs)
+	}
+
+	// A new config file in the directory is a change too
+	writeFiles(t, dir, map[string]string{"config_prod.toml": "[server]\nport = 80\n"})
+	w.step(at(6000))
+	if !w.step(at(6300)) {
+		t.Error("new config file should trigger a regeneration")
+	}
+}
+
+func TestWatcherSurvivesParseError(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"configs/config_dev.toml": "[server]\nport = 1\n"})
+	set := testSettings(filepath.Join(dir, "configs"), filepath.Join(dir, "config"))
+	generated := filepath.Join(set.OutDir, "configgen_config.go")
+
+	var out bytes.Buffer
+	w := newWatcher(set.ConfigsDir, 300*time.Millisecond, regenerator(set, &out))
+	if _, err := os.Stat(generated); err != nil {
+		t.Fatalf("initial generation: %v\n%s", err, out.String())
+	}
+
+	start := time.Now()
+	save := func(at time.Duration, content string) {
+		t.Helper()
+		writeFiles(t, set.ConfigsDir, map[string]string{"config_dev.toml": content})
+		w.step(start.Add(at))
+		if !w.step(start.Add(at + 300*time.Millisecond)) {
+			t.Fatalf("save at %s: no regeneration", at)
+		}
+	}
+
+	save(time.Second, "[server]\nport = \n")
+	if !strings.Contains(out.String(), "error: 1 problem(s), waiting for changes") {
+		t.Errorf("parse error not reported:\n%s", out.String())
+	}
+
+	out.Reset()
+	save(2*time.Second, "[server]\nport = 1\nhost = \"localhost\"\n")
+	if !strings.Contains(out.String(), "regenerated: configgen_config.go") {
+		t.Errorf("watcher did not recover after the parse error:\n%s", out.String())
+	}
+	b, err := os.ReadFile(generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "Host") {
+		t.Error("the fixed config was not generated")
+	}
+}
@@ -0,0 +1,450 @@
+// Package configgen — публичный API генератора: разбор TOML конфигов и flags.toml,
+// построение схемы и генерация Go кода. Используется из своих инструментов
+// (линтеры, bootstrap платформы), CLI cmd/configgen построен на тех же пакетах.
+//
+//	schema, err := configgen.BuildSchema("configs", configgen.SchemaOptions{})
+//	...
+//	files, err := configgen.Render(configgen.Options{PackageName: "config", WithLoader: true},
+//		schema.Fields)
+//
+// Типы пакета собственные, внутренние структуры парсера и генератора в API не попадают.
+// Совместимость сохраняется в пределах мажорной версии модуля
+package configgen
+
+import (
+	"github.com/vovanwin/configgen/internal/diag"
+	"github.com/vovanwin/configgen/internal/generator"
+	"github.com/vovanwin/configgen/internal/model"
+	"github.com/vovanwin/configgen/internal/parser"
+)
+
+// Kind тип поля конфига
+type Kind int
+
+// Типы полей
+const (
+	KindString   = Kind(model.KindString)
+	KindInt      = Kind(model.KindInt)
+	KindFloat    = Kind(model.KindFloat)
+	KindBool     = Kind(model.KindBool)
+	KindObject   = Kind(model.KindObject)
+	KindSlice    = Kind(model.KindSlice)
+	KindDuration = Kind(model.KindDuration)
+)
+
+func (k Kind) String() string {
+	return model.Kind(k).String()
+}
+
+// Field поле конфига: секции — KindObject с Children
+type Field struct {
+	Name      string            // Имя в Go (CamelCase)
+	TOMLName  string            // Имя ключа в TOML
+	Kind      Kind              // Тип поля
+	ItemKind  Kind              // Для KindSlice: тип элементов
+	Children  map[string]*Field // Для KindObject: поля секции
+	Comment   string            // Комментарий из TOML, становится документацией поля
+	EnvVars   []string          // Имена переменных окружения из директивы # env:
+	Sensitive bool              // Директива # sensitive: значение не выводится в открытом виде
+
+	// Поле парсера: подстановки ${section.key} и значение для справки флагов
+	// сохраняются при передаче поля обратно в Render, Intersect и Union
+	parsed *model.Field
+}
+
+// FlagKind тип feature flag
+type FlagKind int
+
+// Типы feature flags
+const (
+	FlagKindBool   = FlagKind(model.FlagKindBool)
+	FlagKindInt    = FlagKind(model.FlagKindInt)
+	FlagKindFloat  = FlagKind(model.FlagKindFloat)
+	FlagKindString = FlagKind(model.FlagKindString)
+	FlagKindEnum   = FlagKind(model.FlagKindEnum)
+)
+
+func (k FlagKind) String() string {
+	return model.FlagKind(k).String()
+}
+
+// FlagDef feature flag из flags.toml
+type FlagDef struct {
+	Name        string   // CamelCase имя (NewCatalogUi)
+	TOMLName    string   // snake_case имя (new_catalog_ui)
+	Kind        FlagKind // Тип значения
+	Default     any      // Значение по умолчанию: bool, int64, float64 или string
+	Description string   // Описание флага
+	EnumValues  []string // Допустимые значения для FlagKindEnum
+}
+
+// LayerKind тип слоя
+type LayerKind int
+
+// Типы слоёв
+const (
+	LayerFile   = LayerKind(model.LayerFile)   // TOML файл
+	LayerDotEnv = LayerKind(model.LayerDotEnv) // .env файл с KEY=VALUE
+	LayerEnv    = LayerKind(model.LayerEnv)    // Переменные окружения
+	LayerFlags  = LayerKind(model.LayerFlags)  // Флаги командной строки (BindFlags)
+)
+
+func (k LayerKind) String() string {
+	return model.LayerKind(k).String()
+}
+
+// Layer слой в порядке мержа конфигурации в сгенерированном loader
+type Layer struct {
+	Kind     LayerKind // Тип слоя
+	Path     string    // Путь относительно директории конфигов, {env} и {region} заменяются при загрузке
+	Required bool      // Ошибка загрузки, если файла нет
+	Override bool      // Применяется только к текущему окружению
+}
+
+// String возвращает слой в формате флага --layers
+func (l Layer) String() string {
+	return l.model().String()
+}
+
+// Mode режим построения схемы из окружений
+type Mode string
+
+// Режимы схемы
+const (
+	ModeIntersect Mode = parser.ModeIntersect // Поля, общие для всех окружений
+	ModeUnion     Mode = parser.ModeUnion     // Поля всех окружений
+)
+
+// SchemaOptions настройки BuildSchema; нулевое значение — настройки CLI по умолчанию
+type SchemaOptions struct {
+	Layers []Layer // Слои, по которым ищутся файлы (nil = DefaultLayers(false))
+	Mode   Mode    // Режим схемы (пусто = ModeIntersect)
+}
+
+// Schema схема директории конфигов, как её строит CLI
+type Schema struct {
+	Fields       map[string]*Field // Поля для генерации: базовые слои (value.toml) и окружения по режиму
+	Environments []Environment     // Окружения в порядке имён, без региональных оверлеев
+	Inputs       []string          // Прочитанные файлы вместе с include
+}
+
+// Environment окружение схемы
+type Environment struct {
+	Name   string            // Имя окружения: prod
+	Path   string            // Файл окружения: configs/config_prod.toml
+	Fields map[string]*Field // Поля окружения с учётом extends и своих include
+}
+
+// Options настройки генерации кода
+type Options struct {
+	OutputDir       string     // Директория для Generate; Render её не использует
+	PackageName     string     // Имя пакета
+	EnvPrefix       string     // Переменная окружения с именем окружения (по умолчанию APP_ENV)
+	RegionEnv       string     // Переменная окружения с регионом для оверлеев (по умолчанию APP_REGION)
+	WithLoader      bool       // Генерировать загрузчик configgen_loader.go
+	WithFlags       bool       // Генерировать файлы feature flags
+	FlagDefs        []*FlagDef // Feature flags из flags.toml
+	WithEnvOverride bool       // Переопределение значений переменными окружения в loader
+	EnvVarPrefix    string     // Префикс переменных окружения (например, "APP_")
+	WithPFlag       bool       // Генерировать BindPFlags для github.com/spf13/pflag
+	Layers          []Layer    // Порядок слоёв в loader (nil = DefaultLayers(WithEnvOverride))
+}
+
+// File сгенерированный файл в памяти
+type File struct {
+	Name    string // Имя файла: configgen_config.go
+	Content []byte
+}
+
+// Changes результат записи файлов
+type Changes struct {
+	Written   []string // Новые и изменённые файлы
+	Unchanged []string // Файлы с тем же содержимым, не перезаписаны
+	Removed   []string // Устаревшие configgen_*.go, которые больше не генерируются
+}
+
+// Severity уровень диагностики
+type Severity int
+
+// Уровни диагностик
+const (
+	SeverityInfo    = Severity(diag.SeverityInfo)
+	SeverityWarning = Severity(diag.SeverityWarning)
+	SeverityError   = Severity(diag.SeverityError)
+)
+
+func (s Severity) String() string {
+	return diag.Severity(s).String()
+}
+
+// Diagnostic ошибка или предупреждение с позицией в TOML файле
+type Diagnostic struct {
+	Code     string   // Код: syntax, directive, include, extends, flag, schema...
+	Severity Severity // Уровень
+	File     string   // Файл; Line и Column начинаются с 1, 0 — неизвестно
+	Line     int
+	Column   int
+	Key      string // Путь ключа: db.host
+	Message  string
+}
+
+// String форматирует диагностику: config_prod.toml:3:7: error: db.port: ... [syntax]
+func (d Diagnostic) String() string {
+	return diag.Diagnostic{
+		Code: d.Code, Severity: diag.Severity(d.Severity), File: d.File,
+		Line: d.Line, Column: d.Column, Key: d.Key, Message: d.Message,
+	}.String()
+}
+
+// Diagnostics список диагностик
+type Diagnostics []Diagnostic
+
+// ParseFile читает один TOML файл (с include) и возвращает его поля. extends не
+// разрешается: поля окружения вместе с родителем и схему директории строит BuildSchema
+func ParseFile(path string) (map[string]*Field, error) {
+	fields, err := parser.ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return fromModel(fields), nil
+}
+
+// BuildSchema строит схему директории конфигов так же, как CLI: базовые слои (value.toml),
+// файлы окружений с разрешёнными цепочками extends и региональные оверлеи, объединённые
+// по режиму. Ошибки всех файлов возвращаются вместе, см. DiagnosticsOf
+func BuildSchema(dir string, opts SchemaOptions) (*Schema, error) {
+	layers := model.DefaultLayers(false)
+	if opts.Layers != nil {
+		layers = layersModel(opts.Layers)
+	}
+	mode := opts.Mode
+	if mode == "" {
+		mode = ModeIntersect
+	}
+	s, err := parser.BuildSchema(dir, layers, string(mode))
+	if err != nil {
+		return nil, err
+	}
+
+	schema := &Schema{Fields: fromModel(s.Fields), Inputs: s.Inputs}
+	for i, env := range s.Envs {
+		schema.Environments = append(schema.Environments, Environment{
+			Name:   env,
+			Path:   s.EnvPaths[i],
+			Fields: fromModel(s.EnvTrees[i]),
+		})
+	}
+	return schema, nil
+}
+
+// ParseFlagsFile читает flags.toml и возвращает флаги, отсортированные по имени
+func ParseFlagsFile(path string) ([]*FlagDef, error) {
+	defs, err := parser.ParseFlagsFile(path)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*FlagDef, len(defs))
+	for i, d := range defs {
+		out[i] = &FlagDef{
+			Name:        d.Name,
+			TOMLName:    d.TOMLName,
+			Kind:        FlagKind(d.Kind),
+			Default:     d.Default,
+			Description: d.Description,
+			EnumValues:  d.EnumValues,
+		}
+	}
+	return out, nil
+}
+
+// Intersect оставляет поля, общие для всех деревьев (ключ и тип совпадают): схема
+// режима intersect, в которой каждое поле есть в каждом окружении
+func Intersect(trees ...map[string]*Field) map[string]*Field {
+	return fromModel(parser.Intersect(treesModel(trees)...))
+}
+
+// Union объединяет поля всех деревьев: схема режима union
+func Union(trees ...map[string]*Field) map[string]*Field {
+	return fromModel(parser.Union(treesModel(trees)...))
+}
+
+// ParseLayers разбирает порядок слоёв в формате флага --layers
+func ParseLayers(spec string) ([]Layer, error) {
+	layers, err := parser.ParseLayers(spec)
+	if err != nil {
+		return nil, err
+	}
+	return layersFromModel(layers), nil
+}
+
+// DefaultLayers порядок слоёв по умолчанию; withEnv добавляет .env и переменные окружения
+func DefaultLayers(withEnv bool) []Layer {
+	return layersFromModel(model.DefaultLayers(withEnv))
+}
+
+// Render генерирует файлы в памяти, не трогая диск: вызывающий код может их
+// дополнить или записать сам. Options.OutputDir не используется
+func Render(opts Options, fields map[string]*Field) ([]File, error) {
+	files, err := generator.Render(opts.generator(), toModel(fields))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]File, len(files))
+	for i, f := range files {
+		out[i] = File{Name: f.Name, Content: f.Content}
+	}
+	return out, nil
+}
+
+// Write записывает файлы в dir так же, как CLI: атомарно, без перезаписи неизменённых
+// и с удалением устаревших configgen_*.go
+func Write(dir string, files []File) (Changes, error) {
+	in := make([]generator.File, len(files))
+	for i, f := range files {
+		in[i] = generator.File{Name: f.Name, Content: f.Content}
+	}
+	ch, err := generator.Write(dir, in)
+	return Changes{Written: ch.Written, Unchanged: ch.Unchanged, Removed: ch.Removed}, err
+}
+
+// Generate генерирует код в Options.OutputDir: Render и Write
+func Generate(opts Options, fields map[string]*Field) (Changes, error) {
+	files, err := Render(opts, fields)
+	if err != nil {
+		return Changes{}, err
+	}
+	return Write(opts.OutputDir, files)
+}
+
+// DiagnosticsOf извлекает диагностики из ошибки ParseFile, BuildSchema, ParseFlagsFile или Render
+func DiagnosticsOf(err error) Diagnostics {
+	list := diag.FromError(err)
+	if list == nil {
+		return nil
+	}
+	out := make(Diagnostics, len(list))
+	for i, d := range list {
+		out[i] = Diagnostic{
+			Code:     d.Code,
+			Severity: Severity(d.Severity),
+			File:     d.File,
+			Line:     d.Line,
+			Column:   d.Column,
+			Key:      d.Key,
+			Message:  d.Message,
+		}
+	}
+	return out
+}
+
+// generator преобразует опции в опции внутреннего генератора
+func (o Options) generator() generator.Options {
+	opts := generator.Options{
+		OutputDir:       o.OutputDir,
+		PackageName:     o.PackageName,
+		EnvPrefix:       o.EnvPrefix,
+		RegionEnv:       o.RegionEnv,
+		WithLoader:      o.WithLoader,
+		WithFlags:       o.WithFlags,
+		WithEnvOverride: o.WithEnvOverride,
+		EnvVarPrefix:    o.EnvVarPrefix,
+		WithPFlag:       o.WithPFlag,
+	}
+	if opts.EnvPrefix == "" {
+		opts.EnvPrefix = "APP_ENV"
+	}
+	if o.Layers != nil {
+		opts.Layers = layersModel(o.Layers)
+	}
+	for _, d := range o.FlagDefs {
+		opts.FlagDefs = append(opts.FlagDefs, &model.FlagDef{
+			Name:        d.Name,
+			TOMLName:    d.TOMLName,
+			Kind:        model.FlagKind(d.Kind),
+			Default:     d.Default,
+			Description: d.Description,
+			EnumValues:  d.EnumValues,
+		})
+	}
+	return opts
+}
+
+// model преобразует слой в слой модели
+func (l Layer) model() model.Layer {
+	return model.Layer{Kind: model.LayerKind(l.Kind), Path: l.Path, Required: l.Required, Override: l.Override}
+}
+
+// layersModel преобразует слои в слои модели
+func layersModel(layers []Layer) []model.Layer {
+	out := make([]model.Layer, len(layers))
+	for i, l := range layers {
+		out[i] = l.model()
+	}
+	return out
+}
+
+// layersFromModel преобразует слои модели в слои пакета
+func layersFromModel(layers []model.Layer) []Layer {
+	out := make([]Layer, len(layers))
+	for i, l := range layers {
+		out[i] = Layer{Kind: LayerKind(l.Kind), Path: l.Path, Required: l.Required, Override: l.Override}
+	}
+	return out
+}
+
+// fromModel копирует дерево полей парсера в поля пакета
+func fromModel(fields map[string]*model.Field) map[string]*Field {
+	if fields == nil {
+		return nil
+	}
+	out := make(map[string]*Field, len(fields))
+	for k, f := range fields {
+		out[k] = &Field{
+			Name:      f.Name,
+			TOMLName:  f.TOMLName,
+			Kind:      Kind(f.Kind),
+			ItemKind:  Kind(f.ItemKind),
+			Children:  fromModel(f.Children),
+			Comment:   f.Comment,
+			EnvVars:   f.EnvVars,
+			Sensitive: f.Sensitive,
+			parsed:    f,
+		}
+	}
+	return out
+}
+
+// toModel копирует поля пакета в дерево полей генератора; данные парсера, которых нет
+// в Field, берутся из поля, из которого оно было получено
+func toModel(fields map[string]*Field) map[string]*model.Field {
+	if fields == nil {
+		return nil
+	}
+	out := make(map[string]*model.Field, len(fields))
+	for k, f := range fields {
+		m := &model.Field{
+			Name:      f.Name,
+			TOMLName:  f.TOMLName,
+			Kind:      model.Kind(f.Kind),
+			ItemKind:  model.Kind(f.ItemKind),
+			Children:  toModel(f.Children),
+			Comment:   f.Comment,
+			EnvVars:   f.EnvVars,
+			Sensitive: f.Sensitive,
+		}
+		if f.parsed != nil {
+			m.Refs, m.WholeRef, m.Default = f.parsed.Refs, f.parsed.WholeRef, f.parsed.Default
+		}
+		out[k] = m
+	}
+	return out
+}
+
+// treesModel преобразует деревья полей для Intersect и Union
+func treesModel(trees []map[string]*Field) []map[string]*model.Field {
+	out := make([]map[string]*model.Field, len(trees))
+	for i, t := range trees {
+		out[i] = toModel(t)
+	}
+	return out
+}
@@ -0,0 +1,153 @@
+package configgen_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vovanwin/configgen"
+)
+
+func TestPublicAPI(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config_prod.toml": "[db]\nhost = \"db.prod\"\nport = 5432\nreplicas = 3\n",
+		"config_stg.toml":  "[db]\nhost = \"db.stg\"\nport = 5432\n",
+		"flags.toml":       "[flags.new_ui]\ntype = \"bool\"\ndefault = false\ndescription = \"Новый UI\"\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	prod, err := configgen.ParseFile(filepath.Join(dir, "config_prod.toml"))
+	if err != nil {
+		t.Fatalf("ParseFile вернул ошибку: %v", err)
+	}
+	stg, err := configgen.ParseFile(filepath.Join(dir, "config_stg.toml"))
+	if err != nil {
+		t.Fatalf("ParseFile вернул ошибку: %v", err)
+	}
+	flags, err := configgen.ParseFlagsFile(filepath.Join(dir, "flags.toml"))
+	if err != nil {
+		t.Fatalf("ParseFlagsFile вернул ошибку: %v", err)
+	}
+
+	schema := configgen.Intersect(prod, stg)
+	if _, ok := schema["db"].Children["replicas"]; ok {
+		t.Error("Intersect: replicas есть только в prod")
+	}
+	if f := configgen.Union(prod, stg)["db"].Children["replicas"]; f == nil || f.Kind != configgen.KindInt {
+		t.Error("Union: ожидалось поле db.replicas типа int")
+	}
+
+	opts := configgen.Options{
+		OutputDir:   filepath.Join(dir, "config"),
+		PackageName: "config",
+		WithLoader:  true,
+		WithFlags:   true,
+		FlagDefs:    flags,
+		Layers:      configgen.DefaultLayers(false),
+	}
+	out, err := configgen.Render(opts, schema)
+	if err != nil {
+		t.Fatalf("Render вернул ошибку: %v", err)
+	}
+	if len(out) != 5 || out[0].Name != "configgen_config.go" {
+		t.Fatalf("неожиданный результат Render: %d файлов", len(out))
+	}
+	if code := string(out[0].Content); !strings.Contains(code, "Host") || strings.Contains(code, "Replicas") {
+		t.Error("configgen_config.go должен содержать только общие поля")
+	}
+	if _, err := os.Stat(opts.OutputDir); !os.IsNotExist(err) {
+		t.Error("Render не должен писать на диск")
+	}
+
+	ch, err := configgen.Generate(opts, schema)
+	if err != nil {
+		t.Fatalf("Generate вернул ошибку: %v", err)
+	}
+	if len(ch.Written) != len(out) {
+		t.Errorf("Generate записал %v, ожидалось %d файлов", ch.Written, len(out))
+	}
+	for _, f := range out {
+		got, err := os.ReadFile(filepath.Join(opts.OutputDir, f.Name))
+		if err != nil || string(got) != string(f.Content) {
+			t.Errorf("%s: содержимое на диске отличается от Render", f.Name)
+		}
+	}
+}
+
+func TestDiagnosticsOf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config_dev.toml")
+	if err := os.WriteFile(path, []byte("[db]\nport = = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := configgen.ParseFile(path)
+	if err == nil {
+		t.Fatal("ожидалась ошибка синтаксиса")
+	}
+	diags := configgen.DiagnosticsOf(err)
+	if len(diags) != 1 || diags[0].File != path || diags[0].Line != 2 || diags[0].Severity != configgen.SeverityError {
+		t.Errorf("неверная диагностика: %+v", diags)
+	}
+}
+
+func TestBuildSchema(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"value.toml":       "[app]\nname = \"svc\"\n",
+		"config_prod.toml": "[db]\n# Адрес базы\nhost = \"db.internal\"\nport = 5432\npassword = \"prod-secret\"\n",
+		"config_stg.toml":  "extends = \"prod\"\n\n[db]\nport = 6432\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// ParseFile читает один файл: у stg только собственные ключи
+	stg, err := configgen.ParseFile(filepath.Join(dir, "config_stg.toml"))
+	if err != nil {
+		t.Fatalf("ParseFile вернул ошибку: %v", err)
+	}
+	if _, ok := stg["db"].Children["host"]; ok {
+		t.Error("ParseFile не разрешает extends")
+	}
+
+	schema, err := configgen.BuildSchema(dir, configgen.SchemaOptions{})
+	if err != nil {
+		t.Fatalf("BuildSchema вернул ошибку: %v", err)
+	}
+	if len(schema.Environments) != 2 || schema.Environments[1].Name != "stg" {
+		t.Fatalf("неожиданные окружения: %+v", schema.Environments)
+	}
+	if _, ok := schema.Environments[1].Fields["db"].Children["host"]; !ok {
+		t.Error("поля stg должны включать поля prod через extends")
+	}
+	for _, section := range []string{"app", "db"} {
+		if schema.Fields[section] == nil {
+			t.Errorf("в схеме нет секции %s", section)
+		}
+	}
+	if f := schema.Fields["db"].Children["host"]; f.Kind != configgen.KindString || f.Comment != "Адрес базы" {
+		t.Errorf("db.host: %+v", f)
+	}
+
+	// Данные парсера, которых нет в Field, сохраняются до генерации: host одинаков
+	// во всех окружениях и становится умолчанием флага, секрет — нет
+	out, err := configgen.Render(configgen.Options{PackageName: "config", WithLoader: true}, schema.Fields)
+	if err != nil {
+		t.Fatalf("Render вернул ошибку: %v", err)
+	}
+	loader := string(out[1].Content)
+	if !strings.Contains(loader, `Default: "db.internal"`) || strings.Contains(loader, "prod-secret") {
+		t.Errorf("неверные умолчания флагов в %s", out[1].Name)
+	}
+
+	if _, err := configgen.BuildSchema(dir, configgen.SchemaOptions{Mode: "all"}); err == nil {
+		t.Error("ожидалась ошибка для неизвестного режима")
+	}
+}
@@ -1,5 +1,7 @@
 // Code generated by configgen. DO NOT EDIT.
 // Код сгенерирован configgen. НЕ РЕДАКТИРОВАТЬ.
+// configgen version: dev
+// configgen inputs: sha256:2870f92a9897b8e6
 
 package config
 
@@ -17,7 +19,8 @@ const (
 
 // Config основная структура конфигурации
 type Config struct {
-	Env Environment `toml:"-"`
+	Env    Environment `toml:"-"`
+	Region string      `toml:"-"` // Регион оверлея, пусто если не задан
 	// Информация о приложении
 	App App `toml:"app"`
 	Db  Db  `toml:"db"`
@@ -56,6 +59,9 @@ type App struct {
 
 // Db секция конфигурации
 type Db struct {
+	// Строка подключения, собирается из значений выше
+	// Env: DATABASE_URL, PG_URL
+	Dsn string `toml:"dsn"`
 	// Хост базы данных
 	Host string `toml:"host"`
 	// Время жизни неактивного соединения
@@ -112,9 +118,12 @@ type Redis struct {
 
 // Server секция конфигурации
 type Server struct {
-	// Адрес для прослушивания
+	// Разрешённые CORS источники
+	AllowedOrigins []string `toml:"allowed_origins"`
+	// Адрес для прослушивания (IP пода, если задан)
 	Host string `toml:"host"`
 	// Порт сервера
+	// Env: PORT, APP_HTTP_PORT
 	Port int `toml:"port"`
 	// Таймаут на чтение запроса
 	ReadTimeout time.Duration `toml:"read_timeout"`
@@ -1,5 +1,7 @@
 // Code generated by configgen. DO NOT EDIT.
 // Код сгенерирован configgen. НЕ РЕДАКТИРОВАТЬ.
+// configgen version: dev
+// configgen inputs: sha256:e862e66f6b8830a0
 
 package config
 
@@ -1,5 +1,7 @@
 // Code generated by configgen. DO NOT EDIT.
 // Код сгенерирован configgen. НЕ РЕДАКТИРОВАТЬ.
+// configgen version: dev
+// configgen inputs: sha256:5d02407a09a6a690
 
 //go:build !production
 
@@ -1,25 +1,33 @@
 // Code generated by configgen. DO NOT EDIT.
 // Код сгенерирован configgen. НЕ РЕДАКТИРОВАТЬ.
+// configgen version: dev
+// configgen inputs: sha256:093c2e3c11f10341
 
 package config
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/knadh/koanf/parsers/toml/v2"
-	kenv "github.com/knadh/koanf/providers/env"
-	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/v2"
 )
 
 var (
-	allConfigs map[Environment]*Config
-	configMu   sync.RWMutex
-	currentEnv Environment
+	allConfigs    map[Target]*Config
+	allSources    map[Target]map[string]Source
+	configMu      sync.RWMutex
+	currentTarget Target
 )
 
 // LoadOptions настраивает загрузку конфигурации
@@ -31,148 +39,1529 @@ type LoadOptions struct {
 	// Если пусто, читается из переменной окружения APP_ENV
 	Environment Environment
 
-	// EnableOverride позволяет override.toml переопределять значения текущего окружения
+	// EnableOverride включает override-слои (override.toml, config_local.toml и т.п.) для текущего окружения
 	EnableOverride bool
 
-	// EnableEnv позволяет env vars переопределять значения из файлов
+	// Region регион для оверлеев config_{env}.{region}.toml (eu, us, ...)
+	// Если пусто, читается из переменной окружения APP_REGION; без региона оверлеи не применяются
+	Region string
+
+	// Layers порядок слоёв мержа; nil — DefaultLayers()
+	Layers []Layer
+
+	// AllEnvironments загружает конфиги всех окружений, а не только текущего
+	// По умолчанию читается только config_{env}.toml текущего окружения, и GetAll()
+	// возвращает map из одного элемента; ошибка в файле другого окружения не мешает запуску
+	AllEnvironments bool
+
+	// EnableEnv позволяет .env и env vars переопределять значения из файлов
 	// Разделитель секций — двойное подчёркивание (__), одинарное (_) сохраняется
 	// Пример: APP_SERVER__HOST=localhost переопределяет server.host
 	// Пример: APP_DB__MAX_OPEN_CONNS=10 переопределяет db.max_open_conns
+	// Значение разбирается по типу поля: списки через запятую или JSON массивом,
+	// длительности (5s), bool (true/1); секция целиком — JSON объектом (APP_DB='{"host":"x"}')
 	EnableEnv bool
+
+	// StrictEnv реакция на переменные с префиксом APP_, не соответствующие ни одному ключу
+	// (опечатка APP_SERVER__PORTT): StrictIgnore (по умолчанию), StrictWarn или StrictError
+	StrictEnv StrictMode
+
+	// StrictKeys реакция на ключи TOML, которых нет в сгенерированной схеме (опечатка pool_sise
+	// или ключ, отброшенный режимом intersect): StrictIgnore (по умолчанию), StrictWarn или StrictError
+	StrictKeys StrictMode
+
+	// OnWarning получает предупреждения режима StrictWarn; nil — вывод через log.Printf
+	OnWarning func(error)
+
+	// Flags значения флагов командной строки из BindFlags (BindPFlags) для слоя $flags;
+	// nil — слой пропускается
+	Flags *BoundFlags
+}
+
+// StrictMode реакция загрузчика на неизвестные значения
+type StrictMode int
+
+const (
+	StrictIgnore StrictMode = iota // молча игнорировать
+	StrictWarn                     // предупреждение через OnWarning
+	StrictError                    // ошибка загрузки
+)
+
+// warn сообщает предупреждение через OnWarning или стандартный лог
+func (o *LoadOptions) warn(err error) {
+	if o.OnWarning != nil {
+		o.OnWarning(err)
+		return
+	}
+	log.Printf("config: %v", err)
+}
+
+// Target окружение вместе с регионом-оверлеем
+type Target struct {
+	Env    Environment
+	Region string // пусто — без оверлея
 }
 
-// Load загружает все конфигурации окружений и выбирает нужную
-// Порядок мержа для каждого env: value.toml -> config_{env}.toml
-// Для текущего env дополнительно: -> override.toml (если EnableOverride=true)
+// String возвращает prod или prod.eu
+func (t Target) String() string {
+	if t.Region == "" {
+		return string(t.Env)
+	}
+	return string(t.Env) + "." + t.Region
+}
+
+// LayerKind тип слоя конфигурации
+type LayerKind int
+
+const (
+	LayerFile   LayerKind = iota // TOML файл
+	LayerDotEnv                  // .env файл с KEY=VALUE
+	LayerEnv                     // переменные окружения
+	LayerFlags                   // флаги командной строки (BindFlags)
+)
+
+// Layer один слой в цепочке мержа
+type Layer struct {
+	Kind     LayerKind
+	Path     string // Путь относительно ConfigDir, {env} и {region} заменяются при загрузке
+	Required bool   // Ошибка, если файла нет
+	Override bool   // Только для текущего окружения
+}
+
+// IsEnvFile возвращает true для слоя, задающего окружение (config_{env}.toml)
+func (l Layer) IsEnvFile() bool {
+	return l.Kind == LayerFile && !l.Override && strings.Contains(l.Path, "{env}") && !l.IsOverlay()
+}
+
+// IsOverlay возвращает true для регионального оверлея (config_{env}.{region}.toml)
+func (l Layer) IsOverlay() bool {
+	return l.Kind == LayerFile && strings.Contains(l.Path, "{region}")
+}
+
+// DefaultLayers возвращает порядок слоёв, заданный при генерации
+func DefaultLayers() []Layer {
+	return []Layer{
+		{Kind: LayerFile, Path: "value.toml", Required: false, Override: false},
+		{Kind: LayerFile, Path: "config_{env}.toml", Required: true, Override: false},
+		{Kind: LayerFile, Path: "config_{env}.{region}.toml", Required: false, Override: false},
+		{Kind: LayerFile, Path: "override.toml", Required: false, Override: true},
+		{Kind: LayerFile, Path: "override_{env}.toml", Required: false, Override: true},
+		{Kind: LayerFile, Path: "config_local.toml", Required: false, Override: true},
+		{Kind: LayerDotEnv, Path: ".env", Required: false, Override: true},
+		{Kind: LayerEnv, Path: "", Required: false, Override: true},
+		{Kind: LayerFlags, Path: "", Required: false, Override: true},
+	}
+}
+
+// FileError ошибка чтения или декодирования файла конфигурации
+// Line и Column заполняются, если парсер сообщил позицию ошибки
+type FileError struct {
+	Path   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *FileError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d:%d: %v", e.Path, e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// newFileError оборачивает ошибку парсера, извлекая позицию если она есть
+func newFileError(path string, err error) *FileError {
+	fe := &FileError{Path: path, Err: err}
+	var pos interface{ Position() (int, int) }
+	if errors.As(err, &pos) {
+		fe.Line, fe.Column = pos.Position()
+	}
+	return fe
+}
+
+// Load загружает конфигурацию текущего окружения и сохраняет её для Get()
+// Порядок мержа задаётся слоями (LoadOptions.Layers, по умолчанию DefaultLayers()):
+//   - value.toml (optional)
+//   - config_{env}.toml (required)
+//   - config_{env}.{region}.toml (region overlay, optional)
+//   - override.toml (optional, current env only)
+//   - override_{env}.toml (optional, current env only)
+//   - config_local.toml (optional, current env only)
+//   - .env (dotenv, optional, current env only)
+//   - environment variables
+//   - command-line flags (BindFlags)
+//
+// Файлы других окружений не читаются, если не включён AllEnvironments
 func Load(opts *LoadOptions) (*Config, error) {
+	opts = withDefaults(opts)
+	target := resolveTarget(opts)
+
+	var configs map[Target]*Config
+	var sources map[Target]map[string]Source
+	if opts.AllEnvironments {
+		all, allSrc, err := loadAll(opts)
+		if err != nil {
+			return nil, err
+		}
+		configs, sources = all, allSrc
+	} else {
+		cfg, src, err := loadEnvironment(opts, target, true)
+		if err != nil {
+			return nil, err
+		}
+		configs = map[Target]*Config{target: cfg}
+		sources = map[Target]map[string]Source{target: src}
+	}
+
+	current := configs[target]
+	if current == nil {
+		return nil, fmt.Errorf("конфиг для окружения %q не найден", target)
+	}
+
+	configMu.Lock()
+	allConfigs = configs
+	allSources = sources
+	currentTarget = target
+	configMu.Unlock()
+
+	return current, nil
+}
+
+// LoadAll загружает конфиги всех окружений (каждый файл слоя окружения, например config_*.toml)
+// и их региональных оверлеев; ключ — окружение + регион (Region пуст для конфига без оверлея)
+// Предназначен для утилит и тестов: глобальное состояние Get()/GetAll() не меняется
+// Для текущего окружения применяются те же переопределения, что и в Load
+// Ошибки всех битых файлов собираются в одну (errors.Join из *FileError)
+func LoadAll(opts *LoadOptions) (map[Target]*Config, error) {
+	configs, _, err := loadAll(opts)
+	return configs, err
+}
+
+// loadAll загружает все окружения вместе с происхождением значений
+func loadAll(opts *LoadOptions) (map[Target]*Config, map[Target]map[string]Source, error) {
+	opts = withDefaults(opts)
+	current := resolveTarget(opts)
+
+	targets, err := discoverTargets(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	configs := make(map[Target]*Config, len(targets))
+	sources := make(map[Target]map[string]Source, len(targets))
+	var errs []error
+	for _, t := range targets {
+		cfg, src, err := loadEnvironment(opts, t, t == current)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		configs[t] = cfg
+		sources[t] = src
+	}
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+
+	return configs, sources, nil
+}
+
+// withDefaults возвращает опции по умолчанию, если opts == nil
+func withDefaults(opts *LoadOptions) *LoadOptions {
 	if opts == nil {
-		opts = &LoadOptions{
+		return &LoadOptions{
 			ConfigDir:      "./configs",
 			EnableOverride: true,
 		}
 	}
+	return opts
+}
 
-	env := opts.Environment
-	if env == "" {
+// resolveTarget определяет окружение и регион из опций или переменных APP_ENV и APP_REGION
+func resolveTarget(opts *LoadOptions) Target {
+	t := Target{Env: opts.Environment, Region: opts.Region}
+	if t.Env == "" {
 		envStr := os.Getenv("APP_ENV")
 		if envStr == "" {
 			envStr = "dev"
 		}
-		env = Environment(envStr)
+		t.Env = Environment(envStr)
+	}
+	if t.Region == "" {
+		t.Region = os.Getenv("APP_REGION")
+	}
+	return t
+}
+
+// loadEnvironment загружает конфиг одного окружения (и региона), применяя слои по порядку
+// Override-слои применяются только к текущему окружению (current=true)
+// Вместе с конфигом возвращает происхождение каждого ключа
+func loadEnvironment(opts *LoadOptions, t Target, current bool) (*Config, map[string]Source, error) {
+	env := t.Env
+	k := koanf.New(".")
+	applied := make(map[string]bool)
+	sources := make(map[string]Source) // ключ -> слой, задавший его последним
+
+	for _, l := range opts.layers() {
+		if l.Override && !current {
+			continue
+		}
+
+		switch l.Kind {
+		case LayerFile:
+			if l.Override && !opts.EnableOverride {
+				continue
+			}
+			if l.IsOverlay() && t.Region == "" {
+				continue
+			}
+			path := layerPath(opts.ConfigDir, l.Path, t)
+			// config_local.toml может совпасть с config_{env}.toml при env=local
+			if applied[path] {
+				continue
+			}
+			if !fileExists(path) {
+				if l.Required {
+					return nil, nil, fmt.Errorf("конфиг для окружения %q не найден: %s", env, path)
+				}
+				continue
+			}
+			var files []*configFile
+			if l.IsEnvFile() {
+				// Файл окружения: сначала родители по цепочке extends
+				chain, err := envChain(opts.ConfigDir, l.Path, env)
+				if err != nil {
+					return nil, nil, err
+				}
+				files = chain
+			} else {
+				cf, err := readConfigFile(path)
+				if err != nil {
+					return nil, nil, err
+				}
+				if cf.Extends != "" {
+					return nil, nil, &FileError{Path: path, Err: fmt.Errorf("extends поддерживается только в файлах окружений")}
+				}
+				files = append(files, cf)
+			}
+			for _, cf := range files {
+				if err := k.Load(mapProvider(cf.Values), nil); err != nil {
+					return nil, nil, newFileError(cf.Path, err)
+				}
+				for key, pos := range cf.Sources {
+					sources[key] = Source{Layer: l, File: pos.Path, Line: pos.Line}
+				}
+			}
+			applied[path] = true
+
+		case LayerDotEnv:
+			if !opts.EnableEnv {
+				continue
+			}
+			path := layerPath(opts.ConfigDir, l.Path, t)
+			if !fileExists(path) {
+				if l.Required {
+					return nil, nil, fmt.Errorf("файл %s не найден", path)
+				}
+				continue
+			}
+			vars, err := readDotEnv(path)
+			if err != nil {
+				return nil, nil, err
+			}
+			names := make([]string, 0, len(vars))
+			for name := range vars {
+				names = append(names, name)
+			}
+			if err := checkUnknownEnv(opts, path, names); err != nil {
+				return nil, nil, err
+			}
+			set, err := applyEnv(k, func(name string) (string, bool) {
+				v, ok := vars[name]
+				return v, ok
+			})
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", path, err)
+			}
+			for key, name := range set {
+				sources[key] = Source{Layer: l, File: path, Name: name}
+			}
+
+		case LayerEnv:
+			if !opts.EnableEnv {
+				continue
+			}
+			var names []string
+			for _, kv := range os.Environ() {
+				name, _, _ := strings.Cut(kv, "=")
+				names = append(names, name)
+			}
+			if err := checkUnknownEnv(opts, "", names); err != nil {
+				return nil, nil, err
+			}
+			set, err := applyEnv(k, os.LookupEnv)
+			if err != nil {
+				return nil, nil, fmt.Errorf("переменные окружения: %w", err)
+			}
+			for key, name := range set {
+				sources[key] = Source{Layer: l, Name: name}
+			}
+
+		case LayerFlags:
+			set, err := applyFlags(k, opts.Flags)
+			if err != nil {
+				return nil, nil, fmt.Errorf("флаги командной строки: %w", err)
+			}
+			for _, key := range set {
+				sources[key] = Source{Layer: l, Name: "-" + key}
+			}
+		}
+	}
+
+	if err := checkUnknownKeys(opts, k, sources); err != nil {
+		return nil, nil, fmt.Errorf("конфиг %s: %w", t, err)
+	}
+
+	if err := interpolate(k, sources); err != nil {
+		return nil, nil, fmt.Errorf("подстановки %s: %w", t, err)
+	}
+
+	cfg := &Config{}
+	if err := k.UnmarshalWithConf("", cfg, koanf.UnmarshalConf{Tag: "toml"}); err != nil {
+		return nil, nil, fmt.Errorf("декодирование конфига %s: %w", t, err)
+	}
+	cfg.Env = env
+	cfg.Region = t.Region
+	return cfg, sources, nil
+}
+
+// keyInfo описание ключа схемы
+type keyInfo struct {
+	Type    string // Go тип поля
+	Help    string // Комментарий из TOML (справка флага)
+	Default string // Значение из TOML, если одинаково во всех окружениях
+}
+
+// configKeys ключи схемы; по ним подставленные значения приводятся к типу поля
+var configKeys = map[string]keyInfo{
+	"app.name":                {Type: "string", Help: "Название сервиса", Default: "my-service"},
+	"app.version":             {Type: "string", Help: "Версия приложения", Default: "1.0.0"},
+	"db.dsn":                  {Type: "string", Help: "Строка подключения, собирается из значений выше"},
+	"db.host":                 {Type: "string", Help: "Хост базы данных"},
+	"db.max_idle_time":        {Type: "time.Duration", Help: "Время жизни неактивного соединения", Default: "5m"},
+	"db.name":                 {Type: "string", Help: "Имя базы данных"},
+	"db.password":             {Type: "string", Help: "Пароль (в dev можно хранить в конфиге)"},
+	"db.pool_size":            {Type: "int", Help: "Размер пула соединений", Default: "5"},
+	"db.port":                 {Type: "int", Help: "Порт PostgreSQL", Default: "5432"},
+	"db.user":                 {Type: "string", Help: "Пользователь БД", Default: "dev_user"},
+	"features.enable_metrics": {Type: "bool", Help: "Включить сбор метрик", Default: "true"},
+	"features.enable_tracing": {Type: "bool", Help: "Включить трейсинг", Default: "true"},
+	"limits.max_connections":  {Type: "int", Help: "Максимальное количество одновременных соединений", Default: "1000"},
+	"limits.max_request_size": {Type: "int", Help: "Максимальный размер запроса в байтах (10MB)", Default: "10485760"},
+	"limits.request_timeout":  {Type: "time.Duration", Help: "Таймаут обработки запроса", Default: "30s"},
+	"log.format":              {Type: "string", Help: "Формат вывода: text или json", Default: "text"},
+	"log.level":               {Type: "string", Help: "Уровень логирования: debug, info, warn, error", Default: "debug"},
+	"redis.db":                {Type: "int", Help: "Номер базы данных", Default: "0"},
+	"redis.host":              {Type: "string", Help: "Хост Redis", Default: "localhost"},
+	"redis.port":              {Type: "int", Help: "Порт Redis", Default: "6379"},
+	"server.allowed_origins":  {Type: "[]string", Help: "Разрешённые CORS источники", Default: "https://example.com"},
+	"server.host":             {Type: "string", Help: "Адрес для прослушивания (IP пода, если задан)"},
+	"server.port":             {Type: "int", Help: "Порт сервера"},
+	"server.read_timeout":     {Type: "time.Duration", Help: "Таймаут на чтение запроса", Default: "5s"},
+	"server.write_timeout":    {Type: "time.Duration", Help: "Таймаут на запись ответа", Default: "10s"},
+}
+
+// BoundFlags значения ключей конфига, заданные флагами командной строки
+// Создаётся BindFlags (BindPFlags) и передаётся в Load через LoadOptions.Flags
+type BoundFlags struct {
+	mu     sync.Mutex
+	values map[string]string // ключ -> строка из командной строки
+}
+
+// BindFlags регистрирует флаг -section.key для каждого ключа конфига
+// Тип, справка и значение по умолчанию берутся из схемы и комментариев TOML
+// Заданные флаги применяются слоем $flags (последним в DefaultLayers) при Load:
+//
+//	flags := config.BindFlags(flag.CommandLine)
+//	flag.Parse()
+//	cfg := config.MustLoad(&config.LoadOptions{ConfigDir: "./configs", EnableOverride: true, Flags: flags})
+func BindFlags(fs *flag.FlagSet) *BoundFlags {
+	b := &BoundFlags{values: make(map[string]string)}
+	for _, key := range flagKeys() {
+		info := configKeys[key]
+		fs.Var(&flagValue{flags: b, key: key, info: info}, key, flagUsage(info))
+	}
+	return b
+}
+
+// snapshot возвращает копию заданных значений; nil-safe
+func (b *BoundFlags) snapshot() map[string]string {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	values := make(map[string]string, len(b.values))
+	for key, s := range b.values {
+		values[key] = s
+	}
+	return values
+}
+
+// flagKeys возвращает ключи конфига в порядке регистрации флагов
+func flagKeys() []string {
+	keys := make([]string, 0, len(configKeys))
+	for key := range configKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// flagUsage возвращает справку флага; тип в обратных кавычках flag показывает как имя аргумента
+func flagUsage(info keyInfo) string {
+	help := info.Help
+	if help == "" {
+		help = "значение конфига"
+	}
+	if info.Type == "bool" {
+		return help
+	}
+	return help + " (`" + info.Type + "`)"
+}
+
+// flagValue значение флага ключа конфига: проверяет тип и сохраняет строку для слоя $flags
+type flagValue struct {
+	flags *BoundFlags
+	key   string
+	info  keyInfo
+}
+
+func (v *flagValue) String() string {
+	if v == nil || v.flags == nil {
+		return ""
+	}
+	v.flags.mu.Lock()
+	defer v.flags.mu.Unlock()
+	if s, ok := v.flags.values[v.key]; ok {
+		return s
+	}
+	return v.info.Default
+}
+
+func (v *flagValue) Set(s string) error {
+	if _, err := convertValue(v.info.Type, s); err != nil {
+		return err
+	}
+	v.flags.mu.Lock()
+	defer v.flags.mu.Unlock()
+	v.flags.values[v.key] = s
+	return nil
+}
+
+// IsBoolFlag позволяет писать -features.enable_metrics без значения
+func (v *flagValue) IsBoolFlag() bool {
+	return v.info.Type == "bool"
+}
+
+// Type возвращает тип значения (для pflag)
+func (v *flagValue) Type() string {
+	return v.info.Type
+}
+
+// applyFlags применяет заданные флаги командной строки и возвращает их ключи
+func applyFlags(k *koanf.Koanf, flags *BoundFlags) ([]string, error) {
+	values := flags.snapshot()
+
+	var set []string
+	var errs []error
+	for _, key := range sortedStrings(values) {
+		v, err := convertValue(configKeys[key].Type, values[key])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("-%s: %w", key, err))
+			continue
+		}
+		if err := k.Set(key, v); err != nil {
+			errs = append(errs, fmt.Errorf("-%s: %w", key, err))
+			continue
+		}
+		set = append(set, key)
+	}
+	return set, errors.Join(errs...)
+}
+
+// sortedStrings возвращает отсортированные ключи map
+func sortedStrings(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkUnknownKeys сообщает о ключах конфига, которых нет в сгенерированной схеме
+func checkUnknownKeys(opts *LoadOptions, k *koanf.Koanf, sources map[string]Source) error {
+	if opts.StrictKeys == StrictIgnore {
+		return nil
+	}
+
+	var errs []error
+	for _, key := range k.Keys() {
+		if _, ok := configKeys[key]; ok {
+			continue
+		}
+		msg := key + ": неизвестный ключ"
+		if s := suggestKey(key); s != "" {
+			msg += " (возможно, " + s + ")"
+		}
+		if src, ok := sources[key]; ok {
+			msg = src.String() + ": " + msg
+		}
+		errs = append(errs, errors.New(msg))
 	}
 
-	valuePath := filepath.Join(opts.ConfigDir, "value.toml")
-	hasValue := fileExists(valuePath)
+	if opts.StrictKeys == StrictWarn {
+		for _, err := range errs {
+			opts.warn(err)
+		}
+		return nil
+	}
+	return errors.Join(errs...)
+}
 
-	// Находим все config_*.toml (каждый = отдельное окружение)
-	matches, err := filepath.Glob(filepath.Join(opts.ConfigDir, "config_*.toml"))
+// suggestKey возвращает ближайший ключ схемы или пусто
+func suggestKey(key string) string {
+	best, bestDist := "", 4
+	for known := range configKeys {
+		if d := levenshtein(key, known); d < bestDist || d == bestDist && known < best {
+			best, bestDist = known, d
+		}
+	}
+	return best
+}
+
+// levenshtein расстояние редактирования между строками
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// interpolate раскрывает подстановки в строковых значениях после мержа всех слоёв:
+//   - ${VAR} — переменная окружения (имя из A-Z, 0-9 и _)
+//   - ${section.key} — значение другого ключа конфига
+//   - ${VAR:-default} — значение по умолчанию, если переменная не задана или пуста (или ключа нет)
+//   - $${...} — экранирование, даёт литерал ${...}
+//
+// Строка из одной подстановки ключа получает его значение с исходным типом,
+// остальные результаты приводятся к типу поля из схемы
+func interpolate(k *koanf.Koanf, sources map[string]Source) error {
+	in := &interpolator{
+		values:  k.All(),
+		done:    make(map[string]any),
+		changed: make(map[string]bool),
+		failed:  make(map[string]bool),
+	}
+	keys := make([]string, 0, len(in.values))
+	for key := range in.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		// Ошибка ключа уже вошла в ошибку ссылающегося на него значения
+		if in.failed[key] {
+			continue
+		}
+		v, err := in.resolve(key)
+		if err != nil {
+			if src, ok := sources[key]; ok {
+				err = fmt.Errorf("%s: %w", src, err)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if in.changed[key] {
+			if err := k.Set(key, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// interpolator раскрывает подстановки с запоминанием результатов и обнаружением циклов
+type interpolator struct {
+	values  map[string]any  // Плоские значения конфига (section.key -> value)
+	done    map[string]any  // Раскрытые значения
+	changed map[string]bool // Значение содержало подстановки
+	failed  map[string]bool // Ключи с ошибкой
+	stack   []string        // Текущая цепочка ссылок
+}
+
+// resolve возвращает значение ключа с раскрытыми подстановками
+func (in *interpolator) resolve(key string) (any, error) {
+	if v, ok := in.done[key]; ok {
+		return v, nil
+	}
+	for i, s := range in.stack {
+		if s == key {
+			return nil, fmt.Errorf("цикл подстановок: %s -> %s", strings.Join(in.stack[i:], " -> "), key)
+		}
+	}
+	in.stack = append(in.stack, key)
+	defer func() { in.stack = in.stack[:len(in.stack)-1] }()
+
+	v, changed, err := in.resolveValue(key, in.values[key])
 	if err != nil {
-		return nil, fmt.Errorf("поиск конфигов: %w", err)
+		in.failed[key] = true
+		return nil, err
+	}
+	in.done[key] = v
+	in.changed[key] = changed
+	return v, nil
+}
+
+// resolveValue раскрывает строку или строковые элементы массива
+func (in *interpolator) resolveValue(key string, raw any) (any, bool, error) {
+	switch val := raw.(type) {
+	case string:
+		if !strings.Contains(val, "${") {
+			return val, false, nil
+		}
+		expanded, err := in.expand(val)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", key, err)
+		}
+		converted, err := convertValue(configKeys[key].Type, expanded)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %q: %w", key, val, err)
+		}
+		return converted, true, nil
+
+	case []any:
+		items := make([]any, len(val))
+		changed := false
+		for i, item := range val {
+			items[i] = item
+			s, ok := item.(string)
+			if !ok || !strings.Contains(s, "${") {
+				continue
+			}
+			expanded, err := in.expand(s)
+			if err != nil {
+				return nil, false, fmt.Errorf("%s[%d]: %w", key, i, err)
+			}
+			items[i] = expanded
+			changed = true
+		}
+		return items, changed, nil
 	}
+	return raw, false, nil
+}
 
-	configs := make(map[Environment]*Config)
+// expand раскрывает подстановки в строке
+func (in *interpolator) expand(s string) (any, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if strings.HasPrefix(s[i:], "$${") {
+			b.WriteString("${")
+			i += 3
+			continue
+		}
+		if !strings.HasPrefix(s[i:], "${") {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("незакрытая подстановка")
+		}
+		name, def, hasDef := strings.Cut(s[i+2:i+end], ":-")
+		v, ok, err := in.lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		if str, isStr := v.(string); !ok || (hasDef && isStr && str == "") {
+			if !hasDef {
+				if isEnvName(name) {
+					return nil, fmt.Errorf("переменная окружения %s не задана", name)
+				}
+				return nil, fmt.Errorf("ключ %s не задан", name)
+			}
+			v = def
+		}
+		if i == 0 && end+1 == len(s) {
+			return v, nil
+		}
+		b.WriteString(fmt.Sprint(v))
+		i += end + 1
+	}
+	return b.String(), nil
+}
 
-	needReload := opts.EnableOverride || opts.EnableEnv
+// lookup возвращает значение переменной окружения или ключа конфига
+func (in *interpolator) lookup(name string) (any, bool, error) {
+	if isEnvName(name) {
+		v, ok := os.LookupEnv(name)
+		return v, ok, nil
+	}
+	if _, ok := in.values[name]; !ok {
+		return nil, false, nil
+	}
+	v, err := in.resolve(name)
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
 
+// isEnvName возвращает true для имени переменной окружения (POD_IP), а не пути ключа (db.host)
+func isEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// convertValue приводит строку к Go типу поля; значения других типов возвращаются как есть
+// Длительности остаются строками, их разбирает декодер конфига
+func convertValue(typ string, v any) (any, error) {
+	s, ok := v.(string)
+	if !ok {
+		if typ == "string" {
+			return fmt.Sprint(v), nil
+		}
+		return v, nil
+	}
+
+	switch typ {
+	case "int":
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("ожидался int, получено %q", s)
+		}
+		return n, nil
+	case "float64":
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("ожидался float64, получено %q", s)
+		}
+		return f, nil
+	case "bool":
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("ожидался bool, получено %q", s)
+		}
+		return b, nil
+	case "time.Duration":
+		if _, err := time.ParseDuration(s); err != nil {
+			return nil, fmt.Errorf("ожидалась длительность, получено %q", s)
+		}
+		return s, nil
+	}
+
+	if item, ok := strings.CutPrefix(typ, "[]"); ok {
+		items := []any{}
+		if s == "" {
+			return items, nil
+		}
+		for _, part := range strings.Split(s, ",") {
+			c, err := convertValue(item, strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, c)
+		}
+		return items, nil
+	}
+	return s, nil
+}
+
+// Source происхождение значения ключа
+type Source struct {
+	Layer Layer  // Слой, задавший значение последним
+	File  string // Файл (TOML или .env); пусто для переменных окружения и флагов
+	Line  int    // Строка в файле, 0 если неизвестна
+	Name  string // Переменная окружения (APP_DB__HOST) или флаг (-db.host)
+}
+
+// String возвращает config_prod.toml:12, .env (APP_DB__HOST), env APP_DB__HOST или flag -db.host
+func (s Source) String() string {
+	switch {
+	case s.File != "" && s.Name != "":
+		return s.File + " (" + s.Name + ")"
+	case s.File != "" && s.Line > 0:
+		return fmt.Sprintf("%s:%d", s.File, s.Line)
+	case s.File != "":
+		return s.File
+	case s.Layer.Kind == LayerFlags:
+		return "flag " + s.Name
+	default:
+		return "env " + s.Name
+	}
+}
+
+// layers возвращает слои из опций или DefaultLayers()
+func (o *LoadOptions) layers() []Layer {
+	if o.Layers != nil {
+		return o.Layers
+	}
+	return DefaultLayers()
+}
+
+// layerPath подставляет окружение и регион в путь слоя
+func layerPath(dir, pattern string, t Target) string {
+	path := strings.ReplaceAll(pattern, "{env}", string(t.Env))
+	path = strings.ReplaceAll(path, "{region}", t.Region)
+	return filepath.Join(dir, path)
+}
+
+// discoverTargets находит окружения по файлам слоёв окружения (config_{env}.toml)
+// и их региональные оверлеи (config_{env}.{region}.toml)
+// Файлы, которые сами являются слоями (config_local.toml), окружениями не считаются,
+// кроме текущего окружения: при окружении local его файл — config_local.toml
+// Текущее окружение с регионом добавляется, даже если файла оверлея нет
+func discoverTargets(opts *LoadOptions) ([]Target, error) {
+	reserved := make(map[string]bool)
+	for _, l := range opts.layers() {
+		if l.Kind == LayerFile && !strings.Contains(l.Path, "{") {
+			reserved[filepath.Join(opts.ConfigDir, l.Path)] = true
+		}
+	}
+
+	var envs []Environment
+	for _, l := range opts.layers() {
+		if !l.IsEnvFile() {
+			continue
+		}
+		names, err := globPlaceholder(opts.ConfigDir, l.Path, "{env}", reserved)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			envs = append(envs, Environment(name))
+		}
+	}
+
+	seen := make(map[Target]bool)
+	var targets []Target
+	add := func(t Target) {
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+	for _, env := range envs {
+		add(Target{Env: env})
+		for _, l := range opts.layers() {
+			if !l.IsOverlay() {
+				continue
+			}
+			pattern := strings.ReplaceAll(l.Path, "{env}", string(env))
+			regions, err := globPlaceholder(opts.ConfigDir, pattern, "{region}", reserved)
+			if err != nil {
+				return nil, err
+			}
+			for _, region := range regions {
+				add(Target{Env: env, Region: region})
+			}
+		}
+	}
+
+	// Текущее окружение может совпадать с файлом слоя (config_local.toml при окружении local)
+	current := resolveTarget(opts)
+	if !seen[Target{Env: current.Env}] && hasEnvFile(opts, current.Env) {
+		add(Target{Env: current.Env})
+	}
+	if seen[Target{Env: current.Env}] {
+		add(current)
+	}
+	return targets, nil
+}
+
+// hasEnvFile сообщает, есть ли у окружения файл хотя бы одного слоя окружения
+func hasEnvFile(opts *LoadOptions, env Environment) bool {
+	for _, l := range opts.layers() {
+		if l.IsEnvFile() && fileExists(layerPath(opts.ConfigDir, l.Path, Target{Env: env})) {
+			return true
+		}
+	}
+	return false
+}
+
+// globPlaceholder находит файлы по шаблону и возвращает значения плейсхолдера
+func globPlaceholder(dir, pattern, placeholder string, reserved map[string]bool) ([]string, error) {
+	prefix, suffix, _ := strings.Cut(pattern, placeholder)
+	matches, err := filepath.Glob(filepath.Join(dir, prefix+"*"+suffix))
+	if err != nil {
+		return nil, fmt.Errorf("поиск конфигов: %w", err)
+	}
+
+	var names []string
 	for _, match := range matches {
-		envName := envFromFilename(filepath.Base(match))
+		if reserved[match] {
+			continue
+		}
+		rel, err := filepath.Rel(dir, match)
+		if err != nil {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.ToSlash(rel), prefix), suffix)
+		if name == "" || strings.ContainsAny(name, "./") {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// envBinding переменная окружения для ключа или секции конфига
+type envBinding struct {
+	Key     string   // section.key
+	Env     string   // Имя переменной
+	Section bool     // Секция целиком: значение — JSON объект
+	Aliases []string // Имена из директивы # env: (DATABASE_URL), проверяются после Env по порядку
+}
+
+// envBindings переменные окружения всех ключей и секций схемы
+// Секция идёт перед своими ключами, поэтому отдельная переменная ключа переопределяет JSON секции
+var envBindings = []envBinding{
+	{Key: "app", Env: "APP_APP", Section: true},
+	{Key: "app.name", Env: "APP_APP__NAME"},
+	{Key: "app.version", Env: "APP_APP__VERSION"},
+	{Key: "db", Env: "APP_DB", Section: true},
+	{Key: "db.dsn", Env: "APP_DB__DSN", Aliases: []string{"DATABASE_URL", "PG_URL"}},
+	{Key: "db.host", Env: "APP_DB__HOST"},
+	{Key: "db.max_idle_time", Env: "APP_DB__MAX_IDLE_TIME"},
+	{Key: "db.name", Env: "APP_DB__NAME"},
+	{Key: "db.password", Env: "APP_DB__PASSWORD"},
+	{Key: "db.pool_size", Env: "APP_DB__POOL_SIZE"},
+	{Key: "db.port", Env: "APP_DB__PORT"},
+	{Key: "db.user", Env: "APP_DB__USER"},
+	{Key: "features", Env: "APP_FEATURES", Section: true},
+	{Key: "features.enable_metrics", Env: "APP_FEATURES__ENABLE_METRICS"},
+	{Key: "features.enable_tracing", Env: "APP_FEATURES__ENABLE_TRACING"},
+	{Key: "limits", Env: "APP_LIMITS", Section: true},
+	{Key: "limits.max_connections", Env: "APP_LIMITS__MAX_CONNECTIONS"},
+	{Key: "limits.max_request_size", Env: "APP_LIMITS__MAX_REQUEST_SIZE"},
+	{Key: "limits.request_timeout", Env: "APP_LIMITS__REQUEST_TIMEOUT"},
+	{Key: "log", Env: "APP_LOG", Section: true},
+	{Key: "log.format", Env: "APP_LOG__FORMAT"},
+	{Key: "log.level", Env: "APP_LOG__LEVEL"},
+	{Key: "redis", Env: "APP_REDIS", Section: true},
+	{Key: "redis.db", Env: "APP_REDIS__DB"},
+	{Key: "redis.host", Env: "APP_REDIS__HOST"},
+	{Key: "redis.port", Env: "APP_REDIS__PORT"},
+	{Key: "server", Env: "APP_SERVER", Section: true},
+	{Key: "server.allowed_origins", Env: "APP_SERVER__ALLOWED_ORIGINS"},
+	{Key: "server.host", Env: "APP_SERVER__HOST"},
+	{Key: "server.port", Env: "APP_SERVER__PORT", Aliases: []string{"PORT", "APP_HTTP_PORT"}},
+	{Key: "server.read_timeout", Env: "APP_SERVER__READ_TIMEOUT"},
+	{Key: "server.write_timeout", Env: "APP_SERVER__WRITE_TIMEOUT"},
+}
+
+// applyEnv применяет заданные переменные окружения к конфигу, разбирая значения по типу поля
+// Для каждого ключа берётся первая заданная переменная: сначала APP_SECTION__KEY, затем
+// имена из # env: в порядке перечисления. Возвращает применённые ключи с именами переменных,
+// ошибки всех переменных собираются в одну
+func applyEnv(k *koanf.Koanf, lookup func(string) (string, bool)) (map[string]string, error) {
+	set := make(map[string]string) // ключ -> переменная
+	var errs []error
+	for _, b := range envBindings {
+		name, raw, ok := lookupBinding(b, lookup)
+		if !ok {
+			continue
+		}
+		values, err := envValues(b, raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		for key, v := range values {
+			if err := k.Set(key, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				continue
+			}
+			set[key] = name
+		}
+	}
+	return set, errors.Join(errs...)
+}
+
+// checkUnknownEnv сообщает о переменных с префиксом APP_, не соответствующих ни одному ключу
+// source — путь .env файла (пусто для переменных процесса)
+func checkUnknownEnv(opts *LoadOptions, source string, names []string) error {
+	if opts.StrictEnv == StrictIgnore {
+		return nil
+	}
+
+	known := map[string]bool{
+		"APP_ENV":    true,
+		"APP_REGION": true,
+	}
+	for _, b := range envBindings {
+		known[b.Env] = true
+		for _, name := range b.Aliases {
+			known[name] = true
+		}
+	}
 
-		if needReload && Environment(envName) == env {
+	sort.Strings(names)
+	var errs []error
+	for _, name := range names {
+		if !strings.HasPrefix(name, "APP_") || known[name] {
 			continue
 		}
+		msg := name + ": неизвестная переменная окружения"
+		if s := suggestEnv(name); s != "" {
+			msg += " (возможно, " + s + ")"
+		}
+		if source != "" {
+			msg = source + ": " + msg
+		}
+		errs = append(errs, errors.New(msg))
+	}
+
+	if opts.StrictEnv == StrictWarn {
+		for _, err := range errs {
+			opts.warn(err)
+		}
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// suggestEnv возвращает ближайшее известное имя переменной или пусто
+func suggestEnv(name string) string {
+	best, bestDist := "", 4
+	for _, b := range envBindings {
+		if d := levenshtein(name, b.Env); d < bestDist {
+			best, bestDist = b.Env, d
+		}
+	}
+	return best
+}
+
+// lookupBinding возвращает первую заданную переменную привязки
+func lookupBinding(b envBinding, lookup func(string) (string, bool)) (string, string, bool) {
+	if v, ok := lookup(b.Env); ok {
+		return b.Env, v, true
+	}
+	for _, name := range b.Aliases {
+		if v, ok := lookup(name); ok {
+			return name, v, true
+		}
+	}
+	return "", "", false
+}
 
-		cfg, err := loadSingle(valuePath, hasValue, match)
+// envValues разбирает значение переменной в ключи конфига
+func envValues(b envBinding, raw string) (map[string]any, error) {
+	if !b.Section {
+		v, err := parseEnvValue(configKeys[b.Key].Type, raw)
 		if err != nil {
 			return nil, err
 		}
-		cfg.Env = Environment(envName)
-		configs[cfg.Env] = cfg
+		return map[string]any{b.Key: v}, nil
 	}
 
-	// Для текущего окружения: value.toml → config_{env}.toml → override.toml → env vars
-	if needReload {
-		envPath := filepath.Join(opts.ConfigDir, fmt.Sprintf("config_%s.toml", env))
-		if fileExists(envPath) {
-			k := koanf.New(".")
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(raw), &obj); err != nil {
+		return nil, fmt.Errorf("ожидался JSON объект секции %s: %v", b.Key, err)
+	}
+	flat := make(map[string]any)
+	flattenJSON(b.Key, obj, flat)
 
-			if hasValue {
-				if err := k.Load(file.Provider(valuePath), toml.Parser()); err != nil {
-					return nil, fmt.Errorf("загрузка value.toml: %w", err)
-				}
+	out := make(map[string]any, len(flat))
+	for key, v := range flat {
+		info, ok := configKeys[key]
+		if !ok {
+			return nil, fmt.Errorf("неизвестный ключ %s", key)
+		}
+		if s, ok := v.(string); ok {
+			parsed, err := parseEnvValue(info.Type, s)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
 			}
+			v = parsed
+		}
+		out[key] = v
+	}
+	return out, nil
+}
 
-			if err := k.Load(file.Provider(envPath), toml.Parser()); err != nil {
-				return nil, fmt.Errorf("загрузка config_%s.toml: %w", env, err)
-			}
+// flattenJSON раскладывает вложенный JSON объект в плоские ключи section.key
+func flattenJSON(prefix string, obj map[string]any, out map[string]any) {
+	for k, v := range obj {
+		key := prefix + "." + k
+		if m, ok := v.(map[string]any); ok {
+			flattenJSON(key, m, out)
+			continue
+		}
+		out[key] = v
+	}
+}
 
-			if opts.EnableOverride {
-				overridePath := filepath.Join(opts.ConfigDir, "override.toml")
-				if fileExists(overridePath) {
-					if err := k.Load(file.Provider(overridePath), toml.Parser()); err != nil {
-						return nil, fmt.Errorf("загрузка override.toml: %w", err)
-					}
+// parseEnvValue разбирает строку из переменной окружения по Go типу поля
+// Списки: a,b,c или JSON массив ["a","b"]
+func parseEnvValue(typ, s string) (any, error) {
+	item, isSlice := strings.CutPrefix(typ, "[]")
+	if !isSlice || !strings.HasPrefix(strings.TrimSpace(s), "[") {
+		return convertValue(typ, s)
+	}
+
+	var items []any
+	if err := json.Unmarshal([]byte(s), &items); err != nil {
+		return nil, fmt.Errorf("ожидался JSON массив %s: %v", typ, err)
+	}
+	for i, it := range items {
+		str, ok := it.(string)
+		if !ok {
+			str = fmt.Sprint(it)
+		}
+		c, err := convertValue(item, str)
+		if err != nil {
+			return nil, fmt.Errorf("элемент %d: %w", i, err)
+		}
+		items[i] = c
+	}
+	return items, nil
+}
+
+// readDotEnv читает .env файл: KEY=VALUE, комментарии #, необязательный export и кавычки
+func readDotEnv(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, newFileError(path, err)
+	}
+
+	vars := make(map[string]string)
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, &FileError{Path: path, Line: i + 1, Column: 1, Err: fmt.Errorf("ожидалось KEY=VALUE")}
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		vars[strings.TrimSpace(name)] = value
+	}
+	return vars, nil
+}
+
+// configFile TOML файл конфигурации с отделёнными директивами configgen
+type configFile struct {
+	Path    string
+	Values  map[string]any          // Значения с подключёнными include
+	Extends string                  // Окружение-родитель (extends = "prod" или [configgen] extends)
+	Sources map[string]filePosition // Файл и строка каждого листового ключа (с учётом include)
+}
+
+// filePosition место ключа в файле
+type filePosition struct {
+	Path string
+	Line int // 0, если строку определить не удалось
+}
+
+// readConfigFile читает TOML файл, ошибки оборачиваются в *FileError
+// Файлы из include мержатся первыми, собственные значения файла переопределяют их
+func readConfigFile(path string) (*configFile, error) {
+	return readConfigFileStack(path, nil)
+}
+
+// readConfigFileStack читает файл, stack — цепочка include для обнаружения циклов
+func readConfigFileStack(path string, stack []string) (*configFile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, newFileError(path, err)
+	}
+	values, err := toml.Parser().Unmarshal(b)
+	if err != nil {
+		return nil, newFileError(path, err)
+	}
+
+	cf := &configFile{Path: path, Values: values}
+	lines := keyLines(b)
+	includes, err := extractDirectives(cf, lines)
+	if err != nil {
+		return nil, err
+	}
+
+	own := make(map[string]filePosition)
+	recordPositions(own, "", values, path, lines)
+
+	if len(includes) == 0 {
+		cf.Sources = own
+		return cf, nil
+	}
+
+	stack = append(stack, path)
+	merged := make(map[string]any)
+	positions := make(map[string]filePosition)
+	for _, pattern := range includes {
+		full := pattern
+		if !filepath.IsAbs(full) {
+			full = filepath.Join(filepath.Dir(path), pattern)
+		}
+		paths := []string{full}
+		if strings.ContainsAny(pattern, "*?[") {
+			matches, err := filepath.Glob(full)
+			if err != nil {
+				return nil, &FileError{Path: path, Err: fmt.Errorf("include %q: %w", pattern, err)}
+			}
+			sort.Strings(matches)
+			paths = matches
+		}
+		for _, p := range paths {
+			for _, seen := range stack {
+				if filepath.Clean(seen) == filepath.Clean(p) {
+					return nil, &FileError{Path: path, Err: fmt.Errorf("цикл include: %s -> %s", strings.Join(stack, " -> "), p)}
 				}
 			}
+			inc, err := readConfigFileStack(p, stack)
+			if err != nil {
+				return nil, err
+			}
+			mergeMaps(merged, inc.Values)
+			for key, pos := range inc.Sources {
+				positions[key] = pos
+			}
+		}
+	}
+	mergeMaps(merged, values)
+	for key, pos := range own {
+		positions[key] = pos
+	}
+	cf.Values = merged
+	cf.Sources = positions
+	return cf, nil
+}
 
-			if opts.EnableEnv {
-				envPrefix := "APP_"
-				if err := k.Load(kenv.Provider(envPrefix, ".", func(s string) string {
-					// APP_SERVER__HOST -> server.host
-					// APP_DB__MAX_IDLE_TIME -> db.max_idle_time
-					s = strings.TrimPrefix(s, envPrefix)
-					s = strings.ToLower(s)
-					s = strings.ReplaceAll(s, "__", ".")
-					return s
-				}), nil); err != nil {
-					return nil, fmt.Errorf("загрузка env vars: %w", err)
-				}
+// recordPositions запоминает место каждого листового ключа; для ключей inline-таблиц
+// берётся строка ближайшего родителя
+func recordPositions(out map[string]filePosition, prefix string, values map[string]any, path string, lines map[string]int) {
+	for k, v := range values {
+		key := prefix + k
+		if m, ok := v.(map[string]any); ok {
+			recordPositions(out, key+".", m, path, lines)
+			continue
+		}
+		pos := filePosition{Path: path}
+		for p := key; p != ""; {
+			if line, ok := lines[p]; ok {
+				pos.Line = line
+				break
 			}
+			i := strings.LastIndex(p, ".")
+			if i < 0 {
+				break
+			}
+			p = p[:i]
+		}
+		out[key] = pos
+	}
+}
 
-			cfg := &Config{}
-			if err := k.UnmarshalWithConf("", cfg, koanf.UnmarshalConf{Tag: "toml"}); err != nil {
-				return nil, fmt.Errorf("декодирование конфига: %w", err)
+// keyLines находит строки, на которых заданы ключи: [section] и key = value
+// Разбор упрощённый, только для сообщений и Sources()
+func keyLines(b []byte) map[string]int {
+	lines := make(map[string]int)
+	section := ""
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			name := strings.TrimLeft(line, "[")
+			if j := strings.Index(name, "]"); j >= 0 {
+				name = name[:j]
 			}
-			cfg.Env = env
-			configs[env] = cfg
+			section = strings.ReplaceAll(strings.ReplaceAll(name, "\"", ""), " ", "")
+			lines[section] = i + 1
+			continue
+		}
+		key, _, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ReplaceAll(strings.ReplaceAll(strings.TrimSpace(key), "\"", ""), " ", "")
+		if section != "" {
+			key = section + "." + key
+		}
+		if _, seen := lines[key]; !seen {
+			lines[key] = i + 1
 		}
 	}
+	return lines
+}
 
-	current := configs[env]
-	if current == nil {
-		return nil, fmt.Errorf("конфиг для окружения %q не найден", env)
+// extractDirectives удаляет из значений директивы configgen, сохраняет extends и возвращает шаблоны include
+// Синтаксис тот же, что проверяет генератор: extends, include и секция [configgen]
+func extractDirectives(cf *configFile, lines map[string]int) ([]string, error) {
+	directiveError := func(key string, err error) error {
+		fe := &FileError{Path: cf.Path, Err: err}
+		if line, ok := lines[key]; ok {
+			fe.Line, fe.Column = line, 1
+		}
+		return fe
 	}
 
-	configMu.Lock()
-	allConfigs = configs
-	currentEnv = env
-	configMu.Unlock()
+	var includes []string
+	if v, ok := cf.Values["extends"]; ok {
+		s, ok := v.(string)
+		if !ok {
+			return nil, directiveError("extends", fmt.Errorf("extends должен быть строкой, получен %T", v))
+		}
+		cf.Extends = s
+		delete(cf.Values, "extends")
+	}
 
-	return current, nil
-}
+	if v, ok := cf.Values["include"]; ok {
+		list, err := directiveList("include", v)
+		if err != nil {
+			return nil, directiveError("include", err)
+		}
+		includes = append(includes, list...)
+		delete(cf.Values, "include")
+	}
 
-// loadSingle загружает один конфиг: value.toml + config_{env}.toml
-func loadSingle(valuePath string, hasValue bool, envPath string) (*Config, error) {
-	k := koanf.New(".")
+	if v, ok := cf.Values["configgen"]; ok {
+		section, ok := v.(map[string]any)
+		if !ok {
+			return nil, directiveError("configgen", fmt.Errorf("configgen должен быть секцией, получен %T", v))
+		}
+		for key, val := range section {
+			switch key {
+			case "extends":
+				s, ok := val.(string)
+				if !ok {
+					return nil, directiveError("configgen.extends", fmt.Errorf("configgen.extends должен быть строкой, получен %T", val))
+				}
+				if cf.Extends != "" && cf.Extends != s {
+					return nil, directiveError("configgen.extends", fmt.Errorf("extends задан дважды: %q и %q", cf.Extends, s))
+				}
+				cf.Extends = s
+			case "include":
+				list, err := directiveList("configgen.include", val)
+				if err != nil {
+					return nil, directiveError("configgen.include", err)
+				}
+				includes = append(includes, list...)
+			default:
+				return nil, directiveError("configgen."+key, fmt.Errorf("неизвестная директива configgen.%s", key))
+			}
+		}
+		delete(cf.Values, "configgen")
+	}
 
-	if hasValue {
-		if err := k.Load(file.Provider(valuePath), toml.Parser()); err != nil {
-			return nil, fmt.Errorf("загрузка value.toml: %w", err)
+	return includes, nil
+}
+
+// directiveList приводит значение директивы к списку строк (строка или массив строк)
+func directiveList(name string, v any) ([]string, error) {
+	switch val := v.(type) {
+	case string:
+		return []string{val}, nil
+	case []any:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s должен содержать строки, получен %T", name, item)
+			}
+			out = append(out, s)
 		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("%s должен быть строкой или массивом строк, получен %T", name, v)
 	}
+}
 
-	if err := k.Load(file.Provider(envPath), toml.Parser()); err != nil {
-		return nil, fmt.Errorf("загрузка %s: %w", filepath.Base(envPath), err)
+// mergeMaps рекурсивно мержит src в dst, значения src переопределяют dst
+func mergeMaps(dst, src map[string]any) {
+	for k, v := range src {
+		if sm, ok := v.(map[string]any); ok {
+			if dm, ok := dst[k].(map[string]any); ok {
+				mergeMaps(dm, sm)
+				continue
+			}
+			cp := make(map[string]any, len(sm))
+			mergeMaps(cp, sm)
+			dst[k] = cp
+			continue
+		}
+		dst[k] = v
 	}
+}
 
-	cfg := &Config{}
-	if err := k.UnmarshalWithConf("", cfg, koanf.UnmarshalConf{Tag: "toml"}); err != nil {
-		return nil, fmt.Errorf("декодирование %s: %w", filepath.Base(envPath), err)
+// envChain читает файл окружения и его родителей по extends, от корневого родителя к env
+func envChain(dir, pattern string, env Environment) ([]*configFile, error) {
+	var chain []*configFile
+	var seen []string
+	for cur := env; cur != ""; {
+		for _, name := range seen {
+			if name == string(cur) {
+				return nil, fmt.Errorf("цикл extends: %s -> %s", strings.Join(seen, " -> "), cur)
+			}
+		}
+		path := layerPath(dir, pattern, Target{Env: cur})
+		if !fileExists(path) {
+			return nil, &FileError{Path: chain[0].Path, Err: fmt.Errorf("extends = %q: файл %s не найден", cur, path)}
+		}
+		cf, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		seen = append(seen, string(cur))
+		chain = append([]*configFile{cf}, chain...)
+		cur = Environment(cf.Extends)
 	}
-	return cfg, nil
+	return chain, nil
+}
+
+// mapProvider отдаёт koanf уже распарсенную map
+type mapProvider map[string]any
+
+func (m mapProvider) ReadBytes() ([]byte, error) {
+	return nil, errors.New("mapProvider не поддерживает ReadBytes")
 }
 
-func envFromFilename(name string) string {
-	return strings.TrimSuffix(strings.TrimPrefix(name, "config_"), ".toml")
+func (m mapProvider) Read() (map[string]any, error) {
+	return m, nil
 }
 
 func fileExists(path string) bool {
@@ -193,23 +1582,70 @@ func MustLoad(opts *LoadOptions) *Config {
 func Get() *Config {
 	configMu.RLock()
 	defer configMu.RUnlock()
-	return allConfigs[currentEnv]
+	return allConfigs[currentTarget]
 }
 
 // GetEnv возвращает текущее окружение
 func GetEnv() Environment {
 	configMu.RLock()
 	defer configMu.RUnlock()
-	return currentEnv
+	return currentTarget.Env
 }
 
-// GetAll возвращает конфиги всех окружений
+// GetRegion возвращает текущий регион (пусто, если оверлей не выбран)
+func GetRegion() string {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return currentTarget.Region
+}
+
+// GetAll возвращает загруженные конфиги окружений для текущего региона
+// Содержит все окружения только если Load вызван с AllEnvironments=true
 func GetAll() map[Environment]*Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	out := make(map[Environment]*Config)
+	for t, cfg := range allConfigs {
+		if t.Region == "" {
+			if _, ok := out[t.Env]; !ok {
+				out[t.Env] = cfg
+			}
+		}
+	}
+	for t, cfg := range allConfigs {
+		if t.Region != "" && t.Region == currentTarget.Region {
+			out[t.Env] = cfg
+		}
+	}
+	return out
+}
+
+// GetAllTargets возвращает загруженные конфиги с ключом окружение + регион
+func GetAllTargets() map[Target]*Config {
 	configMu.RLock()
 	defer configMu.RUnlock()
 	return allConfigs
 }
 
+// Sources возвращает происхождение всех ключей текущего конфига (section.key -> Source)
+func Sources() map[string]Source {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	out := make(map[string]Source, len(allSources[currentTarget]))
+	for key, src := range allSources[currentTarget] {
+		out[key] = src
+	}
+	return out
+}
+
+// SourceOf возвращает происхождение значения ключа текущего конфига: SourceOf("db.host")
+func SourceOf(key string) (Source, bool) {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	src, ok := allSources[currentTarget][key]
+	return src, ok
+}
+
 // IsProduction возвращает true если работаем в production
 func IsProduction() bool {
 	return GetEnv() == EnvProduction
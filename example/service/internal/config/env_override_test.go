@@ -1,7 +1,11 @@
 package config_test
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"example/service/internal/config"
 )
@@ -13,7 +17,7 @@ func TestEnvVarOverride(t *testing.T) {
 
 	cfg, err := config.Load(&config.LoadOptions{
 		ConfigDir:   "../../configs",
-		Environment: config.EnvLocal,
+		Environment: config.EnvStaging,
 		EnableEnv:   true,
 	})
 	if err != nil {
@@ -38,7 +42,7 @@ func TestEnvVarOverrideDisabled(t *testing.T) {
 
 	cfg, err := config.Load(&config.LoadOptions{
 		ConfigDir:   "../../configs",
-		Environment: config.EnvLocal,
+		Environment: config.EnvStaging,
 		EnableEnv:   false,
 	})
 	if err != nil {
@@ -53,3 +57,159 @@ func TestEnvVarOverrideDisabled(t *testing.T) {
 		t.Errorf("expected value from config file, got %s", cfg.Server.Host)
 	}
 }
+
+func TestDotEnvLayer(t *testing.T) {
+	dir := copyConfigs(t)
+	dotenv := "# локальные переменные\nexport APP_SERVER__HOST=\"dotenv.local\"\nAPP_DB__NAME=dotenv_db\nOTHER=ignored\n"
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(dotenv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("APP_DB__NAME", "env_db")
+
+	cfg, err := config.Load(&config.LoadOptions{
+		ConfigDir:   dir,
+		Environment: config.EnvStaging,
+		EnableEnv:   true,
+	})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.Server.Host != "dotenv.local" {
+		t.Errorf("expected host from .env, got %s", cfg.Server.Host)
+	}
+	if cfg.Db.Name != "env_db" {
+		t.Errorf("env vars should win over .env, got %s", cfg.Db.Name)
+	}
+}
+
+func TestEnvVarTypedValues(t *testing.T) {
+	t.Setenv("APP_SERVER__ALLOWED_ORIGINS", "https://a.example, https://b.example")
+	t.Setenv("APP_SERVER__READ_TIMEOUT", "7s")
+	t.Setenv("APP_FEATURES__ENABLE_METRICS", "0")
+	t.Setenv("APP_REDIS", `{"host":"redis.internal","port":6380}`)
+	t.Setenv("APP_REDIS__PORT", "6381")
+
+	cfg, err := config.Load(&config.LoadOptions{
+		ConfigDir:   "../../configs",
+		Environment: config.EnvStaging,
+		EnableEnv:   true,
+	})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if len(cfg.Server.AllowedOrigins) != 2 || cfg.Server.AllowedOrigins[1] != "https://b.example" {
+		t.Errorf("expected comma list from env, got %v", cfg.Server.AllowedOrigins)
+	}
+	if cfg.Server.ReadTimeout != 7*time.Second {
+		t.Errorf("expected duration from env, got %v", cfg.Server.ReadTimeout)
+	}
+	if cfg.Features.EnableMetrics {
+		t.Error("expected bool false from env")
+	}
+	if cfg.Redis.Host != "redis.internal" || cfg.Redis.Port != 6381 {
+		t.Errorf("expected section JSON with key override, got %s:%d", cfg.Redis.Host, cfg.Redis.Port)
+	}
+
+	t.Setenv("APP_SERVER__ALLOWED_ORIGINS", `["https://json.example"]`)
+	cfg, err = config.Load(&config.LoadOptions{ConfigDir: "../../configs", Environment: config.EnvStaging, EnableEnv: true})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.Server.AllowedOrigins) != 1 || cfg.Server.AllowedOrigins[0] != "https://json.example" {
+		t.Errorf("expected JSON list from env, got %v", cfg.Server.AllowedOrigins)
+	}
+}
+
+func TestEnvVarTypeErrors(t *testing.T) {
+	t.Setenv("APP_SERVER__PORT", "eighty")
+	t.Setenv("APP_SERVER__READ_TIMEOUT", "soon")
+
+	_, err := config.Load(&config.LoadOptions{
+		ConfigDir:   "../../configs",
+		Environment: config.EnvStaging,
+		EnableEnv:   true,
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, want := range []string{"APP_SERVER__PORT: ожидался int", "APP_SERVER__READ_TIMEOUT: ожидалась длительность"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should contain %q, got: %v", want, err)
+		}
+	}
+}
+
+func TestEnvVarExplicitNames(t *testing.T) {
+	t.Setenv("PG_URL", "postgres://pg-url")
+	t.Setenv("PORT", "7070")
+
+	cfg, err := config.Load(&config.LoadOptions{
+		ConfigDir:   "../../configs",
+		Environment: config.EnvStaging,
+		EnableEnv:   true,
+	})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Db.Dsn != "postgres://pg-url" || cfg.Server.Port != 7070 {
+		t.Errorf("explicit env names not applied: dsn=%s port=%d", cfg.Db.Dsn, cfg.Server.Port)
+	}
+
+	// Имена из # env: проверяются по порядку, префиксная переменная важнее всех
+	t.Setenv("DATABASE_URL", "postgres://database-url")
+	t.Setenv("APP_SERVER__PORT", "6060")
+	cfg, err = config.Load(&config.LoadOptions{ConfigDir: "../../configs", Environment: config.EnvStaging, EnableEnv: true})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Db.Dsn != "postgres://database-url" || cfg.Server.Port != 6060 {
+		t.Errorf("precedence not respected: dsn=%s port=%d", cfg.Db.Dsn, cfg.Server.Port)
+	}
+}
+
+func TestStrictEnv(t *testing.T) {
+	t.Setenv("APP_SERVER__PORTT", "8081")
+	t.Setenv("APP_TOTALLY_UNRELATED_SETTING", "1")
+	t.Setenv("APP_ENV", "stg")
+	t.Setenv("APP_REGION", "")
+	t.Setenv("APP_HTTP_PORT", "8082") // Имя из # env: с префиксом — не опечатка
+
+	_, err := config.Load(&config.LoadOptions{
+		ConfigDir: "../../configs",
+		EnableEnv: true,
+		StrictEnv: config.StrictError,
+	})
+	if err == nil {
+		t.Fatal("expected error for unknown env vars")
+	}
+	for _, want := range []string{
+		"APP_SERVER__PORTT: неизвестная переменная окружения (возможно, APP_SERVER__PORT)",
+		"APP_TOTALLY_UNRELATED_SETTING: неизвестная переменная окружения",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should contain %q, got: %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "APP_ENV") || strings.Contains(err.Error(), "APP_REGION") {
+		t.Errorf("APP_ENV and APP_REGION are not config keys but must not be reported: %v", err)
+	}
+	if strings.Contains(err.Error(), "APP_HTTP_PORT") {
+		t.Errorf("prefixed alias from # env: must not be reported: %v", err)
+	}
+
+	var warnings []error
+	_, err = config.Load(&config.LoadOptions{
+		ConfigDir: "../../configs",
+		EnableEnv: true,
+		StrictEnv: config.StrictWarn,
+		OnWarning: func(err error) { warnings = append(warnings, err) },
+	})
+	if err != nil {
+		t.Fatalf("StrictWarn should not fail: %v", err)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("expected 2 warnings, got %v", warnings)
+	}
+}
@@ -0,0 +1,115 @@
+package config_test
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+
+	"example/service/internal/config"
+)
+
+func TestBindFlags(t *testing.T) {
+	fs := flag.NewFlagSet("service", flag.ContinueOnError)
+	flags := config.BindFlags(fs)
+	if err := fs.Parse([]string{"-server.port=7777", "-features.enable_metrics=false", "-server.allowed_origins", "a.example,b.example"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	cfg, err := config.Load(&config.LoadOptions{ConfigDir: "../../configs", Environment: config.EnvStaging, Flags: flags})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Server.Port != 7777 || cfg.Features.EnableMetrics {
+		t.Errorf("flags not applied: port=%d metrics=%v", cfg.Server.Port, cfg.Features.EnableMetrics)
+	}
+	if len(cfg.Server.AllowedOrigins) != 2 {
+		t.Errorf("list flag not applied: %v", cfg.Server.AllowedOrigins)
+	}
+
+	// Values are kept in the returned BoundFlags: Load without them and another flag set see no flags
+	cfg, err = config.Load(&config.LoadOptions{ConfigDir: "../../configs", Environment: config.EnvStaging})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Server.Port == 7777 {
+		t.Error("flags leaked into Load without LoadOptions.Flags")
+	}
+	other := config.BindFlags(flag.NewFlagSet("other", flag.ContinueOnError))
+	cfg, err = config.Load(&config.LoadOptions{ConfigDir: "../../configs", Environment: config.EnvStaging, Flags: other})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Server.Port == 7777 {
+		t.Error("flags leaked into another flag set")
+	}
+}
+
+func TestBindPFlags(t *testing.T) {
+	fs := pflag.NewFlagSet("service", pflag.ContinueOnError)
+	flags := config.BindPFlags(fs)
+	if err := fs.Parse([]string{"--server.port=7777", "--features.enable_metrics=false", "--server.allowed_origins", "a.example,b.example", "--log.level=debug"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if f := fs.Lookup("server.port"); f == nil || f.Value.Type() != "int" {
+		t.Errorf("server.port flag should have type int, got %+v", f)
+	}
+
+	cfg, err := config.Load(&config.LoadOptions{ConfigDir: "../../configs", Environment: config.EnvStaging, Flags: flags})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Server.Port != 7777 || cfg.Features.EnableMetrics || cfg.Log.Level != "debug" {
+		t.Errorf("flags not applied: port=%d metrics=%v level=%s", cfg.Server.Port, cfg.Features.EnableMetrics, cfg.Log.Level)
+	}
+	if len(cfg.Server.AllowedOrigins) != 2 {
+		t.Errorf("list flag not applied: %v", cfg.Server.AllowedOrigins)
+	}
+
+	// A bool flag without a value means true
+	fs = pflag.NewFlagSet("service", pflag.ContinueOnError)
+	flags = config.BindPFlags(fs)
+	if err := fs.Parse([]string{"--features.enable_metrics"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	cfg, err = config.Load(&config.LoadOptions{ConfigDir: "../../configs", Environment: config.EnvStaging, Flags: flags})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !cfg.Features.EnableMetrics {
+		t.Error("--features.enable_metrics without a value should enable metrics")
+	}
+
+	fs = pflag.NewFlagSet("service", pflag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	config.BindPFlags(fs)
+	if err := fs.Parse([]string{"--server.port=eighty"}); err == nil {
+		t.Error("expected type error")
+	}
+}
+
+func TestBindFlagsHelp(t *testing.T) {
+	var out bytes.Buffer
+	fs := flag.NewFlagSet("service", flag.ContinueOnError)
+	fs.SetOutput(&out)
+	config.BindFlags(fs)
+
+	if err := fs.Parse([]string{"-server.port=eighty"}); err == nil {
+		t.Fatal("expected type error")
+	}
+	help := out.String()
+	for _, want := range []string{
+		"-server.port int",
+		"Порт сервера (int)",
+		"-server.allowed_origins []string",
+		"(default https://example.com)",
+		"ожидался int",
+	} {
+		if !strings.Contains(help, want) {
+			t.Errorf("help should contain %q, got:\n%s", want, help)
+		}
+	}
+}
@@ -0,0 +1,432 @@
+package config_test
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"example/service/internal/config"
+)
+
+// copyConfigs копирует конфиги примера во временную директорию
+func copyConfigs(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	matches, err := filepath.Glob("../../configs/*.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range matches {
+		b, err := os.ReadFile(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, filepath.Base(m)), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadIgnoresOtherEnvironments(t *testing.T) {
+	dir := copyConfigs(t)
+	broken := "[server]\nhost = \n"
+	if err := os.WriteFile(filepath.Join(dir, "config_qa.toml"), []byte(broken), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load(&config.LoadOptions{ConfigDir: dir, Environment: config.EnvProduction})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Env != config.EnvProduction {
+		t.Errorf("expected prod, got %s", cfg.Env)
+	}
+	if n := len(config.GetAll()); n != 1 {
+		t.Errorf("GetAll() should contain only current env, got %d", n)
+	}
+}
+
+func TestLoadAllAggregatesErrors(t *testing.T) {
+	dir := copyConfigs(t)
+	if err := os.WriteFile(filepath.Join(dir, "config_qa.toml"), []byte("[server]\nhost = \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config_dev.toml"), []byte("port = 1\n[db\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := config.LoadAll(&config.LoadOptions{ConfigDir: dir, Environment: config.EnvProduction})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	for _, name := range []string{"config_qa.toml:2:", "config_dev.toml:2:"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error should mention %s, got: %v", name, err)
+		}
+	}
+
+	var fe *config.FileError
+	if !errors.As(err, &fe) {
+		t.Error("error should wrap *config.FileError")
+	}
+}
+
+func TestLoadAllEnvironments(t *testing.T) {
+	dir := copyConfigs(t)
+
+	_, err := config.Load(&config.LoadOptions{
+		ConfigDir:       dir,
+		Environment:     config.EnvStaging,
+		AllEnvironments: true,
+	})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	all := config.GetAll()
+	if all[config.EnvProduction] == nil || all[config.EnvStaging] == nil {
+		t.Errorf("GetAll() should contain prod and stg, got %d configs", len(all))
+	}
+}
+
+func TestLoadAllEnvironmentsLocal(t *testing.T) {
+	dir := copyConfigs(t)
+	b, err := os.ReadFile("../../configs/config_local.toml.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config_local.toml"), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// config_local.toml — слой переопределения, но для окружения local это его файл
+	cfg, err := config.Load(&config.LoadOptions{
+		ConfigDir:       dir,
+		Environment:     config.EnvLocal,
+		AllEnvironments: true,
+	})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Env != config.EnvLocal {
+		t.Errorf("expected local, got %s", cfg.Env)
+	}
+	all := config.GetAll()
+	if all[config.EnvLocal] == nil || all[config.EnvProduction] == nil {
+		t.Errorf("GetAll() should contain local and prod, got %d configs", len(all))
+	}
+}
+
+func TestLoadLayers(t *testing.T) {
+	dir := copyConfigs(t)
+	if err := os.WriteFile(filepath.Join(dir, "override_stg.toml"), []byte("[server]\nport = 7001\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config_local.toml"), []byte("[db]\nname = \"local_db\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load(&config.LoadOptions{
+		ConfigDir:      dir,
+		Environment:    config.EnvStaging,
+		EnableOverride: true,
+	})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Server.Port != 7001 {
+		t.Errorf("expected port from override_stg.toml, got %d", cfg.Server.Port)
+	}
+	if cfg.Db.Name != "local_db" {
+		t.Errorf("expected db name from config_local.toml, got %s", cfg.Db.Name)
+	}
+
+	all, err := config.LoadAll(&config.LoadOptions{ConfigDir: dir, Environment: config.EnvStaging})
+	if err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+	if _, ok := all[config.Target{Env: config.EnvLocal}]; ok {
+		t.Error("config_local.toml is an override layer, not an environment")
+	}
+
+	cfg, err = config.Load(&config.LoadOptions{
+		ConfigDir:   dir,
+		Environment: config.EnvStaging,
+		Layers: []config.Layer{
+			{Kind: config.LayerFile, Path: "config_{env}.toml", Required: true},
+			{Kind: config.LayerFile, Path: "config_local.toml", Required: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Load with custom layers failed: %v", err)
+	}
+	if cfg.Db.Name != "local_db" || cfg.Server.Port == 7001 {
+		t.Errorf("custom layers not applied: db=%s port=%d", cfg.Db.Name, cfg.Server.Port)
+	}
+}
+
+func TestLoadExtends(t *testing.T) {
+	cfg, err := config.Load(&config.LoadOptions{ConfigDir: "../../configs", Environment: config.EnvStaging})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	// Собственные значения stg
+	if cfg.Server.Port != 8080 || cfg.Db.Name != "myapp_dev" {
+		t.Errorf("stg values not applied: port=%d db=%s", cfg.Server.Port, cfg.Db.Name)
+	}
+	// Унаследованные из prod
+	if cfg.Db.PoolSize != 5 || cfg.Log.Format != "text" {
+		t.Errorf("prod values not inherited: pool=%d format=%s", cfg.Db.PoolSize, cfg.Log.Format)
+	}
+}
+
+func TestLoadExtendsCycle(t *testing.T) {
+	dir := copyConfigs(t)
+	if err := os.WriteFile(filepath.Join(dir, "config_prod.toml"), []byte("extends = \"stg\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := config.Load(&config.LoadOptions{ConfigDir: dir, Environment: config.EnvStaging})
+	if err == nil || !strings.Contains(err.Error(), "цикл extends: stg -> prod -> stg") {
+		t.Errorf("expected extends cycle error, got: %v", err)
+	}
+}
+
+func TestLoadRegionOverlay(t *testing.T) {
+	cfg, err := config.Load(&config.LoadOptions{
+		ConfigDir:   "../../configs",
+		Environment: config.EnvProduction,
+		Region:      "eu",
+	})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Db.Host != "db.eu.internal" || cfg.Region != "eu" {
+		t.Errorf("eu overlay not applied: host=%s region=%s", cfg.Db.Host, cfg.Region)
+	}
+	if cfg.Db.Name != "myapp_prod" {
+		t.Errorf("prod values should stay under overlay, got db=%s", cfg.Db.Name)
+	}
+
+	t.Setenv("APP_REGION", "us")
+	cfg, err = config.Load(&config.LoadOptions{ConfigDir: "../../configs", Environment: config.EnvProduction})
+	if err != nil {
+		t.Fatalf("Load without overlay file failed: %v", err)
+	}
+	if cfg.Db.Host != "localhost" || config.GetRegion() != "us" {
+		t.Errorf("missing overlay should fall back to prod: host=%s region=%s", cfg.Db.Host, config.GetRegion())
+	}
+
+	all, err := config.LoadAll(&config.LoadOptions{ConfigDir: "../../configs", Environment: config.EnvProduction})
+	if err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+	eu := all[config.Target{Env: config.EnvProduction, Region: "eu"}]
+	if eu == nil || eu.Db.Host != "db.eu.internal" {
+		t.Error("LoadAll should key overlays by env+region")
+	}
+	if base := all[config.Target{Env: config.EnvProduction}]; base == nil || base.Db.Host != "localhost" {
+		t.Error("LoadAll should contain prod without overlay")
+	}
+}
+
+func TestLoadInclude(t *testing.T) {
+	dir := copyConfigs(t)
+	prod := "include = [\"parts/*.toml\"]\n\n[server]\nport = 8443\n"
+	if err := os.WriteFile(filepath.Join(dir, "config_qa.toml"), []byte(prod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "parts"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	parts := map[string]string{
+		"server.toml": "[server]\nhost = \"qa.internal\"\nport = 80\n",
+		"db.toml":     "[db]\nname = \"qa_db\"\n",
+	}
+	for name, content := range parts {
+		if err := os.WriteFile(filepath.Join(dir, "parts", name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := config.Load(&config.LoadOptions{ConfigDir: dir, Environment: "qa"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Server.Host != "qa.internal" || cfg.Db.Name != "qa_db" {
+		t.Errorf("included values not applied: host=%s db=%s", cfg.Server.Host, cfg.Db.Name)
+	}
+	if cfg.Server.Port != 8443 {
+		t.Errorf("own values should override includes, got port=%d", cfg.Server.Port)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "parts", "db.toml"), []byte("include = \"../config_qa.toml\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = config.Load(&config.LoadOptions{ConfigDir: dir, Environment: "qa"})
+	if err == nil || !strings.Contains(err.Error(), "цикл include") {
+		t.Errorf("expected include cycle error, got: %v", err)
+	}
+}
+
+func TestLoadIncludeDirectiveErrors(t *testing.T) {
+	// Те же ошибки, что сообщает генератор при разборе директив
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{content: "include = [\"db.toml\", 1]\n", want: "config_qa.toml:1:1: include должен содержать строки, получен int64"},
+		{content: "include = 1\n", want: "include должен быть строкой или массивом строк"},
+		{content: "[configgen]\ninclud = [\"db.toml\"]\n", want: "неизвестная директива configgen.includ"},
+	}
+	for _, tt := range tests {
+		dir := copyConfigs(t)
+		if err := os.WriteFile(filepath.Join(dir, "config_qa.toml"), []byte(tt.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := config.Load(&config.LoadOptions{ConfigDir: dir, Environment: "qa"})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: error = %v, want %q", tt.content, err, tt.want)
+		}
+		var fe *config.FileError
+		if !errors.As(err, &fe) {
+			t.Errorf("%q: error should wrap *config.FileError", tt.content)
+		}
+	}
+}
+
+func TestLoadInterpolation(t *testing.T) {
+	t.Setenv("POD_IP", "10.0.0.7")
+	cfg, err := config.Load(&config.LoadOptions{ConfigDir: "../../configs", Environment: config.EnvStaging})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if want := "postgres://dev_user@localhost:5432/myapp_dev"; cfg.Db.Dsn != want {
+		t.Errorf("dsn = %q, want %q", cfg.Db.Dsn, want)
+	}
+	if cfg.Server.Host != "10.0.0.7" {
+		t.Errorf("host should come from POD_IP, got %q", cfg.Server.Host)
+	}
+
+	dir := copyConfigs(t)
+	qa := `
+[server]
+host = "$${literal}"
+port = "${QA_PORT:-9090}"
+
+[db]
+name = "${db.user}"
+user = "${db.name}"
+`
+	if err := os.WriteFile(filepath.Join(dir, "config_qa.toml"), []byte(qa), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = config.Load(&config.LoadOptions{ConfigDir: dir, Environment: "qa"})
+	if err == nil || !strings.Contains(err.Error(), "цикл подстановок: db.name -> db.user -> db.name") {
+		t.Errorf("expected interpolation cycle error, got: %v", err)
+	}
+
+	qa = "[server]\nhost = \"$${literal}\"\nport = \"${QA_PORT:-9090}\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "config_qa.toml"), []byte(qa), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = config.Load(&config.LoadOptions{ConfigDir: dir, Environment: "qa"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Server.Host != "${literal}" || cfg.Server.Port != 9090 {
+		t.Errorf("escape/default not applied: host=%q port=%d", cfg.Server.Host, cfg.Server.Port)
+	}
+
+	t.Setenv("QA_PORT", "abc")
+	_, err = config.Load(&config.LoadOptions{ConfigDir: dir, Environment: "qa"})
+	if err == nil || !strings.Contains(err.Error(), "ожидался int") {
+		t.Errorf("expected type conversion error, got: %v", err)
+	}
+}
+
+func TestLoadStrictKeys(t *testing.T) {
+	dir := copyConfigs(t)
+	if err := os.WriteFile(filepath.Join(dir, "override.toml"), []byte("[db]\npool_sise = 10\n\n[cache]\nttl = \"1m\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := config.Load(&config.LoadOptions{
+		ConfigDir:      dir,
+		Environment:    config.EnvStaging,
+		EnableOverride: true,
+		StrictKeys:     config.StrictError,
+	})
+	if err == nil {
+		t.Fatal("expected error for unknown keys")
+	}
+	for _, want := range []string{
+		"override.toml:2: db.pool_sise: неизвестный ключ (возможно, db.pool_size)",
+		"override.toml:5: cache.ttl: неизвестный ключ",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should contain %q, got: %v", want, err)
+		}
+	}
+
+	var warnings []error
+	_, err = config.Load(&config.LoadOptions{
+		ConfigDir:      dir,
+		Environment:    config.EnvStaging,
+		EnableOverride: true,
+		StrictKeys:     config.StrictWarn,
+		OnWarning:      func(err error) { warnings = append(warnings, err) },
+	})
+	if err != nil {
+		t.Fatalf("StrictWarn should not fail: %v", err)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("expected 2 warnings, got %v", warnings)
+	}
+
+	if _, err := config.Load(&config.LoadOptions{ConfigDir: "../../configs", Environment: config.EnvProduction, StrictKeys: config.StrictError}); err != nil {
+		t.Errorf("example configs should match the schema: %v", err)
+	}
+}
+
+func TestSources(t *testing.T) {
+	t.Setenv("APP_REDIS__HOST", "redis.internal")
+
+	fs := flag.NewFlagSet("service", flag.ContinueOnError)
+	flags := config.BindFlags(fs)
+	if err := fs.Parse([]string{"-log.level=debug"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, err := config.Load(&config.LoadOptions{ConfigDir: "../../configs", Environment: config.EnvStaging, EnableEnv: true, Flags: flags}); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	for key, want := range map[string]string{
+		"app.name":    "value.toml:7",
+		"server.port": "config_stg.toml:7",
+		"db.port":     "config_prod.toml:22",
+		"redis.host":  "env APP_REDIS__HOST",
+		"log.level":   "flag -log.level",
+	} {
+		src, ok := config.SourceOf(key)
+		if !ok {
+			t.Errorf("SourceOf(%q): source not found", key)
+			continue
+		}
+		if !strings.HasSuffix(src.String(), want) {
+			t.Errorf("SourceOf(%q) = %q, want suffix %q", key, src, want)
+		}
+	}
+	if src := config.Sources()["server.port"]; !src.Layer.IsEnvFile() {
+		t.Errorf("server.port layer = %+v, want config_{env}.toml", src.Layer)
+	}
+	if _, ok := config.SourceOf("no.such.key"); ok {
+		t.Error("SourceOf should report unknown keys")
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"example/service/internal/config"
 )
 
-//go:generate go run github.com/vovanwin/configgen/cmd/configgen --configs=./configs --output=./internal/config --package=config
+//go:generate go run github.com/vovanwin/configgen/cmd/configgen
 
 func main() {
 	cfg, err := config.Load(&config.LoadOptions{
@@ -0,0 +1,223 @@
+// Package diag описывает диагностики configgen: ошибки и предупреждения с кодом,
+// уровнем и позицией в TOML файле, и их вывод для людей и CI
+package diag
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Коды диагностик
+const (
+	CodeGeneric   = "configgen" // Ошибка без позиции
+	CodeRead      = "read"      // Файл не читается
+	CodeSyntax    = "syntax"    // Синтаксическая ошибка TOML
+	CodeDirective = "directive" // Неверная директива (extends, include, [configgen], # env:)
+	CodeInclude   = "include"   // Ошибка include
+	CodeExtends   = "extends"   // Ошибка extends
+	CodeValue     = "value"     // Неподдерживаемое значение ключа
+	CodeFlag      = "flag"      // Ошибка в flags.toml
+	CodeSchema    = "schema"    // Схему нельзя построить
+	CodeStale     = "stale"     // Сгенерированный код устарел (--check)
+	CodeDrift     = "drift"     // Расхождение окружений, к коду добавляется тип: drift-missing
+	CodeProject   = "project"   // Ошибка в .configgen.toml
+)
+
+// Severity уровень диагностики
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText кодирует уровень строкой в JSON
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ParseSeverity разбирает уровень из флага: info, warning, error
+func ParseSeverity(s string) (Severity, error) {
+	for _, sev := range []Severity{SeverityInfo, SeverityWarning, SeverityError} {
+		if sev.String() == s {
+			return sev, nil
+		}
+	}
+	return 0, fmt.Errorf("неизвестный уровень %q (допустимы: info, warning, error)", s)
+}
+
+// Position позиция в файле; Line и Column начинаются с 1, 0 — неизвестно
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	switch {
+	case p.File == "":
+		return ""
+	case p.Line == 0:
+		return p.File
+	case p.Column == 0:
+		return fmt.Sprintf("%s:%d", p.File, p.Line)
+	default:
+		return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	}
+}
+
+// Diagnostic одна ошибка или предупреждение
+type Diagnostic struct {
+	Code     string   `json:"code"`
+	Severity Severity `json:"severity"`
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Key      string   `json:"key,omitempty"` // Путь ключа: db.host
+	Message  string   `json:"message"`
+}
+
+// Pos возвращает позицию диагностики
+func (d Diagnostic) Pos() Position {
+	return Position{File: d.File, Line: d.Line, Column: d.Column}
+}
+
+// String форматирует диагностику для человека: config_prod.toml:3:7: error: db.port: ... [syntax]
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	if pos := d.Pos().String(); pos != "" {
+		b.WriteString(pos + ": ")
+	}
+	b.WriteString(d.Severity.String() + ": ")
+	if d.Key != "" {
+		b.WriteString(d.Key + ": ")
+	}
+	b.WriteString(d.Message + " [" + d.Code + "]")
+	return b.String()
+}
+
+// Error ошибка с кодом и позицией, которую возвращает парсер
+type Error struct {
+	Code   string
+	File   string
+	Line   int
+	Column int
+	Key    string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	if pos := (Position{File: e.File, Line: e.Line, Column: e.Column}).String(); pos != "" {
+		b.WriteString(pos + ": ")
+	}
+	if e.Key != "" {
+		b.WriteString(e.Key + ": ")
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Diagnostic превращает ошибку в диагностику уровня error
+func (e *Error) Diagnostic() Diagnostic {
+	return Diagnostic{
+		Code:     e.Code,
+		Severity: SeverityError,
+		File:     e.File,
+		Line:     e.Line,
+		Column:   e.Column,
+		Key:      e.Key,
+		Message:  e.Err.Error(),
+	}
+}
+
+// List набор диагностик; как error — ошибки, собранные по всем файлам
+type List []Diagnostic
+
+func (l List) Error() string {
+	lines := make([]string, len(l))
+	for i, d := range l {
+		lines[i] = d.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Add добавляет диагностики ошибки err (nil игнорируется), повторы пропускаются
+func (l *List) Add(err error) {
+	for _, d := range FromError(err) {
+		if !slices.Contains(*l, d) {
+			*l = append(*l, d)
+		}
+	}
+}
+
+// Sort сортирует диагностики по файлу, строке и колонке
+func (l List) Sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		a, b := l[i], l[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// Err возвращает список как ошибку или nil, если он пуст
+func (l List) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// Count возвращает число диагностик уровня min и выше
+func (l List) Count(min Severity) int {
+	n := 0
+	for _, d := range l {
+		if d.Severity >= min {
+			n++
+		}
+	}
+	return n
+}
+
+// FromError извлекает диагностики из ошибки: List и *Error раскрываются,
+// остальные ошибки становятся диагностикой без позиции
+func FromError(err error) List {
+	if err == nil {
+		return nil
+	}
+	var list List
+	if errors.As(err, &list) {
+		return list
+	}
+	var de *Error
+	if errors.As(err, &de) {
+		return List{de.Diagnostic()}
+	}
+	return List{{Code: CodeGeneric, Severity: SeverityError, Message: err.Error()}}
+}
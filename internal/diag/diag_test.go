@@ -0,0 +1,145 @@
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFromError(t *testing.T) {
+	de := &Error{Code: CodeSyntax, File: "config_prod.toml", Line: 3, Column: 7, Key: "db.port", Err: errors.New("плохое значение")}
+	if got := de.Error(); got != "config_prod.toml:3:7: db.port: плохое значение" {
+		t.Errorf("Error() = %q", got)
+	}
+
+	list := FromError(fmt.Errorf("parse: %w", de))
+	want := Diagnostic{Code: CodeSyntax, Severity: SeverityError, File: "config_prod.toml", Line: 3, Column: 7, Key: "db.port", Message: "плохое значение"}
+	if len(list) != 1 || list[0] != want {
+		t.Errorf("FromError(*Error) = %+v", list)
+	}
+
+	var all List
+	all.Add(List{want, {Code: CodeRead, Severity: SeverityError, File: "value.toml", Message: "нет файла"}})
+	all.Add(de)
+	all.Add(errors.New("без позиции"))
+	all.Add(nil)
+	if len(all) != 3 {
+		t.Fatalf("ожидалось 3 диагностики без повторов, получено %d: %v", len(all), all)
+	}
+	if all[2].Code != CodeGeneric || all[2].File != "" {
+		t.Errorf("обычная ошибка: %+v", all[2])
+	}
+	if all.Count(SeverityError) != 3 || all.Count(SeverityWarning) != 3 {
+		t.Errorf("Count = %d", all.Count(SeverityError))
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	if s, err := ParseSeverity("warning"); err != nil || s != SeverityWarning {
+		t.Errorf("ParseSeverity(warning) = %v, %v", s, err)
+	}
+	if _, err := ParseSeverity("fatal"); err == nil {
+		t.Error("ожидалась ошибка для неизвестного уровня")
+	}
+}
+
+func testList() List {
+	return List{
+		{Code: CodeSyntax, Severity: SeverityError, File: "configs/config_prod.toml", Line: 3, Column: 7, Key: "db.port", Message: "ожидалось значение"},
+		{Code: "drift-missing", Severity: SeverityWarning, File: "configs/config_stg.toml", Key: "db.timeout", Message: "нет в stg, prod: 50%"},
+		{Code: CodeGeneric, Severity: SeverityInfo, Message: "строка 1\nстрока 2"},
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "text", testList()[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "configs/config_prod.toml:3:7: error: db.port: ожидалось значение [syntax]\n" {
+		t.Errorf("text: %q", got)
+	}
+}
+
+func TestWriteGitHub(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "github", testList()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"::error title=configgen syntax,file=configs/config_prod.toml,line=3,col=7::db.port: ожидалось значение",
+		"::warning title=configgen drift-missing,file=configs/config_stg.toml::db.timeout: нет в stg, prod: 50%25",
+		"::notice title=configgen configgen::строка 1%0Aстрока 2",
+	}
+	if got := strings.Split(strings.TrimSpace(buf.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("github:\n%s\nожидалось:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "sarif", testList()); err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine   int `json:"startLine"`
+							StartColumn int `json:"startColumn"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Version != "2.1.0" || len(report.Runs) != 1 || len(report.Runs[0].Results) != 3 {
+		t.Fatalf("неверная структура отчёта: %s", buf.String())
+	}
+	run := report.Runs[0]
+	if len(run.Tool.Driver.Rules) != 3 {
+		t.Errorf("ожидалось 3 правила, получено %+v", run.Tool.Driver.Rules)
+	}
+	first := run.Results[0]
+	loc := first.Locations[0].PhysicalLocation
+	if first.RuleID != CodeSyntax || first.Level != "error" || loc.ArtifactLocation.URI != "configs/config_prod.toml" || loc.Region.StartLine != 3 || loc.Region.StartColumn != 7 {
+		t.Errorf("первый результат: %+v", first)
+	}
+	if run.Results[2].Level != "note" || len(run.Results[2].Locations) != 0 {
+		t.Errorf("info без файла: %+v", run.Results[2])
+	}
+}
+
+func TestWriteJSONEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "json", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(strings.Fields(buf.String()), ""); got != `{"diagnostics":[]}` {
+		t.Errorf("пустой json: %s", got)
+	}
+	if err := Write(&buf, "xml", nil); err == nil {
+		t.Error("ожидалась ошибка для неизвестного формата")
+	}
+}
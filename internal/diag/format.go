@@ -0,0 +1,169 @@
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Formats поддерживаемые форматы вывода
+var Formats = []string{"text", "json", "sarif", "github"}
+
+// Write выводит диагностики в формате text, json, sarif или github
+func Write(w io.Writer, format string, list List) error {
+	switch format {
+	case "text":
+		for _, d := range list {
+			if _, err := fmt.Fprintln(w, d.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "json":
+		if list == nil {
+			list = List{}
+		}
+		return writeJSON(w, map[string]any{"diagnostics": list})
+	case "sarif":
+		return writeSARIF(w, list)
+	case "github":
+		return writeGitHub(w, list)
+	default:
+		return fmt.Errorf("неизвестный формат %q (допустимы: %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+func writeJSON(w io.Writer, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// sarifLevel уровень SARIF: error, warning, note
+func sarifLevel(s Severity) string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// writeSARIF выводит отчёт SARIF 2.1.0 для code scanning
+func writeSARIF(w io.Writer, list List) error {
+	type region struct {
+		StartLine   int `json:"startLine,omitempty"`
+		StartColumn int `json:"startColumn,omitempty"`
+	}
+	type physicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *region `json:"region,omitempty"`
+	}
+	type location struct {
+		PhysicalLocation physicalLocation `json:"physicalLocation"`
+	}
+	type message struct {
+		Text string `json:"text"`
+	}
+	type result struct {
+		RuleID    string     `json:"ruleId"`
+		Level     string     `json:"level"`
+		Message   message    `json:"message"`
+		Locations []location `json:"locations,omitempty"`
+	}
+	type rule struct {
+		ID string `json:"id"`
+	}
+
+	results := make([]result, 0, len(list))
+	codes := make(map[string]bool)
+	for _, d := range list {
+		codes[d.Code] = true
+		text := d.Message
+		if d.Key != "" {
+			text = d.Key + ": " + text
+		}
+		r := result{RuleID: d.Code, Level: sarifLevel(d.Severity), Message: message{Text: text}}
+		if d.File != "" {
+			var loc location
+			loc.PhysicalLocation.ArtifactLocation.URI = filepath.ToSlash(d.File)
+			if d.Line > 0 {
+				loc.PhysicalLocation.Region = &region{StartLine: d.Line, StartColumn: d.Column}
+			}
+			r.Locations = []location{loc}
+		}
+		results = append(results, r)
+	}
+
+	rules := make([]rule, 0, len(codes))
+	for code := range codes {
+		rules = append(rules, rule{ID: code})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+
+	return writeJSON(w, map[string]any{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []any{map[string]any{
+			"tool": map[string]any{
+				"driver": map[string]any{
+					"name":           "configgen",
+					"informationUri": "https://github.com/vovanwin/configgen",
+					"rules":          rules,
+				},
+			},
+			"results": results,
+		}},
+	})
+}
+
+// writeGitHub выводит аннотации GitHub Actions: ::error file=...,line=...::message
+func writeGitHub(w io.Writer, list List) error {
+	for _, d := range list {
+		level := "notice"
+		switch d.Severity {
+		case SeverityError:
+			level = "error"
+		case SeverityWarning:
+			level = "warning"
+		}
+		props := []string{"title=" + escapeProperty("configgen "+d.Code)}
+		if d.File != "" {
+			props = append(props, "file="+escapeProperty(filepath.ToSlash(d.File)))
+			if d.Line > 0 {
+				props = append(props, fmt.Sprintf("line=%d", d.Line))
+			}
+			if d.Column > 0 {
+				props = append(props, fmt.Sprintf("col=%d", d.Column))
+			}
+		}
+		text := d.Message
+		if d.Key != "" {
+			text = d.Key + ": " + text
+		}
+		if _, err := fmt.Fprintf(w, "::%s %s::%s\n", level, strings.Join(props, ","), escapeData(text)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// escapeData экранирует текст сообщения workflow-команды
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty экранирует значение свойства workflow-команды
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
@@ -2,11 +2,13 @@ package generator
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"go/format"
-	"os"
-	"path/filepath"
+	"go/scanner"
 	"sort"
 	"strconv"
 	"strings"
@@ -23,113 +25,233 @@ type Options struct {
 	OutputDir       string           // Директория для сгенерированных файлов
 	PackageName     string           // Имя пакета
 	EnvPrefix       string           // Префикс переменной окружения
+	RegionEnv       string           // Переменная окружения с регионом для оверлеев (по умолчанию APP_REGION)
 	WithLoader      bool             // Генерировать loader.gen.go
 	WithFlags       bool             // Генерировать flags файлы
 	FlagDefs        []*model.FlagDef // Определения feature flags
 	WithEnvOverride bool             // Включить env var override в loader
 	EnvVarPrefix    string           // Префикс для env vars (например, "APP_")
+	WithPFlag       bool             // Генерировать BindPFlags для github.com/spf13/pflag
+	Layers          []model.Layer    // Порядок слоёв в loader (nil = model.DefaultLayers)
+	Version         string           // Версия configgen для заголовка; пусто — заголовок не пишется
+	SchemaHash      string           // Хеш TOML файлов схемы (с include): заголовок config и loader
+	FlagsHash       string           // Хеш flags.toml: заголовок configgen_flags.go
 }
 
-// Generate генерирует config.gen.go и опционально loader.gen.go в указанную директорию
+// File сгенерированный файл
+type File struct {
+	Name    string // Имя файла в OutputDir: configgen_config.go
+	Content []byte
+}
+
+// Generate генерирует файлы в память и записывает их в OutputDir через Write:
+// при ошибке любого шаблона на диск не попадает ничего
 func Generate(opts Options, fields map[string]*model.Field) error {
-	if err := os.MkdirAll(opts.OutputDir, 0o755); err != nil {
-		return fmt.Errorf("создание директории: %w", err)
+	files, err := Render(opts, fields)
+	if err != nil {
+		return err
 	}
+	_, err = Write(opts.OutputDir, files)
+	return err
+}
 
-	if err := generateConfig(opts, fields); err != nil {
-		return err
+// Render генерирует файлы в памяти, не трогая OutputDir (для --check и --diff)
+func Render(opts Options, fields map[string]*model.Field) ([]File, error) {
+	if err := checkReferences(fields); err != nil {
+		return nil, err
 	}
 
-	if opts.WithLoader {
-		if err := generateLoader(opts, fields); err != nil {
+	var files []File
+	add := func(f File, err error) error {
+		if err != nil {
 			return err
 		}
+		files = append(files, f)
+		return nil
 	}
 
-	if opts.WithFlags && len(opts.FlagDefs) > 0 {
-		if err := generateFlags(opts); err != nil {
-			return err
+	if err := add(generateConfig(opts, fields)); err != nil {
+		return nil, err
+	}
+
+	if opts.WithLoader {
+		if opts.Layers == nil {
+			opts.Layers = model.DefaultLayers(opts.WithEnvOverride)
 		}
-		if err := generateFlagStore(opts); err != nil {
-			return err
+		if opts.RegionEnv == "" {
+			opts.RegionEnv = "APP_REGION"
 		}
-		if err := generateFlagTestHelpers(opts); err != nil {
-			return err
+		if err := validateLayers(opts); err != nil {
+			return nil, err
+		}
+		if opts.WithEnvOverride {
+			if _, err := model.EnvBindings(opts.EnvVarPrefix, fields); err != nil {
+				return nil, err
+			}
+		}
+		if err := add(generateLoader(opts, fields)); err != nil {
+			return nil, err
+		}
+		if opts.WithPFlag {
+			if err := add(generatePFlag(opts)); err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	return nil
+	if opts.WithFlags && len(opts.FlagDefs) > 0 {
+		for _, gen := range []func(Options) (File, error){generateFlags, generateFlagStore, generateFlagTestHelpers} {
+			if err := add(gen(opts)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return files, nil
 }
 
 // generateConfig генерирует config.gen.go
-func generateConfig(opts Options, fields map[string]*model.Field) error {
-	tmplB, err := templatesFS.ReadFile("templates/config.go.tmpl")
-	if err != nil {
-		return fmt.Errorf("чтение шаблона: %w", err)
+func generateConfig(opts Options, fields map[string]*model.Field) (File, error) {
+	data := map[string]any{
+		"Package":         opts.PackageName,
+		"Fields":          fields,
+		"Keys":            sortedKeys(fields),
+		"WithEnvOverride": opts.WithLoader && opts.WithEnvOverride,
+		"Header":          opts.header(opts.SchemaHash, opts.WithLoader && opts.WithEnvOverride),
 	}
+	return renderTemplate("cfg", "templates/config.go.tmpl", "configgen_config.go", data)
+}
 
-	tmpl, err := template.New("cfg").Funcs(templateFuncs()).Parse(string(tmplB))
-	if err != nil {
-		return fmt.Errorf("парсинг шаблона: %w", err)
+// generateLoader генерирует loader.gen.go
+func generateLoader(opts Options, fields map[string]*model.Field) (File, error) {
+	data := map[string]any{
+		"Package":         opts.PackageName,
+		"EnvPrefix":       opts.EnvPrefix,
+		"WithEnvOverride": opts.WithEnvOverride,
+		"EnvVarPrefix":    opts.EnvVarPrefix,
+		"Layers":          opts.Layers,
+		"RegionEnv":       opts.RegionEnv,
+		"Keys":            flattenKeys(fields),
+		"Header":          opts.header(opts.SchemaHash, opts.EnvPrefix, opts.RegionEnv, opts.WithEnvOverride, opts.EnvVarPrefix, opts.WithPFlag, opts.Layers),
+	}
+	if opts.WithEnvOverride {
+		bindings, err := model.EnvBindings(opts.EnvVarPrefix, fields)
+		if err != nil {
+			return File{}, err
+		}
+		data["EnvBindings"] = bindings
 	}
+	return renderTemplate("loader", "templates/loader.go.tmpl", "configgen_loader.go", data)
+}
 
-	keys := sortedKeys(fields)
-
-	buf := &bytes.Buffer{}
+// generatePFlag генерирует configgen_pflag.go с BindPFlags
+func generatePFlag(opts Options) (File, error) {
 	data := map[string]any{
 		"Package": opts.PackageName,
-		"Fields":  fields,
-		"Keys":    keys,
+		"Header":  opts.header(""),
 	}
+	return renderTemplate("pflag", "templates/pflag.go.tmpl", "configgen_pflag.go", data)
+}
 
-	if err := tmpl.Execute(buf, data); err != nil {
-		return fmt.Errorf("выполнение шаблона: %w", err)
+// validateLayers проверяет, что слои совместимы с остальными опциями генерации
+func validateLayers(opts Options) error {
+	hasEnvFile := false
+	for _, l := range opts.Layers {
+		switch l.Kind {
+		case model.LayerEnv, model.LayerDotEnv:
+			if !opts.WithEnvOverride {
+				return fmt.Errorf("слой %s требует --with-env-override", l)
+			}
+		}
+		if l.IsEnvFile() {
+			hasEnvFile = true
+		}
 	}
-
-	formatted, err := format.Source(buf.Bytes())
-	if err != nil {
-		outFile := filepath.Join(opts.OutputDir, "configgen_config.go")
-		_ = os.WriteFile(outFile, buf.Bytes(), 0o644)
-		return fmt.Errorf("форматирование кода: %w", err)
+	if !hasEnvFile {
+		return fmt.Errorf("нужен хотя бы один слой окружения с %s", model.EnvPlaceholder)
 	}
-
-	outFile := filepath.Join(opts.OutputDir, "configgen_config.go")
-	return os.WriteFile(outFile, formatted, 0o644)
+	return nil
 }
 
-// generateLoader генерирует loader.gen.go
-func generateLoader(opts Options, fields map[string]*model.Field) error {
-	tmplB, err := templatesFS.ReadFile("templates/loader.go.tmpl")
-	if err != nil {
-		return fmt.Errorf("чтение шаблона loader: %w", err)
-	}
+// keyData ключ схемы для таблицы configKeys в loader
+type keyData struct {
+	Path    string // section.key
+	Type    string // Go тип поля
+	Help    string // Комментарий из TOML в одну строку (справка флага)
+	Default string // Значение из TOML, если одинаково во всех окружениях; пусто для секретов
+}
 
-	tmpl, err := template.New("loader").Funcs(templateFuncs()).Parse(string(tmplB))
-	if err != nil {
-		return fmt.Errorf("парсинг шаблона loader: %w", err)
+// flattenKeys возвращает листовые ключи схемы в отсортированном порядке
+// Значение секретного ключа не становится умолчанием флага: оно попало бы в бинарник и в --help
+func flattenKeys(fields map[string]*model.Field) []keyData {
+	var out []keyData
+	var walk func(m map[string]*model.Field, prefix string)
+	walk = func(m map[string]*model.Field, prefix string) {
+		for _, k := range sortedKeys(m) {
+			f := m[k]
+			if f.Kind == model.KindObject {
+				walk(f.Children, prefix+k+".")
+				continue
+			}
+			key := keyData{
+				Path: prefix + k,
+				Type: goType(f),
+				Help: strings.Join(strings.Fields(f.Comment), " "),
+			}
+			if !model.IsSensitive(fields, key.Path) {
+				key.Default = f.Default
+			}
+			out = append(out, key)
+		}
 	}
+	walk(fields, "")
+	return out
+}
 
-	buf := &bytes.Buffer{}
-	data := map[string]any{
-		"Package":         opts.PackageName,
-		"EnvPrefix":       opts.EnvPrefix,
-		"WithEnvOverride": opts.WithEnvOverride,
-		"EnvVarPrefix":    opts.EnvVarPrefix,
+// checkReferences проверяет, что подстановки ${section.key} ссылаются на значения схемы
+func checkReferences(fields map[string]*model.Field) error {
+	var errs []string
+	var walk func(m map[string]*model.Field, prefix string)
+	walk = func(m map[string]*model.Field, prefix string) {
+		for _, k := range sortedKeys(m) {
+			f := m[k]
+			if f.Kind == model.KindObject {
+				walk(f.Children, prefix+k+".")
+				continue
+			}
+			for _, ref := range f.Refs {
+				target := model.Lookup(fields, ref)
+				switch {
+				case target == nil:
+					errs = append(errs, fmt.Sprintf("%s%s: ссылка ${%s} на несуществующий ключ", prefix, k, ref))
+				case target.Kind == model.KindObject:
+					errs = append(errs, fmt.Sprintf("%s%s: ссылка ${%s} указывает на секцию, а не на значение", prefix, k, ref))
+				}
+			}
+		}
 	}
+	walk(fields, "")
 
-	if err := tmpl.Execute(buf, data); err != nil {
-		return fmt.Errorf("выполнение шаблона loader: %w", err)
+	if len(errs) > 0 {
+		return fmt.Errorf("неверные подстановки:\n  %s", strings.Join(errs, "\n  "))
 	}
+	return nil
+}
 
-	formatted, err := format.Source(buf.Bytes())
-	if err != nil {
-		outFile := filepath.Join(opts.OutputDir, "configgen_loader.go")
-		_ = os.WriteFile(outFile, buf.Bytes(), 0o644)
-		return fmt.Errorf("форматирование loader кода: %w", err)
+// layerLiteral возвращает Go литерал слоя для сгенерированного DefaultLayers()
+func layerLiteral(l model.Layer) string {
+	var kind string
+	switch l.Kind {
+	case model.LayerDotEnv:
+		kind = "LayerDotEnv"
+	case model.LayerEnv:
+		kind = "LayerEnv"
+	case model.LayerFlags:
+		kind = "LayerFlags"
+	default:
+		kind = "LayerFile"
 	}
-
-	outFile := filepath.Join(opts.OutputDir, "configgen_loader.go")
-	return os.WriteFile(outFile, formatted, 0o644)
+	return fmt.Sprintf("{Kind: %s, Path: %q, Required: %t, Override: %t}", kind, l.Path, l.Required, l.Override)
 }
 
 // templateFuncs возвращает функции для использования в шаблонах
@@ -143,6 +265,8 @@ func templateFuncs() template.FuncMap {
 		"needsTime":     needsTime,
 		"formatComment": formatComment,
 		"hasComment":    hasComment,
+		"layerLiteral":  layerLiteral,
+		"join":          strings.Join,
 	}
 }
 
@@ -370,52 +494,89 @@ func flagDefaultLiteral(val any, kind model.FlagKind) string {
 	}
 }
 
-func generateFromTemplate(tmplName, tmplFile, outFile string, data map[string]any) error {
+// formatError ошибка gofmt сгенерированного кода, src — код до форматирования
+type formatError struct {
+	name string
+	src  []byte
+	err  error
+}
+
+func (e *formatError) Error() string {
+	// Файл не записывается, поэтому для отладки шаблона выводится строка с ошибкой
+	var list scanner.ErrorList
+	if errors.As(e.err, &list) && len(list) > 0 {
+		lines := strings.Split(string(e.src), "\n")
+		if n := list[0].Pos.Line; n > 0 && n <= len(lines) {
+			return fmt.Sprintf("форматирование %s: %v\n\t%d: %s", e.name, e.err, n, strings.TrimSpace(lines[n-1]))
+		}
+	}
+	return fmt.Sprintf("форматирование %s: %v", e.name, e.err)
+}
+
+func (e *formatError) Unwrap() error {
+	return e.err
+}
+
+// header строки заголовка с версией configgen и хешем входных данных одного файла:
+// inputs — хеш файлов, от которых он зависит, params — влияющие на него опции
+// Файл зависит только от своих входных данных, поэтому правка flags.toml не меняет заголовок config
+func (o Options) header(inputs string, params ...any) string {
+	if o.Version == "" {
+		return ""
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "inputs=%s package=%s\n", inputs, o.PackageName)
+	for _, p := range params {
+		fmt.Fprintf(h, "%v\n", p)
+	}
+	return fmt.Sprintf("%s%s\n// configgen inputs: sha256:%s", versionPrefix, o.Version, hex.EncodeToString(h.Sum(nil))[:16])
+}
+
+// renderTemplate выполняет шаблон и форматирует результат
+func renderTemplate(tmplName, tmplFile, outName string, data map[string]any) (File, error) {
 	tmplB, err := templatesFS.ReadFile(tmplFile)
 	if err != nil {
-		return fmt.Errorf("чтение шаблона %s: %w", tmplName, err)
+		return File{}, fmt.Errorf("чтение шаблона %s: %w", tmplName, err)
 	}
 
 	tmpl, err := template.New(tmplName).Funcs(templateFuncs()).Parse(string(tmplB))
 	if err != nil {
-		return fmt.Errorf("парсинг шаблона %s: %w", tmplName, err)
+		return File{}, fmt.Errorf("парсинг шаблона %s: %w", tmplName, err)
 	}
 
 	buf := &bytes.Buffer{}
 	if err := tmpl.Execute(buf, data); err != nil {
-		return fmt.Errorf("выполнение шаблона %s: %w", tmplName, err)
+		return File{}, fmt.Errorf("выполнение шаблона %s: %w", tmplName, err)
 	}
 
 	formatted, err := format.Source(buf.Bytes())
 	if err != nil {
-		_ = os.WriteFile(outFile, buf.Bytes(), 0o644)
-		return fmt.Errorf("форматирование %s: %w", tmplName, err)
+		return File{}, &formatError{name: outName, src: buf.Bytes(), err: err}
 	}
-
-	return os.WriteFile(outFile, formatted, 0o644)
+	return File{Name: outName, Content: formatted}, nil
 }
 
-func generateFlags(opts Options) error {
+func generateFlags(opts Options) (File, error) {
 	data := map[string]any{
 		"Package": opts.PackageName,
 		"Flags":   buildFlagTemplateData(opts.FlagDefs),
+		"Header":  opts.header(opts.FlagsHash),
 	}
-	outFile := filepath.Join(opts.OutputDir, "configgen_flags.go")
-	return generateFromTemplate("flags", "templates/flags.go.tmpl", outFile, data)
+	return renderTemplate("flags", "templates/flags.go.tmpl", "configgen_flags.go", data)
 }
 
-func generateFlagStore(opts Options) error {
+func generateFlagStore(opts Options) (File, error) {
 	data := map[string]any{
 		"Package": opts.PackageName,
+		"Header":  opts.header(""),
 	}
-	outFile := filepath.Join(opts.OutputDir, "configgen_flagstore.go")
-	return generateFromTemplate("flagstore", "templates/flagstore.go.tmpl", outFile, data)
+	return renderTemplate("flagstore", "templates/flagstore.go.tmpl", "configgen_flagstore.go", data)
 }
 
-func generateFlagTestHelpers(opts Options) error {
+func generateFlagTestHelpers(opts Options) (File, error) {
 	data := map[string]any{
 		"Package": opts.PackageName,
+		"Header":  opts.header(""),
 	}
-	outFile := filepath.Join(opts.OutputDir, "configgen_flags_test_helpers.go")
-	return generateFromTemplate("flags_test_helpers", "templates/flags_test_helpers.go.tmpl", outFile, data)
+	return renderTemplate("flags_test_helpers", "templates/flags_test_helpers.go.tmpl", "configgen_flags_test_helpers.go", data)
 }
@@ -1,6 +1,7 @@
 package generator
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 	"strings"
@@ -399,6 +400,103 @@ func TestGenerateWithoutLoader(t *testing.T) {
 	}
 }
 
+func TestRender(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "config")
+	fields := map[string]*model.Field{
+		"host": {Name: "Host", TOMLName: "host", Kind: model.KindString},
+	}
+
+	opts := Options{
+		OutputDir:   outDir,
+		PackageName: "config",
+		WithLoader:  true,
+	}
+	files, err := Render(opts, fields)
+	if err != nil {
+		t.Fatalf("Render вернул ошибку: %v", err)
+	}
+	if len(files) != 2 || files[0].Name != "configgen_config.go" || files[1].Name != "configgen_loader.go" {
+		t.Fatalf("неожиданные файлы: %v", files)
+	}
+	for _, f := range files {
+		// Без Version строки заголовка с версией и хешем не пишутся
+		lines := strings.SplitN(string(f.Content), "\n", 4)
+		if lines[0] != string(GeneratedMarker) || lines[2] != "" {
+			t.Errorf("%s: неверный заголовок:\n%s", f.Name, strings.Join(lines[:3], "\n"))
+		}
+	}
+	if _, err := os.Stat(outDir); !os.IsNotExist(err) {
+		t.Error("Render не должен создавать OutputDir")
+	}
+}
+
+func TestRenderHeader(t *testing.T) {
+	fields := map[string]*model.Field{
+		"host": {Name: "Host", TOMLName: "host", Kind: model.KindString},
+	}
+	opts := Options{
+		PackageName: "config",
+		WithLoader:  true,
+		WithFlags:   true,
+		FlagDefs:    []*model.FlagDef{{Name: "NewUI", TOMLName: "new_ui", Kind: model.FlagKindBool, Default: "false"}},
+		Version:     "v1.4.0",
+		SchemaHash:  "schema-1",
+		FlagsHash:   "flags-1",
+	}
+	render := func(opts Options) map[string][]byte {
+		t.Helper()
+		files, err := Render(opts, fields)
+		if err != nil {
+			t.Fatalf("Render вернул ошибку: %v", err)
+		}
+		out := make(map[string][]byte, len(files))
+		for _, f := range files {
+			out[f.Name] = f.Content
+		}
+		return out
+	}
+	base := render(opts)
+
+	for name, content := range base {
+		lines := strings.SplitN(string(content), "\n", 6)
+		if lines[2] != "// configgen version: v1.4.0" || !strings.HasPrefix(lines[3], "// configgen inputs: sha256:") || lines[4] != "" {
+			t.Errorf("%s: неверный заголовок:\n%s", name, strings.Join(lines[:5], "\n"))
+		}
+	}
+
+	// Хеш в заголовке зависит только от входных данных самого файла
+	flags := opts
+	flags.FlagsHash = "flags-2"
+	changed := render(flags)
+	for name, want := range map[string]bool{
+		"configgen_config.go":             false,
+		"configgen_loader.go":             false,
+		"configgen_flags.go":              true,
+		"configgen_flagstore.go":          false,
+		"configgen_flags_test_helpers.go": false,
+	} {
+		if got := !bytes.Equal(base[name], changed[name]); got != want {
+			t.Errorf("flags.toml изменён: %s изменился = %v, ожидалось %v", name, got, want)
+		}
+	}
+	schema := opts
+	schema.SchemaHash = "schema-2"
+	changed = render(schema)
+	if bytes.Equal(base["configgen_config.go"], changed["configgen_config.go"]) || bytes.Equal(base["configgen_loader.go"], changed["configgen_loader.go"]) {
+		t.Error("изменение схемы должно менять хеш config и loader")
+	}
+
+	// Версия пишется, но при сравнении не учитывается
+	version := opts
+	version.Version = "dev"
+	changed = render(version)
+	for name, content := range base {
+		if bytes.Equal(content, changed[name]) || !Equal(content, changed[name]) {
+			t.Errorf("%s: файлы разных версий configgen должны отличаться только строкой версии", name)
+		}
+	}
+}
+
 func TestGenerateWithEnumFlags(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -46,9 +46,9 @@ func TestGenerateLoaderWithEnvOverride(t *testing.T) {
 	}
 	loaderStr := string(content)
 
-	// Проверяем импорт env provider (с алиасом)
-	if !strings.Contains(loaderStr, `kenv "github.com/knadh/koanf/providers/env"`) {
-		t.Error("env provider import not found")
+	// env provider koanf больше не используется: значения разбираются по типу поля
+	if strings.Contains(loaderStr, `"github.com/knadh/koanf/providers/env"`) {
+		t.Error("env provider import should be replaced by typed env bindings")
 	}
 
 	// Проверяем EnableEnv в LoadOptions
@@ -56,13 +56,25 @@ func TestGenerateLoaderWithEnvOverride(t *testing.T) {
 		t.Error("EnableEnv field not found in LoadOptions")
 	}
 
-	// Проверяем загрузку env vars
-	if !strings.Contains(loaderStr, `envPrefix := "APP_"`) {
-		t.Error("env prefix not found")
+	// Проверяем привязки env vars к полям
+	if !strings.Contains(loaderStr, `{Key: "server", Env: "APP_SERVER", Section: true},`) {
+		t.Error("section env binding not found")
+	}
+
+	if !strings.Contains(loaderStr, `{Key: "server.port", Env: "APP_SERVER__PORT"},`) {
+		t.Error("field env binding not found")
 	}
 
-	if !strings.Contains(loaderStr, `kenv.Provider(envPrefix, "."`) {
-		t.Error("kenv.Provider not found")
+	if !strings.Contains(loaderStr, "applyEnv(k, os.LookupEnv)") {
+		t.Error("env layer should apply typed bindings")
+	}
+
+	// Строгий режим: известные служебные переменные не считаются опечатками
+	if !strings.Contains(loaderStr, "StrictEnv StrictMode") {
+		t.Error("StrictEnv field not found in LoadOptions")
+	}
+	if !strings.Contains(loaderStr, `"TEST_ENV":`) {
+		t.Error("environment selector var should be known to StrictEnv")
 	}
 
 	// Проверяем комментарий с примером (__ как разделитель секций)
@@ -106,4 +118,354 @@ func TestGenerateLoaderWithoutEnvOverride(t *testing.T) {
 	if strings.Contains(loaderStr, "EnableEnv bool") {
 		t.Error("EnableEnv field should not be present when WithEnvOverride=false")
 	}
+
+	if strings.Contains(loaderStr, "StrictEnv") {
+		t.Error("StrictEnv field should not be present when WithEnvOverride=false")
+	}
+}
+
+func TestGenerateLoaderCurrentEnvOnly(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	fields := map[string]*model.Field{
+		"host": {Name: "Host", TOMLName: "host", Kind: model.KindString},
+	}
+
+	opts := Options{
+		OutputDir:   tmpDir,
+		PackageName: "config",
+		WithLoader:  true,
+		EnvPrefix:   "APP_ENV",
+	}
+
+	if err := Generate(opts, fields); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "configgen_loader.go"))
+	if err != nil {
+		t.Fatalf("не удалось прочитать configgen_loader.go: %v", err)
+	}
+	loaderStr := string(content)
+
+	for _, want := range []string{
+		"AllEnvironments bool",
+		"func LoadAll(opts *LoadOptions) (map[Target]*Config, error)",
+		"type FileError struct",
+		"errors.Join(errs...)",
+		"loadEnvironment(opts, target, true)",
+	} {
+		if !strings.Contains(loaderStr, want) {
+			t.Errorf("configgen_loader.go должен содержать %q", want)
+		}
+	}
+}
+
+func TestGenerateLoaderLayers(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	fields := map[string]*model.Field{
+		"host": {Name: "Host", TOMLName: "host", Kind: model.KindString},
+	}
+
+	opts := Options{
+		OutputDir:   tmpDir,
+		PackageName: "config",
+		WithLoader:  true,
+		Layers: []model.Layer{
+			{Kind: model.LayerFile, Path: "config_{env}.toml", Required: true},
+			{Kind: model.LayerFile, Path: "override_{env}.toml", Override: true},
+		},
+	}
+
+	if err := Generate(opts, fields); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "configgen_loader.go"))
+	if err != nil {
+		t.Fatalf("не удалось прочитать configgen_loader.go: %v", err)
+	}
+	loaderStr := string(content)
+
+	if !strings.Contains(loaderStr, `{Kind: LayerFile, Path: "override_{env}.toml", Required: false, Override: true}`) {
+		t.Error("DefaultLayers() должен содержать override_{env}.toml")
+	}
+	if strings.Contains(loaderStr, `Path: "value.toml"`) {
+		t.Error("DefaultLayers() не должен содержать value.toml при явном списке слоёв")
+	}
+	if strings.Contains(loaderStr, "func readDotEnv(") {
+		t.Error("readDotEnv не нужен без WithEnvOverride")
+	}
+}
+
+func TestGenerateLoaderLayersRequireEnvOverride(t *testing.T) {
+	opts := Options{
+		OutputDir:   t.TempDir(),
+		PackageName: "config",
+		WithLoader:  true,
+		Layers: []model.Layer{
+			{Kind: model.LayerFile, Path: "config_{env}.toml", Required: true},
+			{Kind: model.LayerEnv, Override: true},
+		},
+	}
+
+	if err := Generate(opts, nil); err == nil {
+		t.Error("Generate должен вернуть ошибку: слой $env без WithEnvOverride")
+	}
+}
+
+func TestGenerateLoaderRegion(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	fields := map[string]*model.Field{
+		"host": {Name: "Host", TOMLName: "host", Kind: model.KindString},
+	}
+
+	opts := Options{
+		OutputDir:   tmpDir,
+		PackageName: "config",
+		WithLoader:  true,
+		RegionEnv:   "POD_REGION",
+	}
+
+	if err := Generate(opts, fields); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "configgen_loader.go"))
+	if err != nil {
+		t.Fatalf("не удалось прочитать configgen_loader.go: %v", err)
+	}
+	loaderStr := string(content)
+
+	for _, want := range []string{
+		`os.Getenv("POD_REGION")`,
+		"type Target struct",
+		"func GetAllTargets() map[Target]*Config",
+		`Path: "config_{env}.{region}.toml"`,
+	} {
+		if !strings.Contains(loaderStr, want) {
+			t.Errorf("configgen_loader.go должен содержать %q", want)
+		}
+	}
+
+	configContent, err := os.ReadFile(filepath.Join(tmpDir, "configgen_config.go"))
+	if err != nil {
+		t.Fatalf("не удалось прочитать configgen_config.go: %v", err)
+	}
+	if !strings.Contains(string(configContent), "Region string") {
+		t.Error("Config должен содержать поле Region")
+	}
+}
+
+func TestGenerateLoaderInterpolation(t *testing.T) {
+	fields := map[string]*model.Field{
+		"db": {
+			Name:     "Db",
+			TOMLName: "db",
+			Kind:     model.KindObject,
+			Children: map[string]*model.Field{
+				"host": {Name: "Host", TOMLName: "host", Kind: model.KindString},
+				"port": {Name: "Port", TOMLName: "port", Kind: model.KindInt},
+				"dsn":  {Name: "Dsn", TOMLName: "dsn", Kind: model.KindString, Refs: []string{"db.host", "db.port"}},
+			},
+		},
+	}
+
+	tmpDir := t.TempDir()
+	if err := Generate(Options{OutputDir: tmpDir, PackageName: "testconfig", WithLoader: true, EnvPrefix: "APP_ENV"}, fields); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(tmpDir, "configgen_loader.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaderStr := string(content)
+
+	for _, want := range []string{
+		`"db.port": {Type: "int"},`,
+		"цикл подстановок",
+		"StrictKeys StrictMode",
+		"checkUnknownKeys(opts, k, sources)",
+		"if err := interpolate(k, sources); err != nil {",
+		"func SourceOf(key string) (Source, bool) {",
+		"func Sources() map[string]Source {",
+	} {
+		if !strings.Contains(loaderStr, want) {
+			t.Errorf("loader должен содержать %q", want)
+		}
+	}
+
+	fields["db"].Children["dsn"].Refs = []string{"db.nmae"}
+	err = Generate(Options{OutputDir: t.TempDir(), PackageName: "testconfig", WithLoader: true}, fields)
+	if err == nil || !strings.Contains(err.Error(), "db.dsn: ссылка ${db.nmae} на несуществующий ключ") {
+		t.Errorf("ожидалась ошибка о несуществующем ключе, получено: %v", err)
+	}
+}
+
+func TestGenerateLoaderEnvCollision(t *testing.T) {
+	fields := map[string]*model.Field{
+		"db__host": {Name: "DbHost", TOMLName: "db__host", Kind: model.KindString},
+		"db": {
+			Name:     "Db",
+			TOMLName: "db",
+			Kind:     model.KindObject,
+			Children: map[string]*model.Field{
+				"host": {Name: "Host", TOMLName: "host", Kind: model.KindString},
+			},
+		},
+	}
+
+	err := Generate(Options{
+		OutputDir:       t.TempDir(),
+		PackageName:     "config",
+		WithLoader:      true,
+		WithEnvOverride: true,
+		EnvVarPrefix:    "APP_",
+	}, fields)
+	if err == nil || !strings.Contains(err.Error(), "APP_DB__HOST: ключи db.host и db__host") {
+		t.Errorf("ожидалась ошибка конфликта имён переменных, получено: %v", err)
+	}
+}
+
+func TestGenerateLoaderExplicitEnvNames(t *testing.T) {
+	fields := map[string]*model.Field{
+		"db": {
+			Name:     "Db",
+			TOMLName: "db",
+			Kind:     model.KindObject,
+			Children: map[string]*model.Field{
+				"dsn":  {Name: "Dsn", TOMLName: "dsn", Kind: model.KindString, Comment: "Строка подключения", EnvVars: []string{"DATABASE_URL", "PG_URL"}},
+				"host": {Name: "Host", TOMLName: "host", Kind: model.KindString},
+			},
+		},
+	}
+	opts := Options{
+		OutputDir:       t.TempDir(),
+		PackageName:     "config",
+		WithLoader:      true,
+		WithEnvOverride: true,
+		EnvVarPrefix:    "APP_",
+	}
+	if err := Generate(opts, fields); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	loader, err := os.ReadFile(filepath.Join(opts.OutputDir, "configgen_loader.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(loader), `{Key: "db.dsn", Env: "APP_DB__DSN", Aliases: []string{"DATABASE_URL", "PG_URL"}},`) {
+		t.Error("loader должен содержать явные имена переменных")
+	}
+	cfg, err := os.ReadFile(filepath.Join(opts.OutputDir, "configgen_config.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(cfg), "// Строка подключения\n\t// Env: DATABASE_URL, PG_URL\n\tDsn ") {
+		t.Errorf("документация поля должна содержать имена переменных:\n%s", cfg)
+	}
+
+	fields["db"].Children["host"].EnvVars = []string{"DATABASE_URL"}
+	opts.OutputDir = t.TempDir()
+	err = Generate(opts, fields)
+	if err == nil || !strings.Contains(err.Error(), "DATABASE_URL: переменная окружения указана для ключей db.dsn и db.host") {
+		t.Errorf("ожидалась ошибка конфликта явных имён, получено: %v", err)
+	}
+}
+
+func TestGenerateLoaderBindFlags(t *testing.T) {
+	fields := map[string]*model.Field{
+		"server": {
+			Name:     "Server",
+			TOMLName: "server",
+			Kind:     model.KindObject,
+			Children: map[string]*model.Field{
+				"port": {Name: "Port", TOMLName: "port", Kind: model.KindInt, Comment: "Порт\nсервера", Default: "8080"},
+			},
+		},
+	}
+	opts := Options{OutputDir: t.TempDir(), PackageName: "config", WithLoader: true, WithPFlag: true}
+	if err := Generate(opts, fields); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	loader, err := os.ReadFile(filepath.Join(opts.OutputDir, "configgen_loader.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"func BindFlags(fs *flag.FlagSet) *BoundFlags",
+		"Flags *BoundFlags",
+		"applyFlags(k, opts.Flags)",
+		`"server.port": {Type: "int", Help: "Порт сервера", Default: "8080"},`,
+		`{Kind: LayerFlags, Path: "", Required: false, Override: true},`,
+		"case LayerFlags:",
+	} {
+		if !strings.Contains(string(loader), want) {
+			t.Errorf("loader должен содержать %q", want)
+		}
+	}
+
+	pflag, err := os.ReadFile(filepath.Join(opts.OutputDir, "configgen_pflag.go"))
+	if err != nil {
+		t.Fatalf("configgen_pflag.go не сгенерирован: %v", err)
+	}
+	if !strings.Contains(string(pflag), "func BindPFlags(fs *pflag.FlagSet) *BoundFlags") {
+		t.Error("configgen_pflag.go должен содержать BindPFlags")
+	}
+
+	opts = Options{OutputDir: t.TempDir(), PackageName: "config", WithLoader: true}
+	if err := Generate(opts, fields); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(opts.OutputDir, "configgen_pflag.go")); err == nil {
+		t.Error("configgen_pflag.go не должен генерироваться без WithPFlag")
+	}
+}
+
+func TestGenerateLoaderSensitiveFlagDefaults(t *testing.T) {
+	fields := map[string]*model.Field{
+		"db": {
+			Name:     "Db",
+			TOMLName: "db",
+			Kind:     model.KindObject,
+			Children: map[string]*model.Field{
+				"host":     {Name: "Host", TOMLName: "host", Kind: model.KindString, Default: "localhost"},
+				"password": {Name: "Password", TOMLName: "password", Kind: model.KindString, Default: "dev_password"},
+				"dsn":      {Name: "Dsn", TOMLName: "dsn", Kind: model.KindString, Default: "postgres://u:p@db", Sensitive: true},
+			},
+		},
+		"vault": {
+			Name:      "Vault",
+			TOMLName:  "vault",
+			Kind:      model.KindObject,
+			Sensitive: true,
+			Children: map[string]*model.Field{
+				"role": {Name: "Role", TOMLName: "role", Kind: model.KindString, Default: "app-role"},
+			},
+		},
+	}
+	opts := Options{OutputDir: t.TempDir(), PackageName: "config", WithLoader: true}
+	if err := Generate(opts, fields); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	loader, err := os.ReadFile(filepath.Join(opts.OutputDir, "configgen_loader.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Секреты (по имени, # sensitive у ключа и у секции) не попадают в умолчания флагов
+	for _, secret := range []string{"dev_password", "postgres://u:p@db", "app-role"} {
+		if strings.Contains(string(loader), secret) {
+			t.Errorf("loader не должен содержать значение секрета %q", secret)
+		}
+	}
+	if !strings.Contains(string(loader), `"db.password":`) {
+		t.Error("секретный ключ должен остаться флагом без умолчания")
+	}
+	if !strings.Contains(string(loader), `{Type: "string", Default: "localhost"},`) {
+		t.Error("обычный ключ должен сохранить умолчание")
+	}
 }
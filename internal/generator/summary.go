@@ -0,0 +1,226 @@
+package generator
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ChangeKind тип изменения поля или флага между генерациями
+type ChangeKind string
+
+const (
+	ChangeAdded   ChangeKind = "added"
+	ChangeRemoved ChangeKind = "removed"
+	ChangeType    ChangeKind = "type"    // Тип изменился
+	ChangeRenamed ChangeKind = "renamed" // Удалён один ключ и добавлен другой того же типа
+)
+
+// SchemaChange изменение поля конфига или feature flag
+type SchemaChange struct {
+	Kind    ChangeKind
+	Key     string // db.host или имя флага; для renamed — новое имя
+	OldKey  string // renamed: прежнее имя
+	Type    string // Go тип; для removed — прежний
+	OldType string // type: прежний тип
+}
+
+// Summary изменения схемы между сгенерированным ранее и новым кодом
+type Summary struct {
+	Fields []SchemaChange
+	Flags  []SchemaChange
+}
+
+// Empty сообщает, что поля и флаги не изменились
+func (s Summary) Empty() bool {
+	return len(s.Fields) == 0 && len(s.Flags) == 0
+}
+
+// Summarize сравнивает поля конфига и флаги в старых и новых сгенерированных файлах.
+// Схема восстанавливается из кода (структуры Config и методы Flags), поэтому старые
+// файлы не требуют исходных TOML
+func Summarize(old, new []File) Summary {
+	oldFields, oldFlags := generatedSchema(old)
+	newFields, newFlags := generatedSchema(new)
+	return Summary{
+		Fields: compareSchemas(oldFields, newFields, true),
+		Flags:  compareSchemas(oldFlags, newFlags, false),
+	}
+}
+
+// generatedSchema извлекает ключи конфига и флаги с Go типами из сгенерированных файлов
+func generatedSchema(files []File) (fields, flags map[string]string) {
+	fields = make(map[string]string)
+	flags = make(map[string]string)
+	for _, f := range files {
+		file, err := parser.ParseFile(token.NewFileSet(), f.Name, f.Content, parser.SkipObjectResolution)
+		if err != nil {
+			// Повреждённый файл: изменения покажет diff, сводка по нему не строится
+			continue
+		}
+		switch f.Name {
+		case "configgen_config.go":
+			structs := make(map[string]*ast.StructType)
+			ast.Inspect(file, func(n ast.Node) bool {
+				if ts, ok := n.(*ast.TypeSpec); ok {
+					if st, ok := ts.Type.(*ast.StructType); ok {
+						structs[ts.Name.Name] = st
+					}
+				}
+				return true
+			})
+			collectStructFields(structs, "Config", "", fields, 0)
+		case "configgen_flags.go":
+			collectFlags(file, flags)
+		}
+	}
+	return fields, flags
+}
+
+// collectStructFields раскладывает структуру в ключи по тегам toml, вложенные структуры
+// файла становятся секциями
+func collectStructFields(structs map[string]*ast.StructType, name, prefix string, out map[string]string, depth int) {
+	st, ok := structs[name]
+	if !ok || depth > 16 {
+		return
+	}
+	for _, field := range st.Fields.List {
+		if field.Tag == nil {
+			continue
+		}
+		tag, err := strconv.Unquote(field.Tag.Value)
+		if err != nil {
+			continue
+		}
+		key, _, _ := strings.Cut(reflect.StructTag(tag).Get("toml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		if ident, ok := field.Type.(*ast.Ident); ok && structs[ident.Name] != nil {
+			collectStructFields(structs, ident.Name, prefix+key+".", out, depth+1)
+			continue
+		}
+		out[prefix+key] = types.ExprString(field.Type)
+	}
+}
+
+// collectFlags находит методы (*Flags) с вызовом f.store.GetX("name", ...)
+func collectFlags(file *ast.File, out map[string]string) {
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || fn.Body == nil || fn.Type.Results == nil || len(fn.Type.Results.List) != 1 {
+			continue
+		}
+		if star, ok := fn.Recv.List[0].Type.(*ast.StarExpr); !ok || types.ExprString(star.X) != "Flags" {
+			continue
+		}
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || !strings.HasPrefix(sel.Sel.Name, "Get") || !strings.HasSuffix(types.ExprString(sel.X), ".store") {
+				return true
+			}
+			if lit, ok := call.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				if name, err := strconv.Unquote(lit.Value); err == nil {
+					out[name] = types.ExprString(fn.Type.Results.List[0].Type)
+				}
+			}
+			return false
+		})
+	}
+}
+
+// compareSchemas сравнивает ключи с типами. Удалённый и добавленный ключ одного типа
+// считаются переименованием, если такая пара единственная (для полей — в одной секции
+// или с тем же именем в другой секции)
+func compareSchemas(old, new map[string]string, sections bool) []SchemaChange {
+	var changes, removed, added []SchemaChange
+	for key, typ := range old {
+		newType, ok := new[key]
+		switch {
+		case !ok:
+			removed = append(removed, SchemaChange{Kind: ChangeRemoved, Key: key, Type: typ})
+		case newType != typ:
+			changes = append(changes, SchemaChange{Kind: ChangeType, Key: key, Type: newType, OldType: typ})
+		}
+	}
+	for key, typ := range new {
+		if _, ok := old[key]; !ok {
+			added = append(added, SchemaChange{Kind: ChangeAdded, Key: key, Type: typ})
+		}
+	}
+
+	related := func(r, a SchemaChange) bool {
+		if r.Type != a.Type {
+			return false
+		}
+		if !sections {
+			return true
+		}
+		rs, rn := splitKey(r.Key)
+		as, an := splitKey(a.Key)
+		return rs == as || rn == an
+	}
+	usedAdded := make(map[int]bool)
+	for _, r := range removed {
+		match := -1
+		for i, a := range added {
+			if related(r, a) {
+				if match >= 0 {
+					match = -2 // Несколько кандидатов: переименование неоднозначно
+					break
+				}
+				match = i
+			}
+		}
+		if match >= 0 {
+			// Кандидат тоже должен быть однозначным с другой стороны
+			count := 0
+			for _, other := range removed {
+				if related(other, added[match]) {
+					count++
+				}
+			}
+			if count == 1 {
+				usedAdded[match] = true
+				changes = append(changes, SchemaChange{Kind: ChangeRenamed, Key: added[match].Key, OldKey: r.Key, Type: r.Type})
+				continue
+			}
+		}
+		changes = append(changes, r)
+	}
+	for i, a := range added {
+		if !usedAdded[i] {
+			changes = append(changes, a)
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return sortKey(changes[i]) < sortKey(changes[j])
+	})
+	return changes
+}
+
+// splitKey делит ключ на секцию и имя: db.host -> db, host
+func splitKey(key string) (section, name string) {
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", key
+}
+
+// sortKey порядок изменений в сводке: по ключу, переименования по прежнему имени
+func sortKey(c SchemaChange) string {
+	if c.Kind == ChangeRenamed {
+		return c.OldKey
+	}
+	return c.Key
+}
@@ -0,0 +1,62 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vovanwin/configgen/internal/model"
+)
+
+func TestSummarize(t *testing.T) {
+	section := func(children map[string]*model.Field) *model.Field {
+		return &model.Field{Name: "Redis", TOMLName: "redis", Kind: model.KindObject, Children: children}
+	}
+	oldFields := map[string]*model.Field{
+		"redis": section(map[string]*model.Field{
+			"addr": {Name: "Addr", TOMLName: "addr", Kind: model.KindString},
+			"db":   {Name: "Db", TOMLName: "db", Kind: model.KindString},
+		}),
+		"port": {Name: "Port", TOMLName: "port", Kind: model.KindInt},
+	}
+	newFields := map[string]*model.Field{
+		"redis": section(map[string]*model.Field{
+			"host": {Name: "Host", TOMLName: "host", Kind: model.KindString},
+			"db":   {Name: "Db", TOMLName: "db", Kind: model.KindInt},
+		}),
+		"timeout": {Name: "Timeout", TOMLName: "timeout", Kind: model.KindDuration},
+	}
+	oldFlags := []*model.FlagDef{
+		{Name: "NewUi", TOMLName: "new_ui", Kind: model.FlagKindBool, Default: false},
+	}
+	newFlags := []*model.FlagDef{
+		{Name: "NewUi", TOMLName: "new_ui", Kind: model.FlagKindBool, Default: true},
+		{Name: "RateLimit", TOMLName: "rate_limit", Kind: model.FlagKindInt, Default: 10},
+	}
+
+	render := func(fields map[string]*model.Field, flags []*model.FlagDef) []File {
+		files, err := Render(Options{PackageName: "config", WithFlags: true, FlagDefs: flags}, fields)
+		if err != nil {
+			t.Fatalf("Render: %v", err)
+		}
+		return files
+	}
+	got := Summarize(render(oldFields, oldFlags), render(newFields, newFlags))
+
+	wantFields := []SchemaChange{
+		{Kind: ChangeRemoved, Key: "port", Type: "int"},
+		{Kind: ChangeRenamed, Key: "redis.host", OldKey: "redis.addr", Type: "string"},
+		{Kind: ChangeType, Key: "redis.db", Type: "int", OldType: "string"},
+		{Kind: ChangeAdded, Key: "timeout", Type: "time.Duration"},
+	}
+	if !reflect.DeepEqual(got.Fields, wantFields) {
+		t.Errorf("поля:\n%+v\nожидалось:\n%+v", got.Fields, wantFields)
+	}
+	wantFlags := []SchemaChange{{Kind: ChangeAdded, Key: "rate_limit", Type: "int"}}
+	if !reflect.DeepEqual(got.Flags, wantFlags) {
+		t.Errorf("флаги: %+v, ожидалось %+v", got.Flags, wantFlags)
+	}
+
+	if s := Summarize(nil, render(oldFields, nil)); len(s.Fields) != 3 || s.Fields[0].Kind != ChangeAdded {
+		t.Errorf("первая генерация: все поля добавлены, получено %+v", s.Fields)
+	}
+}
@@ -0,0 +1,148 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// GeneratedMarker первая строка каждого файла, созданного configgen. Устаревшие файлы
+// удаляются только с этой строкой, чтобы не тронуть написанные руками configgen_*.go
+var GeneratedMarker = []byte("// Code generated by configgen. DO NOT EDIT.")
+
+// versionPrefix начало строки заголовка с версией configgen
+const versionPrefix = "// configgen version: "
+
+// Equal сравнивает сгенерированные файлы без строки заголовка с версией configgen:
+// код, сгенерированный из тех же входных данных через go run и собранным бинарником,
+// не перезаписывается и не считается устаревшим
+func Equal(a, b []byte) bool {
+	return bytes.Equal(withoutVersion(a), withoutVersion(b))
+}
+
+// withoutVersion удаляет строку с версией из заголовка (комментариев до первой пустой строки)
+func withoutVersion(b []byte) []byte {
+	end := bytes.Index(b, []byte("\n\n"))
+	if end < 0 {
+		return b
+	}
+	i := bytes.Index(b[:end], []byte("\n"+versionPrefix))
+	if i < 0 {
+		return b
+	}
+	j := bytes.IndexByte(b[i+1:], '\n') + i + 1
+	out := make([]byte, 0, len(b))
+	return append(append(out, b[:i]...), b[j:]...)
+}
+
+// Changes результат записи сгенерированных файлов
+type Changes struct {
+	Written   []string // Новые и изменённые файлы
+	Unchanged []string // Файлы с тем же содержимым: не перезаписаны, mtime сохранён
+	Removed   []string // Устаревшие configgen_*.go, которые больше не генерируются
+}
+
+// Write записывает файлы в dir как одну транзакцию: изменившиеся файлы сначала пишутся
+// во временные рядом с целевыми и только потом переименовываются, файлы с тем же
+// содержимым (без учёта версии configgen, см. Equal) не трогаются, а сгенерированные ранее configgen_*.go, которых нет в files
+// (например, удалён flags.toml), удаляются
+func Write(dir string, files []File) (Changes, error) {
+	var ch Changes
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return ch, fmt.Errorf("создание директории: %w", err)
+	}
+
+	type staged struct {
+		name, tmp string
+	}
+	var pending []staged
+	cleanup := func() {
+		for _, s := range pending {
+			_ = os.Remove(s.tmp)
+		}
+	}
+
+	produced := make(map[string]bool, len(files))
+	for _, f := range files {
+		produced[f.Name] = true
+		path := filepath.Join(dir, f.Name)
+		if old, err := os.ReadFile(path); err == nil && Equal(old, f.Content) {
+			ch.Unchanged = append(ch.Unchanged, f.Name)
+			continue
+		}
+		tmp, err := writeTemp(dir, f)
+		if err != nil {
+			cleanup()
+			return Changes{}, err
+		}
+		pending = append(pending, staged{f.Name, tmp})
+	}
+
+	// Все файлы готовы: переименование атомарно для каждого файла
+	for i, s := range pending {
+		if err := os.Rename(s.tmp, filepath.Join(dir, s.name)); err != nil {
+			pending = pending[i:]
+			cleanup()
+			return ch, fmt.Errorf("запись %s: %w", s.name, err)
+		}
+		ch.Written = append(ch.Written, s.name)
+	}
+
+	stale, err := staleFiles(dir, produced)
+	if err != nil {
+		return ch, err
+	}
+	for _, name := range stale {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return ch, fmt.Errorf("удаление %s: %w", name, err)
+		}
+		ch.Removed = append(ch.Removed, name)
+	}
+	return ch, nil
+}
+
+// writeTemp пишет содержимое файла во временный файл в той же директории
+func writeTemp(dir string, f File) (string, error) {
+	tmp, err := os.CreateTemp(dir, "."+f.Name+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("запись %s: %w", f.Name, err)
+	}
+	_, err = tmp.Write(f.Content)
+	if err == nil {
+		err = tmp.Chmod(0o644)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("запись %s: %w", f.Name, err)
+	}
+	return tmp.Name(), nil
+}
+
+// staleFiles находит сгенерированные configgen_*.go в dir, которых нет среди produced
+func staleFiles(dir string, produced map[string]bool) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "configgen_*.go"))
+	if err != nil {
+		return nil, err
+	}
+	var stale []string
+	for _, path := range paths {
+		name := filepath.Base(path)
+		if produced[name] {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.HasPrefix(content, GeneratedMarker) {
+			stale = append(stale, name)
+		}
+	}
+	sort.Strings(stale)
+	return stale, nil
+}
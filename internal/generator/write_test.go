@@ -0,0 +1,120 @@
+package generator
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vovanwin/configgen/internal/model"
+)
+
+func TestWrite(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "config")
+	marker := string(GeneratedMarker) + "\n"
+	files := []File{
+		{Name: "configgen_config.go", Content: []byte(marker + "package config\n")},
+		{Name: "configgen_flags.go", Content: []byte(marker + "package config\n\n// flags\n")},
+	}
+
+	ch, err := Write(dir, files)
+	if err != nil {
+		t.Fatalf("Write вернул ошибку: %v", err)
+	}
+	if !slices.Equal(ch.Written, []string{"configgen_config.go", "configgen_flags.go"}) || len(ch.Unchanged) != 0 || len(ch.Removed) != 0 {
+		t.Fatalf("первая запись: %+v", ch)
+	}
+
+	// Файл с тем же содержимым не перезаписывается
+	configPath := filepath.Join(dir, "configgen_config.go")
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(configPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+	// Написанный руками configgen_*.go без маркера не удаляется
+	manual := filepath.Join(dir, "configgen_custom.go")
+	if err := os.WriteFile(manual, []byte("package config\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// flags.toml удалён: configgen_flags.go больше не генерируется
+	files[0].Content = []byte(marker + "package config\n")
+	ch, err = Write(dir, files[:1])
+	if err != nil {
+		t.Fatalf("Write вернул ошибку: %v", err)
+	}
+	if len(ch.Written) != 0 || !slices.Equal(ch.Unchanged, []string{"configgen_config.go"}) || !slices.Equal(ch.Removed, []string{"configgen_flags.go"}) {
+		t.Errorf("вторая запись: %+v", ch)
+	}
+	if st, err := os.Stat(configPath); err != nil || !st.ModTime().Equal(old) {
+		t.Error("неизменённый файл не должен перезаписываться")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "configgen_flags.go")); !os.IsNotExist(err) {
+		t.Error("устаревший configgen_flags.go должен быть удалён")
+	}
+	if _, err := os.Stat(manual); err != nil {
+		t.Error("configgen_custom.go без маркера не должен удаляться")
+	}
+
+	// Отличие только в версии configgen не считается изменением
+	versioned := func(version string) []byte {
+		return []byte(marker + "// Код сгенерирован configgen. НЕ РЕДАКТИРОВАТЬ.\n" + versionPrefix + version + "\n// configgen inputs: sha256:0123\n\npackage config\n")
+	}
+	files[0].Content = versioned("v1.4.0")
+	if _, err := Write(dir, files[:1]); err != nil {
+		t.Fatalf("Write вернул ошибку: %v", err)
+	}
+	files[0].Content = versioned("dev")
+	ch, err = Write(dir, files[:1])
+	if err != nil {
+		t.Fatalf("Write вернул ошибку: %v", err)
+	}
+	if !slices.Equal(ch.Unchanged, []string{"configgen_config.go"}) {
+		t.Errorf("файл другой версии configgen не должен перезаписываться: %+v", ch)
+	}
+
+	// Изменённый файл перезаписывается, временные файлы не остаются
+	files[0].Content = []byte(marker + "package config\n\n// v2\n")
+	ch, err = Write(dir, files[:1])
+	if err != nil {
+		t.Fatalf("Write вернул ошибку: %v", err)
+	}
+	if !slices.Equal(ch.Written, []string{"configgen_config.go"}) {
+		t.Errorf("третья запись: %+v", ch)
+	}
+	got, _ := os.ReadFile(configPath)
+	if string(got) != string(files[0].Content) {
+		t.Errorf("неверное содержимое: %q", got)
+	}
+	if st, _ := os.Stat(configPath); st.Mode().Perm() != 0o644 {
+		t.Errorf("права файла: %v, ожидалось 0644", st.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tmp") {
+			t.Errorf("остался временный файл %s", e.Name())
+		}
+	}
+}
+
+func TestGenerateFormatErrorWritesNothing(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "config")
+	fields := map[string]*model.Field{
+		"host": {Name: "Host", TOMLName: "host", Kind: model.KindString},
+	}
+	// Некорректное имя пакета ломает gofmt сгенерированного кода
+	err := Generate(Options{OutputDir: dir, PackageName: "not valid", WithLoader: true}, fields)
+	var fe *formatError
+	if !errors.As(err, &fe) {
+		t.Fatalf("ожидалась ошибка форматирования, получено: %v", err)
+	}
+	if !strings.Contains(err.Error(), "package not valid") {
+		t.Errorf("в ошибке должна быть строка с ошибкой: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("при ошибке генерации ничего не должно записываться")
+	}
+}
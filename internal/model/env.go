@@ -0,0 +1,75 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EnvBinding переменная окружения для ключа или секции
+type EnvBinding struct {
+	Key     string   // section.key
+	Env     string   // APP_SECTION__KEY
+	Section bool     // Секция: значение — JSON объект
+	Aliases []string // Явные имена из директивы # env:, проверяются после Env по порядку
+}
+
+// EnvBindings строит имена переменных окружения для всех ключей и секций схемы:
+// префикс + путь в верхнем регистре, секции разделяются __, "-" заменяется на "_"
+// Порядок совпадает с порядком применения в loader: секция раньше своих ключей
+// Ключи, дающие одно и то же имя (a__b и a.b, Host и host) или с общим именем из # env:, — ошибка
+func EnvBindings(prefix string, fields map[string]*Field) ([]EnvBinding, error) {
+	var out []EnvBinding
+	var walk func(m map[string]*Field, path string)
+	walk = func(m map[string]*Field, path string) {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			f := m[k]
+			key := path + k
+			out = append(out, EnvBinding{
+				Key:     key,
+				Env:     EnvVarName(prefix, key),
+				Section: f.Kind == KindObject,
+				Aliases: f.EnvVars,
+			})
+			if f.Kind == KindObject {
+				walk(f.Children, key+".")
+			}
+		}
+	}
+	walk(fields, "")
+
+	owners := make(map[string]string, len(out))
+	var errs []string
+	for _, b := range out {
+		if other, ok := owners[b.Env]; ok {
+			errs = append(errs, fmt.Sprintf("%s: ключи %s и %s дают одинаковое имя переменной окружения", b.Env, other, b.Key))
+			continue
+		}
+		owners[b.Env] = b.Key
+	}
+	for _, b := range out {
+		for _, name := range b.Aliases {
+			if other, ok := owners[name]; ok && other != b.Key {
+				errs = append(errs, fmt.Sprintf("%s: переменная окружения указана для ключей %s и %s", name, other, b.Key))
+				continue
+			}
+			owners[name] = b.Key
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("конфликт переменных окружения:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return out, nil
+}
+
+// EnvVarName возвращает имя переменной окружения для ключа: db.max_idle_time -> APP_DB__MAX_IDLE_TIME
+func EnvVarName(prefix, key string) string {
+	name := strings.ReplaceAll(key, ".", "__")
+	name = strings.ReplaceAll(name, "-", "_")
+	return prefix + strings.ToUpper(name)
+}
@@ -0,0 +1,122 @@
+package model
+
+import "strings"
+
+// LayerKind представляет тип слоя конфигурации
+type LayerKind int
+
+const (
+	LayerFile   LayerKind = iota // TOML файл
+	LayerDotEnv                  // .env файл с KEY=VALUE
+	LayerEnv                     // переменные окружения
+	LayerFlags                   // флаги командной строки (BindFlags)
+)
+
+func (k LayerKind) String() string {
+	switch k {
+	case LayerFile:
+		return "file"
+	case LayerDotEnv:
+		return "dotenv"
+	case LayerEnv:
+		return "env"
+	case LayerFlags:
+		return "flags"
+	default:
+		return "unknown"
+	}
+}
+
+// Плейсхолдеры в Path слоя
+const (
+	EnvPlaceholder    = "{env}"    // имя окружения
+	RegionPlaceholder = "{region}" // регион/кластер; слой пропускается, если регион не задан
+)
+
+// Layer описывает один слой в порядке мержа конфигурации (от первого к последнему)
+type Layer struct {
+	Kind     LayerKind // Тип слоя
+	Path     string    // Путь относительно директории конфигов, {env} и {region} заменяются при загрузке
+	Required bool      // Ошибка загрузки, если файла нет
+	Override bool      // Применяется только к текущему окружению (override.toml, config_local.toml, env vars)
+}
+
+// IsEnvFile возвращает true для слоя, задающего окружение (config_{env}.toml)
+func (l Layer) IsEnvFile() bool {
+	return l.Kind == LayerFile && !l.Override && strings.Contains(l.Path, EnvPlaceholder) && !l.IsOverlay()
+}
+
+// IsBase возвращает true для общего слоя без плейсхолдеров (value.toml)
+func (l Layer) IsBase() bool {
+	return l.Kind == LayerFile && !l.Override && !strings.Contains(l.Path, "{")
+}
+
+// IsOverlay возвращает true для регионального оверлея (config_{env}.{region}.toml)
+func (l Layer) IsOverlay() bool {
+	return l.Kind == LayerFile && strings.Contains(l.Path, RegionPlaceholder)
+}
+
+// String возвращает слой в формате --layers: [+]path[?] или $env
+func (l Layer) String() string {
+	var s string
+	switch l.Kind {
+	case LayerEnv:
+		return "$env"
+	case LayerFlags:
+		return "$flags"
+	default:
+		s = l.Path
+	}
+	if l.Override && l.Kind == LayerFile {
+		s = "+" + s
+	}
+	if !l.Required {
+		s += "?"
+	}
+	return s
+}
+
+// Describe возвращает человекочитаемое описание слоя для вывода CLI
+func (l Layer) Describe() string {
+	var parts []string
+	switch l.Kind {
+	case LayerEnv:
+		return "environment variables"
+	case LayerFlags:
+		return "command-line flags (BindFlags)"
+	case LayerDotEnv:
+		parts = append(parts, "dotenv")
+	}
+	if l.IsOverlay() {
+		parts = append(parts, "region overlay")
+	}
+	if l.Required {
+		parts = append(parts, "required")
+	} else {
+		parts = append(parts, "optional")
+	}
+	if l.Override {
+		parts = append(parts, "current env only")
+	}
+	return l.Path + " (" + strings.Join(parts, ", ") + ")"
+}
+
+// DefaultLayers возвращает порядок слоёв по умолчанию
+// withEnv добавляет .env и переменные окружения перед флагами командной строки
+func DefaultLayers(withEnv bool) []Layer {
+	layers := []Layer{
+		{Kind: LayerFile, Path: "value.toml"},
+		{Kind: LayerFile, Path: "config_{env}.toml", Required: true},
+		{Kind: LayerFile, Path: "config_{env}.{region}.toml"},
+		{Kind: LayerFile, Path: "override.toml", Override: true},
+		{Kind: LayerFile, Path: "override_{env}.toml", Override: true},
+		{Kind: LayerFile, Path: "config_local.toml", Override: true},
+	}
+	if withEnv {
+		layers = append(layers,
+			Layer{Kind: LayerDotEnv, Path: ".env", Override: true},
+			Layer{Kind: LayerEnv, Override: true},
+		)
+	}
+	return append(layers, Layer{Kind: LayerFlags, Override: true})
+}
@@ -1,5 +1,7 @@
 package model
 
+import "strings"
+
 // Kind представляет тип поля конфигурации
 type Kind int
 
@@ -36,10 +38,60 @@ func (k Kind) String() string {
 
 // Field представляет одно поле конфигурации
 type Field struct {
-	Name     string            // Имя в Go (CamelCase)
-	TOMLName string            // Оригинальное имя из TOML
-	Kind     Kind              // Тип поля
-	Children map[string]*Field // Для вложенных объектов
-	ItemKind Kind              // Для слайсов: тип элементов
-	Comment  string            // Комментарий из TOML файла
+	Name      string            // Имя в Go (CamelCase)
+	TOMLName  string            // Оригинальное имя из TOML
+	Kind      Kind              // Тип поля
+	Children  map[string]*Field // Для вложенных объектов
+	ItemKind  Kind              // Для слайсов: тип элементов
+	Comment   string            // Комментарий из TOML файла
+	Refs      []string          // Пути ключей из подстановок ${section.key} в значении
+	WholeRef  string            // Путь ключа, если значение целиком "${section.key}"
+	EnvVars   []string          // Явные имена переменных окружения из директивы # env:
+	Default   string            // Значение из TOML для справки (--help); пусто, если различается по окружениям
+	Sensitive bool              // Значение секретное (директива # sensitive), не выводится в открытом виде
+}
+
+// Lookup находит поле по пути section.key
+func Lookup(fields map[string]*Field, path string) *Field {
+	parts := strings.Split(path, ".")
+	for i, p := range parts {
+		f, ok := fields[p]
+		if !ok {
+			return nil
+		}
+		if i == len(parts)-1 {
+			return f
+		}
+		if f.Kind != KindObject {
+			return nil
+		}
+		fields = f.Children
+	}
+	return nil
+}
+
+// sensitiveWords части имён ключей, значения которых считаются секретами без директивы
+var sensitiveWords = []string{"password", "passwd", "secret", "token", "api_key", "apikey", "private_key", "credential"}
+
+// IsSensitive возвращает true, если значение ключа нельзя показывать: ключ или его секция
+// помечены # sensitive, либо имя ключа похоже на секрет (password, token, secret...)
+func IsSensitive(fields map[string]*Field, path string) bool {
+	for _, p := range strings.Split(path, ".") {
+		f, ok := fields[p]
+		if !ok {
+			break
+		}
+		if f.Sensitive {
+			return true
+		}
+		fields = f.Children
+	}
+
+	name := strings.ToLower(path[strings.LastIndex(path, ".")+1:])
+	for _, w := range sensitiveWords {
+		if strings.Contains(name, w) {
+			return true
+		}
+	}
+	return false
 }
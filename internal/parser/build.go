@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/vovanwin/configgen/internal/diag"
+	"github.com/vovanwin/configgen/internal/model"
+)
+
+// Режимы построения схемы из окружений
+const (
+	ModeIntersect = "intersect" // Поля, общие для всех окружений
+	ModeUnion     = "union"     // Поля всех окружений
+)
+
+// Schema схема директории конфигов: так её строят генерация, explain и render
+type Schema struct {
+	Fields   map[string]*model.Field   // Схема: базовые слои и окружения по режиму
+	Parsed   []ParsedFile              // Разобранные файлы в порядке разбора
+	Base     map[string]*model.Field   // Поля базовых слоёв (value.toml)
+	Envs     []string                  // Имена окружений без региональных оверлеев
+	EnvPaths []string                  // Файл каждого окружения из Envs
+	EnvTrees []map[string]*model.Field // Поля каждого окружения из Envs с учётом extends
+	Inputs   []string                  // Прочитанные файлы вместе с include
+}
+
+// ParsedFile разобранный файл и число его полей верхнего уровня
+type ParsedFile struct {
+	Name   string // Путь относительно директории конфигов
+	Fields int
+}
+
+// BuildSchema разбирает базовые слои (value.toml) и файлы окружений с региональными
+// оверлеями, разрешает extends и строит схему в режиме mode (ModeIntersect или ModeUnion)
+// Файлы ищутся по слоям так же, как в сгенерированном loader. Ошибки разбора всех файлов
+// возвращаются вместе как diag.List
+func BuildSchema(dir string, layers []model.Layer, mode string) (*Schema, error) {
+	if mode != ModeIntersect && mode != ModeUnion {
+		return nil, fmt.Errorf("неизвестный режим %q (допустимы: %s, %s)", mode, ModeIntersect, ModeUnion)
+	}
+	res := &Schema{}
+	var errs diag.List
+
+	// Базовые слои (value.toml): общие для всех окружений значения
+	var valueFields map[string]*model.Field
+	for _, l := range layers {
+		if !l.IsBase() {
+			continue
+		}
+		path := filepath.Join(dir, l.Path)
+		if _, err := os.Stat(path); err != nil {
+			if l.Required {
+				errs.Add(&diag.Error{Code: diag.CodeRead, File: path, Err: fmt.Errorf("обязательный слой не найден")})
+			}
+			continue
+		}
+		doc, err := ReadDocument(path)
+		if err != nil {
+			errs.Add(err)
+			continue
+		}
+		m, err := doc.Fields()
+		if err != nil {
+			errs.Add(err)
+			continue
+		}
+		res.Inputs = append(res.Inputs, path)
+		res.Inputs = append(res.Inputs, doc.Includes...)
+		valueFields = Union(valueFields, m)
+		res.Parsed = append(res.Parsed, ParsedFile{Name: l.Path, Fields: len(m)})
+	}
+
+	// Файлы окружений (config_{env}.toml без слоёв вроде config_local.toml) и их оверлеи
+	envFiles, err := DiscoverEnvFiles(dir, layers)
+	if err != nil {
+		return nil, err
+	}
+	if len(envFiles) == 0 && valueFields == nil && len(errs) == 0 {
+		return nil, &diag.Error{Code: diag.CodeSchema, File: dir, Err: fmt.Errorf("нужен хотя бы value.toml или config_*.toml")}
+	}
+	overlays, err := DiscoverOverlays(dir, layers, envFiles)
+	if err != nil {
+		return nil, err
+	}
+	targets := append(envFiles, overlays...)
+
+	envAsts, err := ParseEnvFiles(targets)
+	if err != nil {
+		errs.Add(err)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	for i, f := range targets {
+		res.Parsed = append(res.Parsed, ParsedFile{Name: filepath.Base(f.Path), Fields: len(envAsts[i])})
+		// Файлы уже разобраны, повторное чтение (из кеша) только собирает include
+		doc, err := ReadDocument(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		res.Inputs = append(res.Inputs, f.Path)
+		res.Inputs = append(res.Inputs, doc.Includes...)
+	}
+	res.Base = valueFields
+	for i, f := range envFiles {
+		res.Envs = append(res.Envs, f.Name())
+		res.EnvPaths = append(res.EnvPaths, f.Path)
+		res.EnvTrees = append(res.EnvTrees, envAsts[i])
+	}
+
+	var envSchema map[string]*model.Field
+	if len(envAsts) > 0 {
+		if mode == ModeUnion {
+			envSchema = Union(envAsts...)
+		} else {
+			envSchema = Intersect(envAsts...)
+		}
+	}
+
+	// Поля value.toml объединяются с полями окружений
+	switch {
+	case valueFields != nil && envSchema != nil:
+		res.Fields = Union(valueFields, envSchema)
+	case valueFields != nil:
+		res.Fields = valueFields
+	default:
+		res.Fields = envSchema
+	}
+
+	if len(res.Fields) == 0 {
+		return nil, &diag.Error{Code: diag.CodeSchema, File: dir, Err: fmt.Errorf("пустая схема: поля не найдены")}
+	}
+	return res, nil
+}
@@ -0,0 +1,50 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vovanwin/configgen/internal/model"
+)
+
+func TestBuildSchema(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"value.toml":          "[app]\nname = \"svc\"\n",
+		"config_prod.toml":    "[db]\nhost = \"db.prod\"\nport = 5432\nreplicas = 3\n",
+		"config_stg.toml":     "extends = \"prod\"\n\n[db]\nhost = \"db.stg\"\n",
+		"config_prod.eu.toml": "[db]\nhost = \"db.eu\"\n",
+		"config_local.toml":   "[db]\nhost = \"localhost\"\n",
+	})
+
+	schema, err := BuildSchema(dir, model.DefaultLayers(false), ModeIntersect)
+	if err != nil {
+		t.Fatalf("BuildSchema вернул ошибку: %v", err)
+	}
+	// stg наследует port и replicas от prod, config_local.toml — слой, а не окружение
+	if strings.Join(schema.Envs, ",") != "prod,stg" {
+		t.Errorf("Envs = %v, ожидалось prod,stg", schema.Envs)
+	}
+	for _, key := range []string{"app.name", "db.host", "db.port", "db.replicas"} {
+		if model.Lookup(schema.Fields, key) == nil {
+			t.Errorf("в схеме нет %s", key)
+		}
+	}
+	if model.Lookup(schema.EnvTrees[1], "db.replicas") == nil {
+		t.Error("дерево stg должно включать поля prod через extends")
+	}
+	var parsed []string
+	for _, p := range schema.Parsed {
+		parsed = append(parsed, p.Name)
+	}
+	if strings.Join(parsed, ",") != "value.toml,config_prod.toml,config_stg.toml,config_prod.eu.toml" {
+		t.Errorf("Parsed = %v", parsed)
+	}
+
+	if _, err := BuildSchema(dir, model.DefaultLayers(false), "all"); err == nil || !strings.Contains(err.Error(), "неизвестный режим") {
+		t.Errorf("ожидалась ошибка режима, получено %v", err)
+	}
+	if _, err := BuildSchema(t.TempDir(), model.DefaultLayers(false), ModeUnion); err == nil {
+		t.Error("ожидалась ошибка для пустой директории")
+	}
+}
@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/vovanwin/configgen/internal/diag"
+)
+
+// parsedFile разобранный TOML файл до обработки include и extends
+type parsedFile struct {
+	path       string // Путь, с которым файл был прочитан: он попадает в позиции ключей
+	content    []byte
+	values     map[string]any
+	comments   commentMap
+	directives directiveMap
+	lines      positionMap
+}
+
+// fileCache разобранные файлы по абсолютному пути. Файл читается при каждом обращении,
+// а разбор повторяется, только если содержимое изменилось: общие include и extends
+// разбираются один раз для всех окружений и целей генерации, а --watch видит правки
+var fileCache = struct {
+	sync.Mutex
+	files map[string]*parsedFile
+}{files: make(map[string]*parsedFile)}
+
+// parseCached читает и разбирает TOML файл с учётом fileCache. Возвращаются копии,
+// которые документ может менять
+func parseCached(path string) (map[string]any, commentMap, directiveMap, positionMap, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return nil, nil, nil, nil, &diag.Error{Code: diag.CodeRead, File: path, Err: fmt.Errorf("чтение файла: %w", err)}
+	}
+
+	key, err := filepath.Abs(path)
+	if err != nil {
+		key = path
+	}
+	fileCache.Lock()
+	pf, ok := fileCache.files[key]
+	fileCache.Unlock()
+
+	// Позиции в диагностиках содержат путь, поэтому файл, прочитанный по другому пути, разбирается заново
+	if !ok || !bytes.Equal(pf.content, b) || pf.path != path {
+		var root map[string]any
+		if _, err := toml.Decode(string(b), &root); err != nil {
+			return nil, nil, nil, nil, syntaxError(path, b, err)
+		}
+		// Комментарии, директивы и позиции ключей
+		comments, directives, lines, err := extractComments(path, bytes.NewReader(b))
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		pf = &parsedFile{path: path, content: b, values: root, comments: comments, directives: directives, lines: lines}
+		fileCache.Lock()
+		fileCache.files[key] = pf
+		fileCache.Unlock()
+	}
+
+	comments := make(commentMap, len(pf.comments))
+	for k, c := range pf.comments {
+		comments[k] = c
+	}
+	directives := make(directiveMap, len(pf.directives))
+	for k, d := range pf.directives {
+		cp := *d
+		directives[k] = &cp
+	}
+	lines := make(positionMap, len(pf.lines))
+	for k, pos := range pf.lines {
+		lines[k] = pos
+	}
+	return copyValue(pf.values).(map[string]any), comments, directives, lines, nil
+}
+
+// copyValue глубоко копирует таблицы и массивы TOML
+func copyValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		cp := make(map[string]any, len(val))
+		for k, item := range val {
+			cp[k] = copyValue(item)
+		}
+		return cp
+	case []any:
+		cp := make([]any, len(val))
+		for i, item := range val {
+			cp[i] = copyValue(item)
+		}
+		return cp
+	case []map[string]any:
+		cp := make([]map[string]any, len(val))
+		for i, item := range val {
+			cp[i] = copyValue(item).(map[string]any)
+		}
+		return cp
+	default:
+		return v
+	}
+}
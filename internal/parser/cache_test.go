@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestReadDocumentCache(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"common.toml":      "[db]\n# Хост базы\nhost = \"localhost\"\nports = [5432]\n",
+		"config_dev.toml":  "include = \"common.toml\"\n[db]\nname = \"dev\"\n",
+		"config_prod.toml": "include = \"common.toml\"\n[db]\nhost = \"db.prod\"\nname = \"prod\"\n",
+	})
+
+	// Окружения подключают общий файл: документы не должны делить данные кеша
+	prod, err := ReadDocument(filepath.Join(dir, "config_prod.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prod.Values["db"].(map[string]any)["ports"].([]any)[0] = int64(1)
+	dev, err := ReadDocument(filepath.Join(dir, "config_dev.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := dev.Values["db"].(map[string]any)
+	if db["host"] != "localhost" || db["ports"].([]any)[0] != int64(5432) {
+		t.Errorf("изменения одного документа попали в другой: %v", db)
+	}
+	if dev.Comments["db.host"] != "Хост базы" {
+		t.Errorf("комментарий из include потерян: %q", dev.Comments["db.host"])
+	}
+
+	// Изменённый файл разбирается заново
+	if err := os.WriteFile(filepath.Join(dir, "common.toml"), []byte("[db]\nhost = \"127.0.0.1\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dev, err = ReadDocument(filepath.Join(dir, "config_dev.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := dev.Values["db"].(map[string]any)["host"]; got != "127.0.0.1" {
+		t.Errorf("после изменения файла host = %v, ожидалось 127.0.0.1", got)
+	}
+
+	// Параллельное чтение (несколько целей генерации) безопасно
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ReadDocument(filepath.Join(dir, "config_prod.toml")); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/vovanwin/configgen/internal/diag"
+	"github.com/vovanwin/configgen/internal/model"
+)
+
+func TestParseErrorPositions(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"syntax.toml": "[db]\nhost = \"db\"\nport = 54 32\n",
+		"eol.toml":    "[db]\nhost = \n",
+		"eof.toml":    "[db]\nhost = \"db\"\nport = ",
+		"string.toml": "[db]\nhost = \"db\n\nport = 1\n",
+		"value.toml":  "[db]\nhost = \"db\"\n  created = 2024-01-01\n",
+		"env.toml":    "[db]\n# env: 1BAD\nhost = \"db\"\n",
+		"extends.toml": `
+[configgen]
+extends = 1
+`,
+	})
+
+	tests := []struct {
+		file string
+		want diag.Diagnostic
+	}{
+		{"syntax.toml", diag.Diagnostic{Code: diag.CodeSyntax, Line: 3, Column: 10, Key: "db"}},
+		// Ошибка в конце строки: позиция в пределах файла из двух строк
+		{"eol.toml", diag.Diagnostic{Code: diag.CodeSyntax, Line: 2, Column: 8, Key: "db.host"}},
+		{"eof.toml", diag.Diagnostic{Code: diag.CodeSyntax, Line: 3, Column: 7, Key: "db.port"}},
+		{"string.toml", diag.Diagnostic{Code: diag.CodeSyntax, Line: 2, Column: 11, Key: "db.host"}},
+		{"value.toml", diag.Diagnostic{Code: diag.CodeValue, Line: 3, Column: 3, Key: "db.created"}},
+		{"env.toml", diag.Diagnostic{Code: diag.CodeDirective, Line: 2}},
+		{"extends.toml", diag.Diagnostic{Code: diag.CodeDirective, Line: 3, Column: 1, Key: "configgen.extends"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join(tmpDir, tt.file)
+			_, err := ParseFile(path)
+			list := diag.FromError(err)
+			if len(list) != 1 {
+				t.Fatalf("ожидалась одна диагностика, получено: %v", err)
+			}
+			got := list[0]
+			if got.File != path || got.Code != tt.want.Code || got.Line != tt.want.Line || got.Column != tt.want.Column || got.Key != tt.want.Key {
+				t.Errorf("диагностика %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEnvFilesCollectsErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"config_prod.toml": "[db]\nport = \n",
+		"config_stg.toml":  "[db]\n# env: 1BAD\nport = 1\n",
+		"config_dev.toml":  "[db]\nport = 1\n",
+	})
+	files, err := DiscoverEnvFiles(tmpDir, model.DefaultLayers(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseEnvFiles(files)
+	list := diag.FromError(err)
+	if len(list) != 2 {
+		t.Fatalf("ожидались ошибки обоих файлов, получено: %v", err)
+	}
+	list.Sort()
+	if filepath.Base(list[0].File) != "config_prod.toml" || filepath.Base(list[1].File) != "config_stg.toml" {
+		t.Errorf("файлы диагностик: %s, %s", list[0].File, list[1].File)
+	}
+}
@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"reflect"
+	"sort"
+)
+
+// ChangeKind тип изменения значения ключа
+type ChangeKind string
+
+const (
+	ChangeAdded   ChangeKind = "added"
+	ChangeRemoved ChangeKind = "removed"
+	ChangeChanged ChangeKind = "changed"
+)
+
+// Change изменение значения ключа между двумя версиями конфига
+type Change struct {
+	Key  string     `json:"key"`
+	Kind ChangeKind `json:"change"`
+	Old  any        `json:"old,omitempty"` // Пусто для added
+	New  any        `json:"new,omitempty"` // Пусто для removed
+}
+
+// DiffValues сравнивает плоские значения (результаты Resolve), изменения отсортированы по ключу
+func DiffValues(old, new map[string]any) []Change {
+	var changes []Change
+	for key, ov := range old {
+		nv, ok := new[key]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Key: key, Kind: ChangeRemoved, Old: ov})
+		case !reflect.DeepEqual(ov, nv):
+			changes = append(changes, Change{Key: key, Kind: ChangeChanged, Old: ov, New: nv})
+		}
+	}
+	for key, nv := range new {
+		if _, ok := old[key]; !ok {
+			changes = append(changes, Change{Key: key, Kind: ChangeAdded, New: nv})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
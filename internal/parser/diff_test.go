@@ -0,0 +1,33 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffValues(t *testing.T) {
+	old := map[string]any{
+		"db.host":      "localhost",
+		"db.pool_size": int64(5),
+		"redis.addr":   "localhost:6379",
+		"server.hosts": []any{"a", "b"},
+	}
+	new := map[string]any{
+		"db.host":      "localhost",
+		"db.pool_size": int64(10),
+		"redis.host":   "localhost",
+		"server.hosts": []any{"a", "b"},
+	}
+
+	want := []Change{
+		{Key: "db.pool_size", Kind: ChangeChanged, Old: int64(5), New: int64(10)},
+		{Key: "redis.addr", Kind: ChangeRemoved, Old: "localhost:6379"},
+		{Key: "redis.host", Kind: ChangeAdded, New: "localhost"},
+	}
+	if got := DiffValues(old, new); !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffValues = %+v\nожидалось %+v", got, want)
+	}
+	if got := DiffValues(old, old); len(got) != 0 {
+		t.Errorf("одинаковые значения не должны давать изменений: %+v", got)
+	}
+}
@@ -0,0 +1,173 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vovanwin/configgen/internal/diag"
+	"github.com/vovanwin/configgen/internal/model"
+)
+
+// DriftKind тип расхождения
+type DriftKind string
+
+const (
+	DriftMissing DriftKind = "missing" // Ключа нет в части окружений
+	DriftType    DriftKind = "type"    // Тип ключа различается
+	DriftOnlyIn  DriftKind = "only-in" // Ключ есть только в одном окружении
+)
+
+// Drift расхождение ключа между окружениями
+type Drift struct {
+	Kind     DriftKind
+	Severity diag.Severity
+	Key      string
+	Envs     []string          // missing: окружения без ключа; only-in: окружение с ключом
+	Present  []string          // missing: окружения с ключом
+	Types    map[string]string // type: тип ключа по окружениям
+	Similar  string            // only-in: похожий ключ других окружений (redis.addr -> redis.host)
+}
+
+// ID возвращает стабильный идентификатор расхождения для baseline: "missing db.max_idle_time"
+func (d Drift) ID() string {
+	return string(d.Kind) + " " + d.Key
+}
+
+// Message возвращает описание расхождения без ключа
+func (d Drift) Message() string {
+	switch d.Kind {
+	case DriftMissing:
+		return fmt.Sprintf("нет в %s (есть в %s)", strings.Join(d.Envs, ", "), strings.Join(d.Present, ", "))
+	case DriftType:
+		envs := make([]string, 0, len(d.Types))
+		for env := range d.Types {
+			envs = append(envs, env)
+		}
+		sort.Strings(envs)
+		parts := make([]string, len(envs))
+		for i, env := range envs {
+			parts[i] = env + "=" + d.Types[env]
+		}
+		return fmt.Sprintf("тип различается: %s", strings.Join(parts, ", "))
+	default:
+		msg := fmt.Sprintf("только в %s", strings.Join(d.Envs, ", "))
+		if d.Similar != "" {
+			msg += " (похожий ключ: " + d.Similar + ")"
+		}
+		return msg
+	}
+}
+
+// DetectDrift сравнивает деревья полей окружений (результат ParseEnvFiles) и находит:
+// ключи, которых нет в части окружений (warning: intersect выбросит их из схемы),
+// ключи с разными типами (error) и ключи только одного окружения (info)
+// Результат отсортирован по убыванию важности, затем по ключу
+func DetectDrift(envs []string, trees []map[string]*model.Field) []Drift {
+	if len(envs) < 2 {
+		return nil
+	}
+
+	types := make(map[string]map[string]string) // ключ -> окружение -> тип
+	for i, tree := range trees {
+		flat := make(map[string]string)
+		flattenTypes("", tree, flat)
+		for key, typ := range flat {
+			if types[key] == nil {
+				types[key] = make(map[string]string)
+			}
+			types[key][envs[i]] = typ
+		}
+	}
+
+	var out []Drift
+	for key, byEnv := range types {
+		var present, missing []string
+		for _, env := range envs {
+			if _, ok := byEnv[env]; ok {
+				present = append(present, env)
+			} else {
+				missing = append(missing, env)
+			}
+		}
+
+		switch {
+		case len(present) == 1:
+			d := Drift{Kind: DriftOnlyIn, Severity: diag.SeverityInfo, Key: key, Envs: present}
+			d.Similar = similarKey(key, present[0], types)
+			out = append(out, d)
+		case len(missing) > 0:
+			out = append(out, Drift{Kind: DriftMissing, Severity: diag.SeverityWarning, Key: key, Envs: missing, Present: present})
+		}
+
+		distinct := make(map[string]bool)
+		for _, typ := range byEnv {
+			distinct[typ] = true
+		}
+		if len(distinct) > 1 {
+			out = append(out, Drift{Kind: DriftType, Severity: diag.SeverityError, Key: key, Types: byEnv})
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Severity != out[j].Severity {
+			return out[i].Severity > out[j].Severity
+		}
+		if out[i].Key != out[j].Key {
+			return out[i].Key < out[j].Key
+		}
+		return out[i].Kind < out[j].Kind
+	})
+	return out
+}
+
+// flattenTypes раскладывает дерево полей в плоские ключи с Go типами
+func flattenTypes(prefix string, fields map[string]*model.Field, out map[string]string) {
+	for name, f := range fields {
+		if f.Kind == model.KindObject {
+			flattenTypes(prefix+name+".", f.Children, out)
+			continue
+		}
+		out[prefix+name] = fieldType(f)
+	}
+}
+
+// similarKey ищет ключ той же секции, которого нет в env, с ближайшим именем:
+// вероятно, то же значение названо в окружениях по-разному (redis.addr и redis.host)
+func similarKey(key, env string, types map[string]map[string]string) string {
+	section := key[:strings.LastIndex(key, ".")+1]
+	best, bestDist := "", -1
+	for other, byEnv := range types {
+		if other == key || !strings.HasPrefix(other, section) || strings.Contains(other[len(section):], ".") {
+			continue
+		}
+		if _, ok := byEnv[env]; ok {
+			continue
+		}
+		if d := levenshtein(key, other); bestDist < 0 || d < bestDist || d == bestDist && other < best {
+			best, bestDist = other, d
+		}
+	}
+	return best
+}
+
+// levenshtein расстояние редактирования между строками
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vovanwin/configgen/internal/model"
+)
+
+func TestDetectDrift(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"config_prod.toml": `
+[db]
+host = "db"
+max_idle_time = "5m"
+port = 5432
+
+[redis]
+host = "redis"
+`,
+		"config_stg.toml": `
+[db]
+host = "db"
+port = "5432"
+
+[redis]
+host = "redis"
+`,
+		"config_dev.toml": `
+[db]
+host = "localhost"
+max_idle_time = "1m"
+port = 5432
+
+[redis]
+addr = "localhost:6379"
+`,
+	})
+
+	files, err := DiscoverEnvFiles(tmpDir, model.DefaultLayers(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	trees, err := ParseEnvFiles(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envs := make([]string, len(files))
+	for i, f := range files {
+		envs[i] = f.Name()
+	}
+
+	drift := DetectDrift(envs, trees)
+	var got []string
+	for _, d := range drift {
+		got = append(got, d.Severity.String()+" "+d.Key+": "+d.Message())
+	}
+	want := []string{
+		"error db.port: тип различается: dev=int, prod=int, stg=string",
+		"warning db.max_idle_time: нет в stg (есть в dev, prod)",
+		"warning redis.host: нет в dev (есть в prod, stg)",
+		"info redis.addr: только в dev (похожий ключ: redis.host)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DetectDrift:\n%v\nожидалось:\n%v", got, want)
+	}
+	if drift[0].ID() != "type db.port" {
+		t.Errorf("ID = %q", drift[0].ID())
+	}
+
+	if d := DetectDrift(envs[:1], trees[:1]); d != nil {
+		t.Errorf("одно окружение не может расходиться: %v", d)
+	}
+}
@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vovanwin/configgen/internal/model"
+)
+
+// ExplainStep значение ключа в одном слое
+type ExplainStep struct {
+	Layer  model.Layer
+	Source string // Файл слоя; пусто для переменных окружения и флагов
+	Name   string // Переменная окружения (APP_DB__HOST или имена через запятую, если ни одна не задана)
+	Value  any    // Значение, заданное слоем
+	Set    bool   // Слой задаёт ключ
+	Skip   string // Причина, по которой слой не применялся
+}
+
+// Trace история значения ключа по слоям
+type Trace struct {
+	Key     string
+	Target  string        // Окружение (prod или prod.eu)
+	Type    string        // Go тип поля
+	Comment string        // Комментарий из TOML
+	Steps   []ExplainStep // В порядке применения
+	Winner  int           // Индекс шага, задавшего итоговое значение; -1, если ключ не задан
+	Raw     any           // Значение после мержа слоёв, до подстановок
+	Value   any           // Итоговое значение: подстановки раскрыты, тип приведён к типу поля
+}
+
+// Explain мержит слои для target (prod или prod.eu) так же, как Resolve, и возвращает
+// историю значения ключа
+func Explain(opts ResolveOptions, target, key string) (*Trace, error) {
+	f := model.Lookup(opts.Schema, key)
+	if f == nil {
+		return nil, fmt.Errorf("ключ %s не найден в схеме", key)
+	}
+	if f.Kind == model.KindObject {
+		return nil, fmt.Errorf("%s — секция, укажите ключ внутри неё", key)
+	}
+
+	m, err := mergeLayers(opts, target)
+	if err != nil {
+		return nil, err
+	}
+
+	tr := &Trace{Key: key, Target: target, Type: fieldType(f), Comment: f.Comment, Winner: -1}
+	for _, s := range m.steps {
+		step := ExplainStep{Layer: s.Layer, Source: s.Source, Skip: s.Skip}
+		step.Value, step.Set = s.Values[key]
+		switch {
+		case step.Set:
+			step.Name = s.Names[key]
+			tr.Winner = len(tr.Steps)
+		case s.Names != nil:
+			step.Name = strings.Join(append([]string{model.EnvVarName(opts.EnvVarPrefix, key)}, f.EnvVars...), ", ")
+		}
+		tr.Steps = append(tr.Steps, step)
+	}
+
+	raw, ok := m.values[key]
+	if !ok {
+		return tr, nil
+	}
+	tr.Raw = raw
+
+	v, err := m.interpolator().resolve(key)
+	if err != nil {
+		return nil, err
+	}
+	if tr.Value, err = typedValue(tr.Type, v); err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return tr, nil
+}
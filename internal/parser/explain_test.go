@@ -0,0 +1,184 @@
+package parser
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vovanwin/configgen/internal/model"
+)
+
+func explainFixture(t *testing.T) ResolveOptions {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"value.toml": `
+[app]
+name = "svc"
+`,
+		"config_prod.toml": `
+[server]
+# Порт сервера
+# env: PORT
+port = 80
+timeout = "5s"
+hosts = ["a", "b"]
+
+[db]
+host = "db.prod"
+dsn = "postgres://${db.host}/app"
+`,
+		"config_stg.toml": `
+extends = "prod"
+
+[server]
+port = 8080
+`,
+		".env": "APP_DB__HOST=db.dotenv\n",
+	})
+
+	layers := model.DefaultLayers(true)
+	files, err := DiscoverEnvFiles(dir, layers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trees, err := ParseEnvFiles(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := ParseFile(filepath.Join(dir, "value.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ResolveOptions{
+		Dir:            dir,
+		Layers:         layers,
+		Schema:         Union(value, Intersect(trees...)),
+		EnvVarPrefix:   "APP_",
+		EnableOverride: true,
+		LookupEnv:      func(string) (string, bool) { return "", false },
+	}
+}
+
+func TestExplainExtendsChain(t *testing.T) {
+	opts := explainFixture(t)
+
+	tr, err := Explain(opts, "stg", "server.port")
+	if err != nil {
+		t.Fatalf("Explain вернул ошибку: %v", err)
+	}
+	if tr.Type != "int" || tr.Comment != "Порт сервера" {
+		t.Errorf("тип/комментарий = %q/%q", tr.Type, tr.Comment)
+	}
+
+	var set []string
+	for _, s := range tr.Steps {
+		if s.Set {
+			set = append(set, filepath.Base(s.Source))
+		}
+	}
+	if !reflect.DeepEqual(set, []string{"config_prod.toml", "config_stg.toml"}) {
+		t.Errorf("ключ задают %v, ожидалось prod, затем stg", set)
+	}
+	if filepath.Base(tr.Steps[tr.Winner].Source) != "config_stg.toml" {
+		t.Errorf("победил шаг %+v, ожидался config_stg.toml", tr.Steps[tr.Winner])
+	}
+	if tr.Value != 8080 {
+		t.Errorf("Value = %#v, ожидалось 8080", tr.Value)
+	}
+
+	last := tr.Steps[len(tr.Steps)-1]
+	if last.Layer.Kind != model.LayerFlags || last.Skip == "" {
+		t.Errorf("слой флагов должен быть пропущен: %+v", last)
+	}
+}
+
+func TestExplainEnvLayers(t *testing.T) {
+	opts := explainFixture(t)
+	opts.LookupEnv = func(name string) (string, bool) {
+		if name == "PORT" {
+			return "9000", true
+		}
+		return "", false
+	}
+
+	tr, err := Explain(opts, "prod", "server.port")
+	if err != nil {
+		t.Fatalf("Explain вернул ошибку: %v", err)
+	}
+	win := tr.Steps[tr.Winner]
+	if win.Layer.Kind != model.LayerEnv || win.Name != "PORT" || tr.Value != 9000 {
+		t.Errorf("ожидалась переменная PORT=9000, получено %+v, Value=%#v", win, tr.Value)
+	}
+
+	// .env задаёт db.host, dsn ссылается на него
+	tr, err = Explain(opts, "prod", "db.dsn")
+	if err != nil {
+		t.Fatalf("Explain вернул ошибку: %v", err)
+	}
+	if tr.Raw != "postgres://${db.host}/app" || tr.Value != "postgres://db.dotenv/app" {
+		t.Errorf("Raw/Value = %#v/%#v", tr.Raw, tr.Value)
+	}
+
+	// Секция целиком JSON объектом применяется раньше переменной ключа
+	opts.LookupEnv = func(name string) (string, bool) {
+		if name == "APP_SERVER" {
+			return `{"timeout":"1m"}`, true
+		}
+		return "", false
+	}
+	tr, err = Explain(opts, "prod", "server.timeout")
+	if err != nil {
+		t.Fatalf("Explain вернул ошибку: %v", err)
+	}
+	if tr.Steps[tr.Winner].Name != "APP_SERVER" || tr.Value != time.Minute {
+		t.Errorf("ожидалась секция APP_SERVER, получено %+v, Value=%#v", tr.Steps[tr.Winner], tr.Value)
+	}
+}
+
+func TestExplainWithoutEnvOverride(t *testing.T) {
+	opts := explainFixture(t)
+	opts.EnvVarPrefix = ""
+
+	tr, err := Explain(opts, "prod", "db.host")
+	if err != nil {
+		t.Fatalf("Explain вернул ошибку: %v", err)
+	}
+	if tr.Value != "db.prod" {
+		t.Errorf("без переменных окружения Value = %#v, ожидалось db.prod", tr.Value)
+	}
+
+	tr, err = Explain(opts, "prod", "server.hosts")
+	if err != nil {
+		t.Fatalf("Explain вернул ошибку: %v", err)
+	}
+	if !reflect.DeepEqual(tr.Value, []any{"a", "b"}) {
+		t.Errorf("Value = %#v", tr.Value)
+	}
+}
+
+func TestExplainErrors(t *testing.T) {
+	opts := explainFixture(t)
+
+	tests := map[string]struct{ target, key, want string }{
+		"неизвестный ключ":   {"prod", "server.prot", "ключ server.prot не найден в схеме"},
+		"секция":             {"prod", "server", "секция"},
+		"нет окружения":      {"qa", "server.port", `конфиг для окружения "qa" не найден`},
+		"переменная с типом": {"prod", "server.port", "ожидался int"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := opts
+			if name == "переменная с типом" {
+				o.LookupEnv = func(name string) (string, bool) { return "eighty", name == "APP_SERVER__PORT" }
+			}
+			_, err := Explain(o, tt.target, tt.key)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ожидалась ошибка с %q, получено: %v", tt.want, err)
+			}
+		})
+	}
+}
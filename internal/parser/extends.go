@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vovanwin/configgen/internal/diag"
+	"github.com/vovanwin/configgen/internal/model"
+)
+
+// ParseEnvFiles парсит файлы окружений и разрешает цепочки extends
+// Результат в порядке files: дерево полей каждого окружения с учётом родителей,
+// пригодное для Intersect/Union. Региональный оверлей (Region != "") мержится
+// поверх разрешённого дерева своего окружения
+// Ошибки собираются по всем файлам и возвращаются как diag.List
+func ParseEnvFiles(files []EnvFile) ([]map[string]*model.Field, error) {
+	var errs diag.List
+	docs := make(map[string]*Document, len(files))
+	overlays := make(map[string]*Document)
+	for _, f := range files {
+		doc, err := ReadDocument(f.Path)
+		if err != nil {
+			errs.Add(err)
+			continue
+		}
+		if f.Region != "" {
+			if doc.Extends != "" {
+				errs.Add(doc.errorAt(diag.CodeExtends, "extends", fmt.Errorf("extends не поддерживается в региональных оверлеях")))
+				continue
+			}
+			overlays[f.Name()] = doc
+			continue
+		}
+		docs[f.Env] = doc
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	resolved := make(map[string]map[string]*model.Field, len(files))
+	failed := make(map[string]bool)
+	result := make([]map[string]*model.Field, 0, len(files))
+	for _, f := range files {
+		if failed[f.Env] {
+			continue
+		}
+		fields, err := resolveEnv(f.Env, docs, resolved)
+		if err != nil {
+			errs.Add(err)
+			failed[f.Env] = true
+			continue
+		}
+		if doc, ok := overlays[f.Name()]; ok {
+			own, err := doc.Fields()
+			if err != nil {
+				errs.Add(err)
+				continue
+			}
+			fields = Merge(fields, own)
+		}
+		// Ссылки могут указывать на ключи родителя или окружения под оверлеем
+		resolveWholeRefs(fields)
+		result = append(result, fields)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return result, nil
+}
+
+// ExtendsChain возвращает цепочку окружений от корневого родителя до env включительно
+func ExtendsChain(env string, docs map[string]*Document) ([]string, error) {
+	var chain []string
+	for cur := env; cur != ""; {
+		for _, seen := range chain {
+			if seen == cur {
+				err := fmt.Errorf("цикл extends: %s -> %s", strings.Join(chain, " -> "), cur)
+				return nil, docs[chain[len(chain)-1]].errorAt(diag.CodeExtends, "extends", err)
+			}
+		}
+		doc, ok := docs[cur]
+		if !ok {
+			if len(chain) == 0 {
+				return nil, fmt.Errorf("окружение %q не найдено", cur)
+			}
+			child := chain[len(chain)-1]
+			return nil, docs[child].errorAt(diag.CodeExtends, "extends", fmt.Errorf("extends = %q: окружение не найдено", cur))
+		}
+		chain = append(chain, cur)
+		cur = doc.Extends
+	}
+	return reverse(chain), nil
+}
+
+// resolveEnv строит дерево полей окружения: Merge(родитель, собственные поля)
+func resolveEnv(env string, docs map[string]*Document, resolved map[string]map[string]*model.Field) (map[string]*model.Field, error) {
+	if fields, ok := resolved[env]; ok {
+		return fields, nil
+	}
+
+	chain, err := ExtendsChain(env, docs)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]*model.Field
+	for _, name := range chain {
+		own, err := docs[name].Fields()
+		if err != nil {
+			return nil, err
+		}
+		fields = Merge(fields, own)
+	}
+	resolved[env] = fields
+	return fields, nil
+}
+
+func reverse(s []string) []string {
+	out := make([]string, len(s))
+	for i, v := range s {
+		out[len(s)-1-i] = v
+	}
+	return out
+}
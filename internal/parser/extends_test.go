@@ -0,0 +1,152 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vovanwin/configgen/internal/model"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("не удалось создать %s: %v", name, err)
+		}
+	}
+}
+
+func TestParseEnvFilesExtends(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"config_prod.toml": `
+[server]
+host = "0.0.0.0"
+port = 80
+
+[db]
+pool_size = 50
+`,
+		"config_stg.toml": `
+extends = "prod"
+
+[server]
+port = 8080
+`,
+		"config_dev.toml": `
+[configgen]
+extends = "stg"
+
+[db]
+pool_size = 5
+`,
+	})
+
+	files, err := DiscoverEnvFiles(tmpDir, model.DefaultLayers(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trees, err := ParseEnvFiles(files)
+	if err != nil {
+		t.Fatalf("ParseEnvFiles вернул ошибку: %v", err)
+	}
+
+	schema := Intersect(trees...)
+	server, ok := schema["server"]
+	if !ok || len(server.Children) != 2 {
+		t.Fatalf("server должен содержать host и port во всех окружениях: %+v", server)
+	}
+	if _, ok := schema["db"]; !ok {
+		t.Error("db должен быть унаследован от prod")
+	}
+	if _, ok := schema["extends"]; ok {
+		t.Error("директива extends не должна попадать в схему")
+	}
+	if _, ok := schema["configgen"]; ok {
+		t.Error("секция configgen не должна попадать в схему")
+	}
+}
+
+func TestParseEnvFilesExtendsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "cycle",
+			files: map[string]string{
+				"config_prod.toml": `extends = "stg"`,
+				"config_stg.toml":  `extends = "prod"`,
+			},
+			want: "цикл extends",
+		},
+		{
+			name: "unknown parent",
+			files: map[string]string{
+				"config_stg.toml": `extends = "qa"`,
+			},
+			want: `extends = "qa": окружение не найдено`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			writeFiles(t, tmpDir, tt.files)
+
+			files, err := DiscoverEnvFiles(tmpDir, model.DefaultLayers(false))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = ParseEnvFiles(files)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ожидалась ошибка %q, получено: %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestParseEnvFilesOverlays(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"config_prod.toml":    "[db]\nhost = \"db\"\n",
+		"config_prod.eu.toml": "[db]\nhost = \"db.eu\"\nreplica = \"db-ro.eu\"\n",
+		"config_stg.toml":     "[db]\nhost = \"db-stg\"\n",
+	})
+
+	layers := model.DefaultLayers(false)
+	envs, err := DiscoverEnvFiles(tmpDir, layers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(envs) != 2 {
+		t.Fatalf("оверлей не должен считаться окружением: %+v", envs)
+	}
+
+	overlays, err := DiscoverOverlays(tmpDir, layers, envs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(overlays) != 1 || overlays[0].Name() != "prod.eu" {
+		t.Fatalf("ожидался оверлей prod.eu, получено %+v", overlays)
+	}
+
+	trees, err := ParseEnvFiles(append(envs, overlays...))
+	if err != nil {
+		t.Fatalf("ParseEnvFiles вернул ошибку: %v", err)
+	}
+
+	schema := Union(trees...)
+	if _, ok := schema["db"].Children["replica"]; !ok {
+		t.Error("поле из оверлея должно попасть в union схему")
+	}
+	schema = Intersect(trees...)
+	if _, ok := schema["db"].Children["replica"]; ok {
+		t.Error("поле только из оверлея не должно попасть в intersect схему")
+	}
+}
@@ -1,11 +1,13 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"sort"
 
 	"github.com/BurntSushi/toml"
+	"github.com/vovanwin/configgen/internal/diag"
 	"github.com/vovanwin/configgen/internal/model"
 )
 
@@ -26,26 +28,43 @@ type flagsFile struct {
 func ParseFlagsFile(path string) ([]*model.FlagDef, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("чтение %s: %w", path, err)
+		return nil, &diag.Error{Code: diag.CodeRead, File: path, Err: fmt.Errorf("чтение: %w", err)}
 	}
 
 	var ff flagsFile
 	if _, err := toml.Decode(string(b), &ff); err != nil {
-		return nil, fmt.Errorf("декодирование %s: %w", path, err)
+		return nil, syntaxError(path, b, err)
 	}
 
 	if len(ff.Flags) == 0 {
 		return nil, nil
 	}
 
+	// Позиции секций [flags.name] для диагностик
+	_, _, lines, err := extractComments(path, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
 	var defs []*model.FlagDef
+	var errs diag.List
 	for name, entry := range ff.Flags {
 		def, err := flagEntryToDef(name, entry)
 		if err != nil {
-			return nil, fmt.Errorf("флаг %q: %w", name, err)
+			pos := lines["flags."+name]
+			errs = append(errs, diag.Diagnostic{
+				Code: diag.CodeFlag, Severity: diag.SeverityError,
+				File: path, Line: pos.Line, Column: pos.Column,
+				Key: "flags." + name, Message: err.Error(),
+			})
+			continue
 		}
 		defs = append(defs, def)
 	}
+	if len(errs) > 0 {
+		errs.Sort()
+		return nil, errs
+	}
 
 	sort.Slice(defs, func(i, j int) bool {
 		return defs[i].TOMLName < defs[j].TOMLName
@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseFileInclude(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "parts"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, tmpDir, map[string]string{
+		"config_prod.toml": `include = ["db.toml", "parts/*.toml"]
+
+[db]
+# Хост из основного файла
+host = "db-prod"
+`,
+		"db.toml": `# Настройки базы данных
+[db]
+# Хост базы данных
+host = "localhost"
+# Порт PostgreSQL
+port = 5432
+`,
+		"parts/kafka.toml": `[kafka]
+# Брокеры Kafka
+brokers = ["k1:9092"]
+`,
+	})
+
+	doc, err := ReadDocument(filepath.Join(tmpDir, "config_prod.toml"))
+	if err != nil {
+		t.Fatalf("ReadDocument вернул ошибку: %v", err)
+	}
+	if len(doc.Includes) != 2 {
+		t.Errorf("Includes = %v, ожидалось 2 файла", doc.Includes)
+	}
+	if host := doc.Values["db"].(map[string]any)["host"]; host != "db-prod" {
+		t.Errorf("db.host = %v, собственное значение файла должно переопределять include", host)
+	}
+
+	fields, err := doc.Fields()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fields["include"]; ok {
+		t.Error("директива include не должна попадать в схему")
+	}
+	db := fields["db"]
+	if db == nil || db.Children["port"] == nil || fields["kafka"] == nil {
+		t.Fatalf("поля из подключённых файлов должны быть в схеме: %+v", fields)
+	}
+	if db.Comment != "Настройки базы данных" {
+		t.Errorf("комментарий секции из include = %q", db.Comment)
+	}
+	if c := db.Children["port"].Comment; c != "Порт PostgreSQL" {
+		t.Errorf("комментарий поля из include = %q", c)
+	}
+	if c := db.Children["host"].Comment; c != "Хост из основного файла" {
+		t.Errorf("комментарий основного файла должен переопределять include, получено %q", c)
+	}
+}
+
+func TestParseFileIncludeCycle(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"a.toml": `include = "b.toml"`,
+		"b.toml": `include = "a.toml"`,
+	})
+
+	_, err := ParseFile(filepath.Join(tmpDir, "a.toml"))
+	if err == nil || !strings.Contains(err.Error(), "цикл include") {
+		t.Errorf("ожидалась ошибка цикла include, получено: %v", err)
+	}
+}
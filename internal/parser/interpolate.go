@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vovanwin/configgen/internal/model"
+)
+
+// reference одна подстановка ${...} в строковом значении
+type reference struct {
+	Name       string // Имя переменной окружения или путь ключа
+	Default    string // Значение после :-
+	HasDefault bool
+}
+
+// IsEnvReference возвращает true, если имя ссылки — переменная окружения (POD_IP),
+// а не путь ключа (db.host): переменные пишутся заглавными буквами, цифрами и _
+func IsEnvReference(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// scanReferences находит подстановки ${NAME}, ${NAME:-default} в строке
+// $${ — экранирование, подстановкой не считается
+// whole=true, если строка целиком состоит из одной подстановки
+func scanReferences(s string) (refs []reference, whole bool, err error) {
+	for i := 0; i < len(s); {
+		if strings.HasPrefix(s[i:], "$${") {
+			i += 3
+			continue
+		}
+		if !strings.HasPrefix(s[i:], "${") {
+			i++
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return nil, false, fmt.Errorf("незакрытая подстановка в %q", s)
+		}
+		expr := s[i+2 : i+end]
+		name, def, hasDef := strings.Cut(expr, ":-")
+		if name == "" {
+			return nil, false, fmt.Errorf("пустая подстановка в %q", s)
+		}
+		refs = append(refs, reference{Name: name, Default: def, HasDefault: hasDef})
+		whole = i == 0 && i+end+1 == len(s) && len(refs) == 1
+		i += end + 1
+	}
+	return refs, whole, nil
+}
+
+// keyRefs возвращает пути ключей (не переменные окружения), на которые ссылается значение
+func keyRefs(refs []reference) []string {
+	var out []string
+	for _, r := range refs {
+		if !IsEnvReference(r.Name) {
+			out = append(out, r.Name)
+		}
+	}
+	return out
+}
+
+// literalKind определяет тип значения по default подстановки: "${PORT:-8080}" -> int
+func literalKind(s string) model.Kind {
+	if _, err := strconv.Atoi(s); err == nil {
+		return model.KindInt
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return model.KindFloat
+	}
+	if s == "true" || s == "false" {
+		return model.KindBool
+	}
+	if _, err := time.ParseDuration(s); err == nil && containsDurationSuffix(s) {
+		return model.KindDuration
+	}
+	return model.KindString
+}
+
+// resolveWholeRefs уточняет тип строковых полей вида "${db.port}" по типу поля,
+// на которое они ссылаются (в пределах одного дерева)
+func resolveWholeRefs(root map[string]*model.Field) {
+	var walk func(fields map[string]*model.Field)
+	walk = func(fields map[string]*model.Field) {
+		for _, f := range fields {
+			if f.Kind == model.KindObject {
+				walk(f.Children)
+				continue
+			}
+			if f.Kind != model.KindString || f.WholeRef == "" {
+				continue
+			}
+			// Идём по цепочке "${a}" -> "${b}" -> значение, пока не встретим нестроковое поле
+			seen := make(map[string]bool)
+			for ref := f.WholeRef; ref != "" && !seen[ref]; {
+				seen[ref] = true
+				target := model.Lookup(root, ref)
+				if target == nil || target.Kind == model.KindObject {
+					break
+				}
+				if target.Kind != model.KindString {
+					f.Kind = target.Kind
+					f.ItemKind = target.ItemKind
+					break
+				}
+				ref = target.WholeRef
+			}
+		}
+	}
+	walk(root)
+}
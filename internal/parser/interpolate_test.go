@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vovanwin/configgen/internal/model"
+)
+
+func TestScanReferences(t *testing.T) {
+	refs, whole, err := scanReferences("postgres://${db.user}@${DB_HOST:-localhost}/$${literal}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if whole {
+		t.Error("строка с текстом не должна считаться целой подстановкой")
+	}
+	if len(refs) != 2 || refs[1].Name != "DB_HOST" || refs[1].Default != "localhost" {
+		t.Errorf("refs = %+v", refs)
+	}
+	if got := keyRefs(refs); !reflect.DeepEqual(got, []string{"db.user"}) {
+		t.Errorf("keyRefs = %v, ожидалось [db.user]", got)
+	}
+
+	if _, whole, _ := scanReferences("${db.port}"); !whole {
+		t.Error("\"${db.port}\" должна быть целой подстановкой")
+	}
+	if _, _, err := scanReferences("${db.port"); err == nil {
+		t.Error("ожидалась ошибка для незакрытой подстановки")
+	}
+}
+
+func TestParseFileInterpolationKinds(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"config.toml": `
+[server]
+host = "${POD_IP:-0.0.0.0}"
+port = "${PORT:-8080}"
+timeout = "${TIMEOUT:-5s}"
+admin_port = "${server.port}"
+
+[db]
+port = 5432
+dsn = "postgres://${db.user}@host:${db.port}"
+replica_port = "${db.port}"
+`,
+	})
+
+	fields, err := ParseFile(filepath.Join(tmpDir, "config.toml"))
+	if err != nil {
+		t.Fatalf("ParseFile вернул ошибку: %v", err)
+	}
+
+	server := fields["server"].Children
+	db := fields["db"].Children
+	tests := []struct {
+		name string
+		f    *model.Field
+		kind model.Kind
+	}{
+		{"server.host", server["host"], model.KindString},
+		{"server.port", server["port"], model.KindInt},
+		{"server.timeout", server["timeout"], model.KindDuration},
+		{"server.admin_port", server["admin_port"], model.KindInt},
+		{"db.dsn", db["dsn"], model.KindString},
+		{"db.replica_port", db["replica_port"], model.KindInt},
+	}
+	for _, tt := range tests {
+		if tt.f.Kind != tt.kind {
+			t.Errorf("%s: Kind = %v, ожидалось %v", tt.name, tt.f.Kind, tt.kind)
+		}
+	}
+
+	if !reflect.DeepEqual(db["dsn"].Refs, []string{"db.user", "db.port"}) {
+		t.Errorf("db.dsn Refs = %v", db["dsn"].Refs)
+	}
+	if len(server["host"].Refs) != 0 {
+		t.Errorf("переменные окружения не должны попадать в Refs: %v", server["host"].Refs)
+	}
+}
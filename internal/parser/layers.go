@@ -0,0 +1,176 @@
+package parser
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vovanwin/configgen/internal/model"
+)
+
+// ParseLayers разбирает описание слоёв из флага --layers
+// Формат: элементы через запятую, порядок = порядок мержа
+//
+//	path       — TOML файл относительно директории конфигов, {env} = окружение
+//	path?      — необязательный слой
+//	+path      — override-слой: только для текущего окружения
+//	*.env      — dotenv файл (всегда override)
+//	$env       — переменные окружения
+//	$flags     — флаги командной строки (BindFlags)
+func ParseLayers(spec string) ([]model.Layer, error) {
+	var layers []model.Layer
+	envFiles := 0
+
+	for _, raw := range strings.Split(spec, ",") {
+		item := strings.TrimSpace(raw)
+		if item == "" {
+			continue
+		}
+
+		switch item {
+		case "$env":
+			layers = append(layers, model.Layer{Kind: model.LayerEnv, Override: true})
+			continue
+		case "$flags":
+			layers = append(layers, model.Layer{Kind: model.LayerFlags, Override: true})
+			continue
+		}
+		if strings.HasPrefix(item, "$") {
+			return nil, fmt.Errorf("неизвестный слой %q (допустимы: $env, $flags)", item)
+		}
+
+		l := model.Layer{Kind: model.LayerFile, Required: true}
+		if strings.HasPrefix(item, "+") {
+			l.Override = true
+			item = item[1:]
+		}
+		if strings.HasSuffix(item, "?") {
+			l.Required = false
+			item = item[:len(item)-1]
+		}
+		if item == "" {
+			return nil, fmt.Errorf("пустой путь слоя в %q", raw)
+		}
+		l.Path = item
+
+		switch {
+		case strings.HasSuffix(item, ".env"):
+			l.Kind = model.LayerDotEnv
+			l.Override = true
+		case !strings.HasSuffix(item, ".toml"):
+			return nil, fmt.Errorf("слой %q: ожидался .toml или .env файл", item)
+		}
+
+		if l.IsEnvFile() {
+			envFiles++
+		}
+		layers = append(layers, l)
+	}
+
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("пустой список слоёв")
+	}
+	if envFiles == 0 {
+		return nil, fmt.Errorf("нужен хотя бы один слой окружения с %s (например, config_{env}.toml)", model.EnvPlaceholder)
+	}
+
+	return layers, nil
+}
+
+// EnvFile файл окружения, найденный по слою с {env}
+type EnvFile struct {
+	Env    string // Имя окружения (prod, stg, ...)
+	Region string // Регион оверлея (eu, us, ...); пусто для файла окружения
+	Path   string // Путь к файлу
+}
+
+// Name возвращает имя цели: prod или prod.eu для оверлея
+func (f EnvFile) Name() string {
+	if f.Region == "" {
+		return f.Env
+	}
+	return f.Env + "." + f.Region
+}
+
+// DiscoverEnvFiles находит файлы окружений так же, как сгенерированный loader:
+// по шаблону слоёв окружения (config_{env}.toml), исключая файлы, которые сами
+// являются слоями (config_local.toml, override.toml)
+func DiscoverEnvFiles(dir string, layers []model.Layer) ([]EnvFile, error) {
+	reserved := make(map[string]bool)
+	for _, l := range layers {
+		if l.Kind == model.LayerFile && !strings.Contains(l.Path, model.EnvPlaceholder) {
+			reserved[filepath.Join(dir, l.Path)] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	var files []EnvFile
+	for _, l := range layers {
+		if !l.IsEnvFile() {
+			continue
+		}
+		prefix, suffix, _ := strings.Cut(l.Path, model.EnvPlaceholder)
+		matches, err := filepath.Glob(filepath.Join(dir, prefix+"*"+suffix))
+		if err != nil {
+			return nil, fmt.Errorf("поиск конфигов: %w", err)
+		}
+		for _, match := range matches {
+			if reserved[match] {
+				continue
+			}
+			rel, err := filepath.Rel(dir, match)
+			if err != nil {
+				continue
+			}
+			env := strings.TrimSuffix(strings.TrimPrefix(filepath.ToSlash(rel), prefix), suffix)
+			if env == "" || strings.ContainsAny(env, "./") || seen[env] {
+				continue
+			}
+			seen[env] = true
+			files = append(files, EnvFile{Env: env, Path: match})
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Env < files[j].Env
+	})
+	return files, nil
+}
+
+// DiscoverOverlays находит региональные оверлеи (config_{env}.{region}.toml) для окружений
+func DiscoverOverlays(dir string, layers []model.Layer, envs []EnvFile) ([]EnvFile, error) {
+	var overlays []EnvFile
+	seen := make(map[string]bool)
+	for _, l := range layers {
+		if !l.IsOverlay() {
+			continue
+		}
+		for _, env := range envs {
+			pattern := strings.ReplaceAll(l.Path, model.EnvPlaceholder, env.Env)
+			prefix, suffix, _ := strings.Cut(pattern, model.RegionPlaceholder)
+			matches, err := filepath.Glob(filepath.Join(dir, prefix+"*"+suffix))
+			if err != nil {
+				return nil, fmt.Errorf("поиск оверлеев: %w", err)
+			}
+			for _, match := range matches {
+				rel, err := filepath.Rel(dir, match)
+				if err != nil {
+					continue
+				}
+				region := strings.TrimSuffix(strings.TrimPrefix(filepath.ToSlash(rel), prefix), suffix)
+				f := EnvFile{Env: env.Env, Region: region, Path: match}
+				if region == "" || strings.ContainsAny(region, "./") || seen[f.Name()] {
+					continue
+				}
+				seen[f.Name()] = true
+				overlays = append(overlays, f)
+			}
+		}
+	}
+
+	sort.Slice(overlays, func(i, j int) bool {
+		return overlays[i].Name() < overlays[j].Name()
+	})
+	return overlays, nil
+}
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vovanwin/configgen/internal/model"
+)
+
+func TestParseLayers(t *testing.T) {
+	layers, err := ParseLayers("value.toml?, config_{env}.toml, +override_{env}.toml?, +config_local.toml?, .env?, $env")
+	if err != nil {
+		t.Fatalf("ParseLayers вернул ошибку: %v", err)
+	}
+
+	expected := []model.Layer{
+		{Kind: model.LayerFile, Path: "value.toml"},
+		{Kind: model.LayerFile, Path: "config_{env}.toml", Required: true},
+		{Kind: model.LayerFile, Path: "override_{env}.toml", Override: true},
+		{Kind: model.LayerFile, Path: "config_local.toml", Override: true},
+		{Kind: model.LayerDotEnv, Path: ".env", Override: true},
+		{Kind: model.LayerEnv, Override: true},
+	}
+
+	if len(layers) != len(expected) {
+		t.Fatalf("len(layers) = %d, ожидалось %d", len(layers), len(expected))
+	}
+	for i := range expected {
+		if layers[i] != expected[i] {
+			t.Errorf("layers[%d] = %+v, ожидалось %+v", i, layers[i], expected[i])
+		}
+	}
+}
+
+func TestParseLayersErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"value.toml", // нет слоя окружения
+		"config_{env}.yaml",
+		"config_{env}.toml,$vault",
+	}
+
+	for _, spec := range tests {
+		if _, err := ParseLayers(spec); err == nil {
+			t.Errorf("ParseLayers(%q) должен вернуть ошибку", spec)
+		}
+	}
+}
+
+func TestDiscoverEnvFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"value.toml", "config_prod.toml", "config_stg.toml", "config_local.toml", "override_prod.toml"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := DiscoverEnvFiles(tmpDir, model.DefaultLayers(false))
+	if err != nil {
+		t.Fatalf("DiscoverEnvFiles вернул ошибку: %v", err)
+	}
+
+	if len(files) != 2 {
+		t.Fatalf("len(files) = %d, ожидалось 2 (config_local.toml — override-слой): %+v", len(files), files)
+	}
+	if files[0].Env != "prod" || files[1].Env != "stg" {
+		t.Errorf("окружения = %s, %s, ожидалось prod, stg", files[0].Env, files[1].Env)
+	}
+}
+
+func TestParseLayersFlags(t *testing.T) {
+	layers, err := ParseLayers("config_{env}.toml, $env, $flags")
+	if err != nil {
+		t.Fatalf("ParseLayers вернул ошибку: %v", err)
+	}
+	last := layers[len(layers)-1]
+	if last.Kind != model.LayerFlags || !last.Override || last.String() != "$flags" {
+		t.Errorf("последний слой = %+v, ожидался $flags", last)
+	}
+
+	defaults := model.DefaultLayers(false)
+	if defaults[len(defaults)-1].Kind != model.LayerFlags {
+		t.Error("флаги должны быть последним слоем по умолчанию")
+	}
+}
@@ -2,53 +2,322 @@ package parser
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
-	"os"
+	"io"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/vovanwin/configgen/internal/diag"
 	"github.com/vovanwin/configgen/internal/model"
 )
 
 // commentMap хранит комментарии для ключей (section.key -> comment)
 type commentMap map[string]string
 
+// keyDirectives директивы configgen из комментариев перед ключом
+type keyDirectives struct {
+	Env       []string // # env: DATABASE_URL, PG_URL
+	Sensitive bool     // # sensitive
+}
+
+// directiveMap хранит директивы ключей (section.key -> directives)
+type directiveMap map[string]*keyDirectives
+
+// positionMap хранит позиции ключей и секций (section.key -> файл и строка)
+type positionMap map[string]diag.Position
+
+// Document распарсенный TOML файл с отделёнными директивами configgen
+type Document struct {
+	Path     string         // Путь к файлу
+	Values   map[string]any // Значения без директив
+	Comments commentMap     // Комментарии ключей (section.key -> comment)
+	Keys     directiveMap   // Директивы ключей из комментариев (# env: ..., # sensitive)
+	Lines    positionMap    // Позиции ключей и секций, с учётом include
+	Extends  string         // Окружение-родитель из extends = "prod" или [configgen] extends
+	Includes []string       // Подключённые файлы (рекурсивно, в порядке мержа)
+}
+
 // ParseFile читает TOML файл и возвращает map[string]*model.Field с деревом полей
 func ParseFile(path string) (map[string]*model.Field, error) {
-	b, err := os.ReadFile(path)
+	doc, err := ReadDocument(path)
 	if err != nil {
-		return nil, fmt.Errorf("чтение файла %s: %w", path, err)
+		return nil, err
 	}
+	return doc.Fields()
+}
 
-	// Извлекаем комментарии из файла
-	comments, err := extractComments(path)
+// ReadDocument читает TOML файл, извлекает комментарии и директивы configgen
+// Файлы из include мержатся первыми, собственные значения файла переопределяют их
+func ReadDocument(path string) (*Document, error) {
+	return readDocument(path, nil)
+}
+
+// readDocument читает документ, stack — цепочка include для обнаружения циклов
+func readDocument(path string, stack []string) (*Document, error) {
+	root, comments, directives, lines, err := parseCached(path)
 	if err != nil {
-		return nil, fmt.Errorf("извлечение комментариев %s: %w", path, err)
+		return nil, err
 	}
 
-	var root map[string]any
-	if _, err := toml.Decode(string(b), &root); err != nil {
-		return nil, fmt.Errorf("декодирование toml %s: %w", path, err)
+	doc := &Document{Path: path, Values: root, Comments: comments, Keys: directives, Lines: lines}
+	includes, err := doc.extractDirectives()
+	if err != nil {
+		return nil, err
 	}
+	if len(includes) > 0 {
+		if err := doc.resolveIncludes(includes, append(stack, path)); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// resolveIncludes подключает файлы include: пути относительно файла, допускаются glob-шаблоны
+func (d *Document) resolveIncludes(patterns []string, stack []string) error {
+	values := make(map[string]any)
+	comments := make(commentMap)
+	directives := make(directiveMap)
+	lines := make(positionMap)
+
+	for _, pattern := range patterns {
+		full := pattern
+		if !filepath.IsAbs(full) {
+			full = filepath.Join(filepath.Dir(d.Path), pattern)
+		}
+
+		paths := []string{full}
+		if strings.ContainsAny(pattern, "*?[") {
+			matches, err := filepath.Glob(full)
+			if err != nil {
+				return d.errorAt(diag.CodeInclude, "include", fmt.Errorf("include %q: %w", pattern, err))
+			}
+			sort.Strings(matches)
+			paths = matches
+		}
 
-	return buildFieldsWithComments(root, comments, "")
+		for _, p := range paths {
+			for _, seen := range stack {
+				if filepath.Clean(seen) == filepath.Clean(p) {
+					return d.errorAt(diag.CodeInclude, "include", fmt.Errorf("цикл include: %s -> %s", strings.Join(stack, " -> "), p))
+				}
+			}
+			inc, err := readDocument(p, stack)
+			if err != nil {
+				return err
+			}
+			if inc.Extends != "" {
+				return inc.errorAt(diag.CodeInclude, "extends", fmt.Errorf("extends не поддерживается во включаемых файлах"))
+			}
+			mergeValues(values, inc.Values)
+			for k, c := range inc.Comments {
+				comments[k] = c
+			}
+			for k, dir := range inc.Keys {
+				directives[k] = dir
+			}
+			for k, pos := range inc.Lines {
+				lines[k] = pos
+			}
+			d.Includes = append(d.Includes, p)
+			d.Includes = append(d.Includes, inc.Includes...)
+		}
+	}
+
+	// Собственные значения и комментарии файла переопределяют подключённые
+	mergeValues(values, d.Values)
+	for k, c := range d.Comments {
+		comments[k] = c
+	}
+	for k, dir := range d.Keys {
+		directives[k] = dir
+	}
+	for k, pos := range d.Lines {
+		lines[k] = pos
+	}
+	d.Values = values
+	d.Comments = comments
+	d.Keys = directives
+	d.Lines = lines
+	return nil
+}
+
+// errorAt возвращает ошибку с позицией ключа документа; для extends и include
+// ищется и форма из секции [configgen]
+func (d *Document) errorAt(code, key string, err error) *diag.Error {
+	pos, ok := d.Lines[key]
+	if !ok {
+		pos, ok = d.Lines["configgen."+key]
+	}
+	if !ok {
+		pos = diag.Position{File: d.Path}
+	}
+	return &diag.Error{Code: code, File: pos.File, Line: pos.Line, Column: pos.Column, Key: key, Err: err}
+}
+
+// tomlErrorPrefixRe префикс ошибки toml.ParseError: toml: line 3 (last key "db.port"):
+var tomlErrorPrefixRe = regexp.MustCompile(`^toml: line \d+( \(last key "[^"]*"\))?: `)
+
+// syntaxError переводит ошибку декодирования TOML в диагностику с позицией
+func syntaxError(path string, src []byte, err error) *diag.Error {
+	var pe toml.ParseError
+	if !errors.As(err, &pe) {
+		return &diag.Error{Code: diag.CodeSyntax, File: path, Err: err}
+	}
+	// Позиция и ключ переносятся в диагностику, из текста остаётся только сообщение
+	msg := tomlErrorPrefixRe.ReplaceAllString(pe.Error(), "")
+	e := &diag.Error{Code: diag.CodeSyntax, File: path, Line: pe.Position.Line, Key: pe.LastKey, Err: errors.New(msg)}
+	// Строка и колонка считаются от одного смещения: строка ParseError после перевода
+	// строки уже указывает на следующую (ошибка в конце строки 2 давала 3:8)
+	if start := pe.Position.Start; start > 0 && start <= len(src) {
+		e.Line = bytes.Count(src[:start], []byte("\n")) + 1
+		e.Column = start - bytes.LastIndexByte(src[:start], '\n')
+	}
+	return e
+}
+
+// mergeValues рекурсивно мержит src в dst, значения src переопределяют dst
+func mergeValues(dst, src map[string]any) {
+	for k, v := range src {
+		if sm, ok := v.(map[string]any); ok {
+			if dm, ok := dst[k].(map[string]any); ok {
+				mergeValues(dm, sm)
+				continue
+			}
+			cp := make(map[string]any, len(sm))
+			mergeValues(cp, sm)
+			dst[k] = cp
+			continue
+		}
+		dst[k] = v
+	}
 }
 
-// extractComments парсит TOML файл и извлекает комментарии перед каждым ключом
-func extractComments(path string) (commentMap, error) {
-	file, err := os.Open(path)
+// Fields строит дерево полей документа
+func (d *Document) Fields() (map[string]*model.Field, error) {
+	fields, err := buildFieldsWithComments(d.Values, d.Comments, "")
 	if err != nil {
+		var de *diag.Error
+		if errors.As(err, &de) && de.File == "" {
+			return nil, d.errorAt(de.Code, de.Key, de.Err)
+		}
 		return nil, err
 	}
-	defer file.Close()
+	resolveWholeRefs(fields)
+	for key, dir := range d.Keys {
+		if f := model.Lookup(fields, key); f != nil {
+			f.EnvVars = dir.Env
+			f.Sensitive = dir.Sensitive
+		}
+	}
+	return fields, nil
+}
 
+// extractDirectives удаляет из значений директивы configgen, сохраняет extends в документе
+// и возвращает шаблоны include
+// Поддерживаются верхнеуровневые ключи extends, include и секция [configgen]
+func (d *Document) extractDirectives() ([]string, error) {
+	var includes []string
+
+	if v, ok := d.Values["extends"]; ok {
+		s, ok := v.(string)
+		if !ok {
+			return nil, d.errorAt(diag.CodeDirective, "extends", fmt.Errorf("extends должен быть строкой, получен %T", v))
+		}
+		d.Extends = s
+		delete(d.Values, "extends")
+	}
+
+	if v, ok := d.Values["include"]; ok {
+		list, err := stringList("include", v)
+		if err != nil {
+			return nil, d.errorAt(diag.CodeDirective, "include", err)
+		}
+		includes = append(includes, list...)
+		delete(d.Values, "include")
+	}
+
+	if v, ok := d.Values["configgen"]; ok {
+		section, ok := v.(map[string]any)
+		if !ok {
+			return nil, d.errorAt(diag.CodeDirective, "configgen", fmt.Errorf("configgen должен быть секцией, получен %T", v))
+		}
+		for key, val := range section {
+			switch key {
+			case "extends":
+				s, ok := val.(string)
+				if !ok {
+					return nil, d.errorAt(diag.CodeDirective, "configgen.extends", fmt.Errorf("configgen.extends должен быть строкой, получен %T", val))
+				}
+				if d.Extends != "" && d.Extends != s {
+					return nil, d.errorAt(diag.CodeDirective, "configgen.extends", fmt.Errorf("extends задан дважды: %q и %q", d.Extends, s))
+				}
+				d.Extends = s
+			case "include":
+				list, err := stringList("configgen.include", val)
+				if err != nil {
+					return nil, d.errorAt(diag.CodeDirective, "configgen.include", err)
+				}
+				includes = append(includes, list...)
+			default:
+				return nil, d.errorAt(diag.CodeDirective, "configgen."+key, fmt.Errorf("неизвестная директива configgen.%s", key))
+			}
+		}
+		delete(d.Values, "configgen")
+	}
+
+	return includes, nil
+}
+
+// stringList приводит значение директивы к списку строк (строка или массив строк)
+func stringList(name string, v any) ([]string, error) {
+	switch val := v.(type) {
+	case string:
+		return []string{val}, nil
+	case []any:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s должен содержать строки, получен %T", name, item)
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("%s должен быть строкой или массивом строк, получен %T", name, v)
+	}
+}
+
+// extractComments парсит TOML файл и извлекает комментарии перед каждым ключом и позиции ключей
+// Строки-директивы (# env: NAME, ..., # sensitive) в комментарий не попадают, а возвращаются отдельно
+func extractComments(path string, r io.Reader) (commentMap, directiveMap, positionMap, error) {
 	comments := make(commentMap)
-	scanner := bufio.NewScanner(file)
+	directives := make(directiveMap)
+	lines := make(positionMap)
+	scanner := bufio.NewScanner(r)
 
 	var currentSection string
 	var pendingComments []string
+	var pending *keyDirectives
+	lineNo := 0
+
+	// attach привязывает накопленные комментарии и директивы к ключу
+	attach := func(key string) {
+		if len(pendingComments) > 0 {
+			comments[key] = strings.Join(pendingComments, "\n")
+		}
+		if pending != nil {
+			directives[key] = pending
+		}
+		pendingComments = nil
+		pending = nil
+	}
 
 	// Регулярки для парсинга
 	sectionRe := regexp.MustCompile(`^\s*\[([^\]]+)\]\s*$`)
@@ -57,21 +326,38 @@ func extractComments(path string) (commentMap, error) {
 
 	for scanner.Scan() {
 		line := scanner.Text()
+		lineNo++
 
 		// Проверяем секцию [section]
-		if match := sectionRe.FindStringSubmatch(line); match != nil {
-			currentSection = match[1]
+		if match := sectionRe.FindStringSubmatchIndex(line); match != nil {
+			currentSection = line[match[2]:match[3]]
+			lines[currentSection] = diag.Position{File: path, Line: lineNo, Column: strings.Index(line, "[") + 1}
 			// Сохраняем комментарий для секции если есть
-			if len(pendingComments) > 0 {
-				comments[currentSection] = strings.Join(pendingComments, "\n")
-				pendingComments = nil
-			}
+			attach(currentSection)
 			continue
 		}
 
 		// Проверяем комментарий
 		if match := commentRe.FindStringSubmatch(line); match != nil {
 			comment := strings.TrimSpace(match[1])
+			if names, ok := strings.CutPrefix(comment, "env:"); ok {
+				env, err := parseEnvDirective(names)
+				if err != nil {
+					return nil, nil, nil, &diag.Error{Code: diag.CodeDirective, File: path, Line: lineNo, Err: err}
+				}
+				if pending == nil {
+					pending = &keyDirectives{}
+				}
+				pending.Env = append(pending.Env, env...)
+				continue
+			}
+			if comment == "sensitive" {
+				if pending == nil {
+					pending = &keyDirectives{}
+				}
+				pending.Sensitive = true
+				continue
+			}
 			if comment != "" {
 				pendingComments = append(pendingComments, comment)
 			}
@@ -79,26 +365,41 @@ func extractComments(path string) (commentMap, error) {
 		}
 
 		// Проверяем ключ
-		if match := keyRe.FindStringSubmatch(line); match != nil {
-			key := match[1]
+		if match := keyRe.FindStringSubmatchIndex(line); match != nil {
+			key := line[match[2]:match[3]]
 			fullKey := key
 			if currentSection != "" {
 				fullKey = currentSection + "." + key
 			}
-			if len(pendingComments) > 0 {
-				comments[fullKey] = strings.Join(pendingComments, "\n")
-				pendingComments = nil
-			}
+			lines[fullKey] = diag.Position{File: path, Line: lineNo, Column: match[2] + 1}
+			attach(fullKey)
 			continue
 		}
 
 		// Пустая строка сбрасывает накопленные комментарии
 		if strings.TrimSpace(line) == "" {
 			pendingComments = nil
+			pending = nil
 		}
 	}
 
-	return comments, scanner.Err()
+	return comments, directives, lines, scanner.Err()
+}
+
+// envNameRe допустимое имя переменной окружения
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseEnvDirective разбирает список имён из директивы # env: DATABASE_URL, PG_URL
+func parseEnvDirective(s string) ([]string, error) {
+	var names []string
+	for _, part := range strings.Split(s, ",") {
+		name := strings.TrimSpace(part)
+		if !envNameRe.MatchString(name) {
+			return nil, fmt.Errorf("env: неверное имя переменной окружения %q", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
 }
 
 // buildFieldsWithComments строит дерево полей из распарсенного TOML с комментариями
@@ -122,15 +423,60 @@ func buildFieldsWithComments(node map[string]any, comments commentMap, prefix st
 
 // detectFieldWithComment определяет тип поля и создает Field структуру с комментарием
 func detectFieldWithComment(key string, val any, comments commentMap, fullKey string) (*model.Field, error) {
+	f, err := detectField(key, val, comments, fullKey)
+	if err != nil {
+		return nil, err
+	}
+	if f.Kind != model.KindObject && len(f.Refs) == 0 && f.WholeRef == "" {
+		f.Default = defaultLiteral(val)
+	}
+	return f, nil
+}
+
+// defaultLiteral форматирует значение из TOML для справки: списки через запятую
+func defaultLiteral(val any) string {
+	switch v := val.(type) {
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ",")
+	case string:
+		if strings.Contains(v, "${") {
+			return ""
+		}
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// detectField определяет тип поля по значению
+func detectField(key string, val any, comments commentMap, fullKey string) (*model.Field, error) {
 	comment := comments[fullKey]
 
 	switch v := val.(type) {
 	case string:
-		// Проверяем похоже ли на duration
-		if _, err := time.ParseDuration(v); err == nil && containsDurationSuffix(v) {
-			return &model.Field{Name: ToGoName(key), TOMLName: key, Kind: model.KindDuration, Comment: comment}, nil
+		refs, whole, err := scanReferences(v)
+		if err != nil {
+			return nil, &diag.Error{Code: diag.CodeValue, Key: fullKey, Err: err}
 		}
-		return &model.Field{Name: ToGoName(key), TOMLName: key, Kind: model.KindString, Comment: comment}, nil
+		f := &model.Field{Name: ToGoName(key), TOMLName: key, Kind: model.KindString, Comment: comment, Refs: keyRefs(refs)}
+		switch {
+		case whole && refs[0].HasDefault:
+			// "${PORT:-8080}" — тип по значению по умолчанию
+			f.Kind = literalKind(refs[0].Default)
+		case whole && !IsEnvReference(refs[0].Name):
+			// "${db.port}" — тип уточняется по ключу в resolveWholeRefs
+			f.WholeRef = refs[0].Name
+		case len(refs) == 0:
+			// Проверяем похоже ли на duration
+			if _, err := time.ParseDuration(v); err == nil && containsDurationSuffix(v) {
+				f.Kind = model.KindDuration
+			}
+		}
+		return f, nil
 
 	case int, int64:
 		return &model.Field{Name: ToGoName(key), TOMLName: key, Kind: model.KindInt, Comment: comment}, nil
@@ -146,7 +492,17 @@ func detectFieldWithComment(key string, val any, comments commentMap, fullKey st
 			return &model.Field{Name: ToGoName(key), TOMLName: key, Kind: model.KindSlice, ItemKind: model.KindString, Comment: comment}, nil
 		}
 		itemKind := detectSimpleKind(v[0])
-		return &model.Field{Name: ToGoName(key), TOMLName: key, Kind: model.KindSlice, ItemKind: itemKind, Comment: comment}, nil
+		f := &model.Field{Name: ToGoName(key), TOMLName: key, Kind: model.KindSlice, ItemKind: itemKind, Comment: comment}
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				refs, _, err := scanReferences(s)
+				if err != nil {
+					return nil, &diag.Error{Code: diag.CodeValue, Key: fullKey, Err: err}
+				}
+				f.Refs = append(f.Refs, keyRefs(refs)...)
+			}
+		}
+		return f, nil
 
 	case map[string]any:
 		children := make(map[string]*model.Field)
@@ -161,7 +517,7 @@ func detectFieldWithComment(key string, val any, comments commentMap, fullKey st
 		return &model.Field{Name: ToGoName(key), TOMLName: key, Kind: model.KindObject, Children: children, Comment: comment}, nil
 
 	default:
-		return nil, fmt.Errorf("неподдерживаемый тип для ключа %s: %T", key, val)
+		return nil, &diag.Error{Code: diag.CodeValue, Key: fullKey, Err: fmt.Errorf("неподдерживаемый тип %T", val)}
 	}
 }
 
@@ -3,6 +3,8 @@ package parser
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/vovanwin/configgen/internal/model"
@@ -164,3 +166,100 @@ func TestParseFileInvalidTOML(t *testing.T) {
 		t.Error("ожидалась ошибка для невалидного TOML")
 	}
 }
+
+func TestParseFileEnvDirective(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"config.toml": `
+[db]
+# Строка подключения
+# env: DATABASE_URL, PG_URL
+dsn = "postgres://localhost"
+# Хост базы данных
+host = "localhost"
+`,
+		"bad.toml": `
+# env: DATABASE-URL
+dsn = ""
+`,
+	})
+
+	fields, err := ParseFile(filepath.Join(tmpDir, "config.toml"))
+	if err != nil {
+		t.Fatalf("ParseFile вернул ошибку: %v", err)
+	}
+	dsn := fields["db"].Children["dsn"]
+	if !reflect.DeepEqual(dsn.EnvVars, []string{"DATABASE_URL", "PG_URL"}) {
+		t.Errorf("EnvVars = %v", dsn.EnvVars)
+	}
+	if dsn.Comment != "Строка подключения" {
+		t.Errorf("директива не должна попадать в комментарий: %q", dsn.Comment)
+	}
+	if len(fields["db"].Children["host"].EnvVars) != 0 {
+		t.Error("директива не должна применяться к соседнему ключу")
+	}
+
+	if _, err := ParseFile(filepath.Join(tmpDir, "bad.toml")); err == nil || !strings.Contains(err.Error(), "DATABASE-URL") {
+		t.Errorf("ожидалась ошибка неверного имени, получено: %v", err)
+	}
+
+	// Директиву достаточно указать в одном окружении
+	other := map[string]*model.Field{
+		"db": {Name: "Db", TOMLName: "db", Kind: model.KindObject, Children: map[string]*model.Field{
+			"dsn": {Name: "Dsn", TOMLName: "dsn", Kind: model.KindString},
+		}},
+	}
+	merged := Intersect(other, fields)
+	if got := merged["db"].Children["dsn"].EnvVars; len(got) != 2 {
+		t.Errorf("Intersect потерял EnvVars: %v", got)
+	}
+}
+
+func TestParseFileSensitiveDirective(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"config.toml": `
+# Доступы к платёжному шлюзу
+# sensitive
+[payments]
+merchant = "m-1"
+
+[db]
+# Строка подключения с паролем
+# sensitive
+dsn = "postgres://u:p@localhost"
+host = "localhost"
+password = "secret"
+`,
+	})
+
+	fields, err := ParseFile(filepath.Join(tmpDir, "config.toml"))
+	if err != nil {
+		t.Fatalf("ParseFile вернул ошибку: %v", err)
+	}
+	dsn := fields["db"].Children["dsn"]
+	if !dsn.Sensitive || dsn.Comment != "Строка подключения с паролем" {
+		t.Errorf("dsn: Sensitive=%v, Comment=%q", dsn.Sensitive, dsn.Comment)
+	}
+
+	for key, want := range map[string]bool{
+		"db.dsn":            true,
+		"db.host":           false,
+		"db.password":       true, // по имени
+		"payments.merchant": true, // секция помечена целиком
+	} {
+		if got := model.IsSensitive(fields, key); got != want {
+			t.Errorf("IsSensitive(%q) = %v, ожидалось %v", key, got, want)
+		}
+	}
+
+	// Директиву достаточно указать в одном окружении
+	other := map[string]*model.Field{
+		"db": {Name: "Db", TOMLName: "db", Kind: model.KindObject, Children: map[string]*model.Field{
+			"dsn": {Name: "Dsn", TOMLName: "dsn", Kind: model.KindString},
+		}},
+	}
+	if !Intersect(other, fields)["db"].Children["dsn"].Sensitive {
+		t.Error("Intersect потерял Sensitive")
+	}
+}
@@ -0,0 +1,590 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vovanwin/configgen/internal/model"
+)
+
+// ResolveOptions параметры offline-мержа слоёв (configgen explain, configgen render)
+type ResolveOptions struct {
+	Dir            string                      // Директория конфигов
+	Layers         []model.Layer               // Порядок слоёв, как при генерации loader
+	Schema         map[string]*model.Field     // Схема конфига: типы, комментарии и директивы
+	EnvVarPrefix   string                      // Префикс переменных (APP_); пусто — слои .env и $env не применяются
+	EnableOverride bool                        // Применять override-файлы (LoadOptions.EnableOverride)
+	LookupEnv      func(string) (string, bool) // Переменные окружения; nil — os.LookupEnv
+}
+
+// layerStep один шаг мержа: файл, .env, переменные окружения или пропущенный слой
+type layerStep struct {
+	Layer  model.Layer
+	Source string            // Файл шага; пусто для переменных окружения и флагов
+	Skip   string            // Причина, по которой слой не применялся
+	Values map[string]any    // Плоские значения, заданные шагом (section.key -> value)
+	Names  map[string]string // Для переменных окружения: ключ -> имя переменной
+}
+
+// merged результат мержа слоёв для одного окружения
+type merged struct {
+	opts   ResolveOptions
+	steps  []layerStep
+	values map[string]any // Плоские значения после мержа, до подстановок
+}
+
+// Resolve мержит слои для target (prod или prod.eu) как для текущего окружения сгенерированного
+// loader и возвращает итоговые значения ключей схемы (section.key -> value): подстановки раскрыты,
+// строки приведены к типу поля, длительности остаются строками. Флаги командной строки offline неизвестны
+func Resolve(opts ResolveOptions, target string) (map[string]any, error) {
+	m, err := mergeLayers(opts, target)
+	if err != nil {
+		return nil, err
+	}
+
+	in := m.interpolator()
+	keys := make([]string, 0, len(m.values))
+	for key := range m.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	out := make(map[string]any)
+	var errs []error
+	for _, key := range keys {
+		f := model.Lookup(opts.Schema, key)
+		if f == nil || f.Kind == model.KindObject {
+			continue
+		}
+		v, err := in.resolve(key)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		out[key] = v
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return out, nil
+}
+
+// mergeLayers применяет слои по порядку, повторяя loadEnvironment сгенерированного loader
+func mergeLayers(opts ResolveOptions, target string) (*merged, error) {
+	env, region, _ := strings.Cut(target, ".")
+	if env == "" {
+		return nil, fmt.Errorf("не указано окружение")
+	}
+	if opts.LookupEnv == nil {
+		opts.LookupEnv = os.LookupEnv
+	}
+
+	m := &merged{opts: opts, values: make(map[string]any)}
+	applied := make(map[string]bool)
+
+	for _, l := range opts.Layers {
+		switch l.Kind {
+		case model.LayerFile:
+			if l.Override && !opts.EnableOverride {
+				m.skip(l, "", "override-файлы выключены")
+				continue
+			}
+			if l.IsOverlay() && region == "" {
+				m.skip(l, "", "регион не задан")
+				continue
+			}
+			path := layerPath(opts.Dir, l.Path, env, region)
+			// config_local.toml может совпасть с config_{env}.toml при env=local
+			if applied[path] {
+				m.skip(l, path, "файл уже применён")
+				continue
+			}
+			if !fileExists(path) {
+				if l.Required {
+					return nil, fmt.Errorf("конфиг для окружения %q не найден: %s", env, path)
+				}
+				m.skip(l, path, "файла нет")
+				continue
+			}
+
+			var docs []*Document
+			if l.IsEnvFile() {
+				chain, err := envDocChain(opts.Dir, l.Path, env)
+				if err != nil {
+					return nil, err
+				}
+				docs = chain
+			} else {
+				doc, err := ReadDocument(path)
+				if err != nil {
+					return nil, err
+				}
+				if doc.Extends != "" {
+					return nil, fmt.Errorf("%s: extends поддерживается только в файлах окружений", path)
+				}
+				docs = append(docs, doc)
+			}
+			for _, doc := range docs {
+				flat := make(map[string]any)
+				flattenValues("", doc.Values, flat)
+				m.apply(layerStep{Layer: l, Source: doc.Path, Values: flat})
+			}
+			applied[path] = true
+
+		case model.LayerDotEnv:
+			if opts.EnvVarPrefix == "" {
+				m.skip(l, "", "переменные окружения не включены")
+				continue
+			}
+			path := layerPath(opts.Dir, l.Path, env, region)
+			if !fileExists(path) {
+				if l.Required {
+					return nil, fmt.Errorf("файл %s не найден", path)
+				}
+				m.skip(l, path, "файла нет")
+				continue
+			}
+			vars, err := ReadDotEnv(path)
+			if err != nil {
+				return nil, err
+			}
+			step, err := envStep(opts, l, func(name string) (string, bool) {
+				v, ok := vars[name]
+				return v, ok
+			})
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			step.Source = path
+			m.apply(step)
+
+		case model.LayerEnv:
+			if opts.EnvVarPrefix == "" {
+				m.skip(l, "", "переменные окружения не включены")
+				continue
+			}
+			step, err := envStep(opts, l, opts.LookupEnv)
+			if err != nil {
+				return nil, fmt.Errorf("переменные окружения: %w", err)
+			}
+			m.apply(step)
+
+		case model.LayerFlags:
+			m.skip(l, "", "флаги командной строки offline неизвестны")
+		}
+	}
+	return m, nil
+}
+
+// apply мержит значения шага поверх текущих
+func (m *merged) apply(step layerStep) {
+	for k, v := range step.Values {
+		m.values[k] = v
+	}
+	m.steps = append(m.steps, step)
+}
+
+// skip запоминает пропущенный слой
+func (m *merged) skip(l model.Layer, source, reason string) {
+	m.steps = append(m.steps, layerStep{Layer: l, Source: source, Skip: reason})
+}
+
+// interpolator возвращает раскрыватель подстановок по значениям после мержа
+func (m *merged) interpolator() *runtimeInterpolator {
+	return &runtimeInterpolator{values: m.values, schema: m.opts.Schema, lookupEnv: m.opts.LookupEnv}
+}
+
+// envStep применяет переменные окружения в порядке привязок loader:
+// секция (JSON объект) раньше своих ключей, для ключа — первая заданная переменная
+func envStep(opts ResolveOptions, l model.Layer, lookup func(string) (string, bool)) (layerStep, error) {
+	step := layerStep{Layer: l, Values: make(map[string]any), Names: make(map[string]string)}
+	bindings, err := model.EnvBindings(opts.EnvVarPrefix, opts.Schema)
+	if err != nil {
+		return step, err
+	}
+
+	var errs []error
+	for _, b := range bindings {
+		name, raw, ok := lookupEnvBinding(b, lookup)
+		if !ok {
+			continue
+		}
+		vals, err := envBindingValues(opts.Schema, b, raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		for k, v := range vals {
+			step.Values[k] = v
+			step.Names[k] = name
+		}
+	}
+	return step, errors.Join(errs...)
+}
+
+// lookupEnvBinding возвращает первую заданную переменную привязки
+func lookupEnvBinding(b model.EnvBinding, lookup func(string) (string, bool)) (string, string, bool) {
+	if v, ok := lookup(b.Env); ok {
+		return b.Env, v, true
+	}
+	for _, name := range b.Aliases {
+		if v, ok := lookup(name); ok {
+			return name, v, true
+		}
+	}
+	return "", "", false
+}
+
+// envBindingValues разбирает значение переменной в ключи конфига так же, как loader
+func envBindingValues(schema map[string]*model.Field, b model.EnvBinding, raw string) (map[string]any, error) {
+	if !b.Section {
+		v, err := parseEnvValue(fieldType(model.Lookup(schema, b.Key)), raw)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{b.Key: v}, nil
+	}
+
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(raw), &obj); err != nil {
+		return nil, fmt.Errorf("ожидался JSON объект секции %s: %v", b.Key, err)
+	}
+	flat := make(map[string]any)
+	flattenValues(b.Key+".", obj, flat)
+
+	out := make(map[string]any, len(flat))
+	for key, v := range flat {
+		f := model.Lookup(schema, key)
+		if f == nil || f.Kind == model.KindObject {
+			return nil, fmt.Errorf("неизвестный ключ %s", key)
+		}
+		if s, ok := v.(string); ok {
+			parsed, err := parseEnvValue(fieldType(f), s)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			v = parsed
+		}
+		out[key] = v
+	}
+	return out, nil
+}
+
+// parseEnvValue разбирает строку из переменной окружения по Go типу поля
+// Списки: a,b,c или JSON массив ["a","b"]
+func parseEnvValue(typ, s string) (any, error) {
+	item, isSlice := strings.CutPrefix(typ, "[]")
+	if !isSlice || !strings.HasPrefix(strings.TrimSpace(s), "[") {
+		return convertValue(typ, s)
+	}
+
+	var items []any
+	if err := json.Unmarshal([]byte(s), &items); err != nil {
+		return nil, fmt.Errorf("ожидался JSON массив %s: %v", typ, err)
+	}
+	for i, it := range items {
+		str, ok := it.(string)
+		if !ok {
+			str = fmt.Sprint(it)
+		}
+		c, err := convertValue(item, str)
+		if err != nil {
+			return nil, fmt.Errorf("элемент %d: %w", i, err)
+		}
+		items[i] = c
+	}
+	return items, nil
+}
+
+// convertValue приводит строку к Go типу поля, как convertValue в loader
+// Длительности остаются строками
+func convertValue(typ string, v any) (any, error) {
+	s, ok := v.(string)
+	if !ok {
+		if typ == "string" {
+			return fmt.Sprint(v), nil
+		}
+		return v, nil
+	}
+
+	switch typ {
+	case "int":
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("ожидался int, получено %q", s)
+		}
+		return n, nil
+	case "float64":
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("ожидался float64, получено %q", s)
+		}
+		return f, nil
+	case "bool":
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("ожидался bool, получено %q", s)
+		}
+		return b, nil
+	case "time.Duration":
+		if _, err := time.ParseDuration(s); err != nil {
+			return nil, fmt.Errorf("ожидалась длительность, получено %q", s)
+		}
+		return s, nil
+	}
+
+	if item, ok := strings.CutPrefix(typ, "[]"); ok {
+		items := []any{}
+		if s == "" {
+			return items, nil
+		}
+		for _, part := range strings.Split(s, ",") {
+			c, err := convertValue(item, strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, c)
+		}
+		return items, nil
+	}
+	return s, nil
+}
+
+// typedValue приводит значение после мержа к Go типу поля, как декодер конфига:
+// int64 из TOML -> int, строки длительностей -> time.Duration
+func typedValue(typ string, v any) (any, error) {
+	if items, ok := v.([]any); ok {
+		item := strings.TrimPrefix(typ, "[]")
+		out := make([]any, len(items))
+		for i, it := range items {
+			c, err := typedValue(item, it)
+			if err != nil {
+				return nil, fmt.Errorf("элемент %d: %w", i, err)
+			}
+			out[i] = c
+		}
+		return out, nil
+	}
+
+	v, err := convertValue(typ, v)
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case "int":
+		if n, ok := v.(int64); ok {
+			return int(n), nil
+		}
+	case "float64":
+		if n, ok := v.(int64); ok {
+			return float64(n), nil
+		}
+	case "time.Duration":
+		if s, ok := v.(string); ok {
+			return time.ParseDuration(s)
+		}
+	}
+	return v, nil
+}
+
+// runtimeInterpolator раскрывает подстановки в плоских значениях так же, как interpolate в loader
+type runtimeInterpolator struct {
+	values    map[string]any
+	schema    map[string]*model.Field
+	lookupEnv func(string) (string, bool)
+	stack     []string
+}
+
+// resolve возвращает значение ключа с раскрытыми подстановками
+func (in *runtimeInterpolator) resolve(key string) (any, error) {
+	for i, s := range in.stack {
+		if s == key {
+			return nil, fmt.Errorf("цикл подстановок: %s -> %s", strings.Join(in.stack[i:], " -> "), key)
+		}
+	}
+	in.stack = append(in.stack, key)
+	defer func() { in.stack = in.stack[:len(in.stack)-1] }()
+
+	switch val := in.values[key].(type) {
+	case string:
+		if !strings.Contains(val, "${") {
+			return val, nil
+		}
+		expanded, err := in.expand(val)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		converted, err := convertValue(fieldType(model.Lookup(in.schema, key)), expanded)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q: %w", key, val, err)
+		}
+		return converted, nil
+
+	case []any:
+		items := make([]any, len(val))
+		for i, item := range val {
+			items[i] = item
+			s, ok := item.(string)
+			if !ok || !strings.Contains(s, "${") {
+				continue
+			}
+			expanded, err := in.expand(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s[%d]: %w", key, i, err)
+			}
+			items[i] = expanded
+		}
+		return items, nil
+	}
+	return in.values[key], nil
+}
+
+// expand раскрывает подстановки в строке
+func (in *runtimeInterpolator) expand(s string) (any, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if strings.HasPrefix(s[i:], "$${") {
+			b.WriteString("${")
+			i += 3
+			continue
+		}
+		if !strings.HasPrefix(s[i:], "${") {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("незакрытая подстановка")
+		}
+		name, def, hasDef := strings.Cut(s[i+2:i+end], ":-")
+		v, ok, err := in.lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		if str, isStr := v.(string); !ok || (hasDef && isStr && str == "") {
+			if !hasDef {
+				if IsEnvReference(name) {
+					return nil, fmt.Errorf("переменная окружения %s не задана", name)
+				}
+				return nil, fmt.Errorf("ключ %s не задан", name)
+			}
+			v = def
+		}
+		if i == 0 && end+1 == len(s) {
+			return v, nil
+		}
+		b.WriteString(fmt.Sprint(v))
+		i += end + 1
+	}
+	return b.String(), nil
+}
+
+// lookup возвращает значение переменной окружения или ключа конфига
+func (in *runtimeInterpolator) lookup(name string) (any, bool, error) {
+	if IsEnvReference(name) {
+		v, ok := in.lookupEnv(name)
+		return v, ok, nil
+	}
+	if _, ok := in.values[name]; !ok {
+		return nil, false, nil
+	}
+	v, err := in.resolve(name)
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+// envDocChain читает файл окружения и его родителей по extends, от корневого родителя
+func envDocChain(dir, pattern, env string) ([]*Document, error) {
+	var chain []*Document
+	var seen []string
+	for cur := env; cur != ""; {
+		for _, name := range seen {
+			if name == cur {
+				return nil, fmt.Errorf("цикл extends: %s -> %s", strings.Join(seen, " -> "), cur)
+			}
+		}
+		path := layerPath(dir, pattern, cur, "")
+		if !fileExists(path) {
+			return nil, fmt.Errorf("%s: extends = %q: файл %s не найден", chain[0].Path, cur, path)
+		}
+		doc, err := ReadDocument(path)
+		if err != nil {
+			return nil, err
+		}
+		seen = append(seen, cur)
+		chain = append([]*Document{doc}, chain...)
+		cur = doc.Extends
+	}
+	return chain, nil
+}
+
+// layerPath подставляет окружение и регион в путь слоя
+func layerPath(dir, pattern, env, region string) string {
+	path := strings.ReplaceAll(pattern, model.EnvPlaceholder, env)
+	path = strings.ReplaceAll(path, model.RegionPlaceholder, region)
+	return filepath.Join(dir, path)
+}
+
+// flattenValues раскладывает вложенные таблицы в плоские ключи section.key
+func flattenValues(prefix string, values map[string]any, out map[string]any) {
+	for k, v := range values {
+		if m, ok := v.(map[string]any); ok {
+			flattenValues(prefix+k+".", m, out)
+			continue
+		}
+		out[prefix+k] = v
+	}
+}
+
+// ReadDotEnv читает .env файл: KEY=VALUE, комментарии #, необязательный export и кавычки
+func ReadDotEnv(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("чтение файла %s: %w", path, err)
+	}
+
+	vars := make(map[string]string)
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: ожидалось KEY=VALUE", path, i+1)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		vars[strings.TrimSpace(name)] = value
+	}
+	return vars, nil
+}
+
+// fieldType возвращает Go тип листового поля: string, int, []string, time.Duration...
+func fieldType(f *model.Field) string {
+	if f == nil {
+		return ""
+	}
+	if f.Kind == model.KindSlice {
+		return "[]" + f.ItemKind.String()
+	}
+	return f.Kind.String()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,53 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	opts := explainFixture(t)
+	opts.LookupEnv = func(name string) (string, bool) {
+		if name == "APP_SERVER__HOSTS" {
+			return "x, y", true
+		}
+		return "", false
+	}
+
+	values, err := Resolve(opts, "stg")
+	if err != nil {
+		t.Fatalf("Resolve вернул ошибку: %v", err)
+	}
+	want := map[string]any{
+		"app.name":       "svc",
+		"server.port":    int64(8080),
+		"server.timeout": "5s",
+		"server.hosts":   []any{"x", "y"},
+		"db.host":        "db.dotenv",
+		"db.dsn":         "postgres://db.dotenv/app",
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("Resolve = %#v\nожидалось %#v", values, want)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	opts := explainFixture(t)
+	writeFiles(t, opts.Dir, map[string]string{
+		"override.toml": `
+[db]
+dsn = "${db.dsn}/x"
+`,
+	})
+
+	_, err := Resolve(opts, "prod")
+	if err == nil || !strings.Contains(err.Error(), "цикл подстановок: db.dsn -> db.dsn") {
+		t.Errorf("ожидалась ошибка цикла, получено: %v", err)
+	}
+
+	opts.EnableOverride = false
+	if _, err := Resolve(opts, "prod"); err != nil {
+		t.Errorf("без override-файлов цикла нет: %v", err)
+	}
+}
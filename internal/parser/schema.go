@@ -38,12 +38,12 @@ func intersectTwo(a, b map[string]*model.Field) map[string]*model.Field {
 			if len(children) == 0 {
 				continue
 			}
-			out[k] = &model.Field{
+			out[k] = withDirectives(withDirectives(&model.Field{
 				Name:     fa.Name,
 				TOMLName: fa.TOMLName,
 				Kind:     model.KindObject,
 				Children: children,
-			}
+			}, fa), fb)
 			continue
 		}
 
@@ -52,15 +52,21 @@ func intersectTwo(a, b map[string]*model.Field) map[string]*model.Field {
 				continue
 			}
 			out[k] = &model.Field{
-				Name:     fa.Name,
-				TOMLName: fa.TOMLName,
-				Kind:     model.KindSlice,
-				ItemKind: fa.ItemKind,
+				Name:      fa.Name,
+				TOMLName:  fa.TOMLName,
+				Kind:      model.KindSlice,
+				ItemKind:  fa.ItemKind,
+				Comment:   fa.Comment,
+				Refs:      fa.Refs,
+				EnvVars:   fa.EnvVars,
+				Default:   fa.Default,
+				Sensitive: fa.Sensitive,
 			}
+			out[k] = withDefault(withDirectives(out[k], fb), fb)
 			continue
 		}
 
-		out[k] = fa
+		out[k] = withDefault(withDirectives(fa, fb), fb)
 	}
 	return out
 }
@@ -75,14 +81,19 @@ func Merge(maps ...map[string]*model.Field) map[string]*model.Field {
 	for _, m := range maps {
 		for k, f := range m {
 			if existing, ok := result[k]; ok && existing.Kind == model.KindObject && f.Kind == model.KindObject {
-				result[k] = &model.Field{
+				comment := f.Comment
+				if comment == "" {
+					comment = existing.Comment
+				}
+				result[k] = withDirectives(withDirectives(&model.Field{
 					Name:     f.Name,
 					TOMLName: f.TOMLName,
 					Kind:     model.KindObject,
 					Children: Merge(existing.Children, f.Children),
-				}
+					Comment:  comment,
+				}, f), existing)
 			} else {
-				result[k] = f
+				result[k] = withDirectives(f, existing)
 			}
 		}
 	}
@@ -100,12 +111,14 @@ func Union(maps ...map[string]*model.Field) map[string]*model.Field {
 		for k, f := range m {
 			if existing, ok := result[k]; ok {
 				if existing.Kind == model.KindObject && f.Kind == model.KindObject {
-					result[k] = &model.Field{
+					result[k] = withDirectives(withDirectives(&model.Field{
 						Name:     f.Name,
 						TOMLName: f.TOMLName,
 						Kind:     model.KindObject,
 						Children: Union(existing.Children, f.Children),
-					}
+					}, existing), f)
+				} else {
+					result[k] = withDefault(withDirectives(existing, f), f)
 				}
 			} else {
 				result[k] = f
@@ -114,3 +127,35 @@ func Union(maps ...map[string]*model.Field) map[string]*model.Field {
 	}
 	return result
 }
+
+// withDirectives возвращает f с директивами из other, которых нет у f:
+// # env: и # sensitive достаточно указать в одном из файлов
+func withDirectives(f, other *model.Field) *model.Field {
+	if f == nil || other == nil || f.Kind != other.Kind {
+		return f
+	}
+	envVars := len(f.EnvVars) == 0 && len(other.EnvVars) > 0
+	sensitive := !f.Sensitive && other.Sensitive
+	if !envVars && !sensitive {
+		return f
+	}
+	cp := *f
+	if envVars {
+		cp.EnvVars = other.EnvVars
+	}
+	if sensitive {
+		cp.Sensitive = true
+	}
+	return &cp
+}
+
+// withDefault сбрасывает значение для справки, если в other оно другое:
+// поле, различающееся по окружениям, не имеет общего значения по умолчанию
+func withDefault(f, other *model.Field) *model.Field {
+	if f == nil || other == nil || f.Default == other.Default {
+		return f
+	}
+	cp := *f
+	cp.Default = ""
+	return &cp
+}
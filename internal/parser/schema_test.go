@@ -201,3 +201,25 @@ func TestMerge(t *testing.T) {
 		t.Error("поле 'port' должно быть в результате")
 	}
 }
+
+func TestIntersectDefault(t *testing.T) {
+	a := map[string]*model.Field{
+		"port": {Name: "Port", TOMLName: "port", Kind: model.KindInt, Default: "8080"},
+		"host": {Name: "Host", TOMLName: "host", Kind: model.KindString, Default: "localhost"},
+	}
+	b := map[string]*model.Field{
+		"port": {Name: "Port", TOMLName: "port", Kind: model.KindInt, Default: "80"},
+		"host": {Name: "Host", TOMLName: "host", Kind: model.KindString, Default: "localhost"},
+	}
+
+	result := Intersect(a, b)
+	if result["port"].Default != "" {
+		t.Errorf("port.Default = %q, значение различается по окружениям и должно сброситься", result["port"].Default)
+	}
+	if result["host"].Default != "localhost" {
+		t.Errorf("host.Default = %q, ожидалось localhost", result["host"].Default)
+	}
+	if a["port"].Default != "8080" {
+		t.Error("Intersect не должен изменять исходные поля")
+	}
+}
@@ -0,0 +1,200 @@
+// Package textdiff строит построчный unified diff для --check и --diff
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// context число строк контекста вокруг изменений
+const context = 3
+
+// maxEdits предел длины редакционного предписания; при большем числе правок
+// файл показывается как полностью заменённый, чтобы не тратить O(D²) памяти
+const maxEdits = 4000
+
+// op тип строки в diff
+type op byte
+
+const (
+	opEqual  op = ' '
+	opDelete op = '-'
+	opInsert op = '+'
+)
+
+// edit строка результата сравнения
+type edit struct {
+	op   op
+	line string
+}
+
+// Unified возвращает unified diff между old и new или пустую строку, если они совпадают
+func Unified(oldName, newName string, old, new []byte) string {
+	if string(old) == string(new) {
+		return ""
+	}
+	a, b := splitLines(string(old)), splitLines(string(new))
+	edits := diffLines(a, b)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks(edits) {
+		writeHunk(&sb, edits, h)
+	}
+	return sb.String()
+}
+
+// splitLines делит текст на строки с сохранением \n; последняя строка без \n помечается
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	} else {
+		lines[len(lines)-1] += "\n\\ No newline at end of file\n"
+	}
+	return lines
+}
+
+// diffLines находит кратчайшее редакционное предписание алгоритмом Майерса
+func diffLines(a, b []string) []edit {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxEdits)
+	off := limit + 1
+	v := make([]int, 2*off+1)
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		// Сохраняем только диагонали -d..d: память O(D²), а не O(D·(N+M))
+		trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[off+k-1] < v[off+k+1] {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, d, k)
+			}
+		}
+	}
+	return replaceAll(a, b)
+}
+
+// backtrack восстанавливает правки по сохранённым диагоналям
+func backtrack(a, b []string, trace [][]int, d, k int) []edit {
+	x, y := len(a), len(b)
+	var rev []edit
+	for ; d > 0; d-- {
+		prev := trace[d] // Диагонали -d..d перед шагом d, индекс k+d
+		var pk int
+		if k == -d || k != d && prev[k-1+d] < prev[k+1+d] {
+			pk = k + 1
+		} else {
+			pk = k - 1
+		}
+		px := prev[pk+d]
+		py := px - pk
+		for x > px && y > py {
+			x--
+			y--
+			rev = append(rev, edit{opEqual, a[x]})
+		}
+		if pk == k+1 {
+			y--
+			rev = append(rev, edit{opInsert, b[y]})
+		} else {
+			x--
+			rev = append(rev, edit{opDelete, a[x]})
+		}
+		k = pk
+	}
+	for x > 0 {
+		x--
+		rev = append(rev, edit{opEqual, a[x]})
+	}
+
+	edits := make([]edit, len(rev))
+	for i, e := range rev {
+		edits[len(rev)-1-i] = e
+	}
+	return edits
+}
+
+// replaceAll правки «удалить всё, вставить всё» для слишком больших различий
+func replaceAll(a, b []string) []edit {
+	edits := make([]edit, 0, len(a)+len(b))
+	for _, l := range a {
+		edits = append(edits, edit{opDelete, l})
+	}
+	for _, l := range b {
+		edits = append(edits, edit{opInsert, l})
+	}
+	return edits
+}
+
+// hunk диапазон правок [start, end) одного блока @@
+type hunk struct {
+	start, end int
+}
+
+// hunks группирует изменения с context строками вокруг, близкие блоки объединяются
+func hunks(edits []edit) []hunk {
+	var out []hunk
+	for i, e := range edits {
+		if e.op == opEqual {
+			continue
+		}
+		start := max(i-context, 0)
+		end := min(i+1+context, len(edits))
+		if len(out) > 0 && start <= out[len(out)-1].end {
+			out[len(out)-1].end = end
+			continue
+		}
+		out = append(out, hunk{start, end})
+	}
+	return out
+}
+
+// writeHunk печатает блок с заголовком @@ -l,s +l,s @@
+func writeHunk(sb *strings.Builder, edits []edit, h hunk) {
+	oldLine, newLine := 1, 1
+	for _, e := range edits[:h.start] {
+		if e.op != opInsert {
+			oldLine++
+		}
+		if e.op != opDelete {
+			newLine++
+		}
+	}
+	oldCount, newCount := 0, 0
+	for _, e := range edits[h.start:h.end] {
+		if e.op != opInsert {
+			oldCount++
+		}
+		if e.op != opDelete {
+			newCount++
+		}
+	}
+	// Пустой диапазон в unified diff указывает на строку перед ним
+	if oldCount == 0 {
+		oldLine--
+	}
+	if newCount == 0 {
+		newLine--
+	}
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", oldLine, oldCount, newLine, newCount)
+	for _, e := range edits[h.start:h.end] {
+		sb.WriteByte(byte(e.op))
+		sb.WriteString(e.line)
+	}
+}
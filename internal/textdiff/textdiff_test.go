@@ -0,0 +1,81 @@
+package textdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	new := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	got := Unified("old.go", "new.go", []byte(old), []byte(new))
+	want := `--- old.go
++++ new.go
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -8,3 +8,4 @@
+ h
+ i
+ j
++k
+`
+	if got != want {
+		t.Errorf("Unified:\n%s\nожидалось:\n%s", got, want)
+	}
+}
+
+func TestUnifiedEqual(t *testing.T) {
+	if got := Unified("a", "b", []byte("x\n"), []byte("x\n")); got != "" {
+		t.Errorf("одинаковые файлы дали diff:\n%s", got)
+	}
+}
+
+func TestUnifiedNewFile(t *testing.T) {
+	got := Unified("/dev/null", "new.go", nil, []byte("x\ny"))
+	want := "--- /dev/null\n+++ new.go\n@@ -0,0 +1,2 @@\n+x\n+y\n\\ No newline at end of file\n"
+	if got != want {
+		t.Errorf("новый файл:\n%q\nожидалось:\n%q", got, want)
+	}
+}
+
+// Правки должны переводить a в b
+func TestDiffLinesRoundTrip(t *testing.T) {
+	cases := [][2]string{
+		{"a b c a b b a", "c b a b a c"},
+		{"", "x y"},
+		{"x y", ""},
+		{"p q r s", "p q r s t"},
+		{"1 2 3 4 5 6", "6 5 4 3 2 1"},
+	}
+	for _, c := range cases {
+		a, b := strings.Fields(c[0]), strings.Fields(c[1])
+		var gotA, gotB []string
+		for _, e := range diffLines(a, b) {
+			if e.op != opInsert {
+				gotA = append(gotA, e.line)
+			}
+			if e.op != opDelete {
+				gotB = append(gotB, e.line)
+			}
+		}
+		if strings.Join(gotA, " ") != c[0] || strings.Join(gotB, " ") != c[1] {
+			t.Errorf("%q -> %q: правки дают %q -> %q", c[0], c[1], gotA, gotB)
+		}
+	}
+	// Пример Майерса: кратчайшее предписание из 5 правок
+	edits := diffLines(strings.Fields("a b c a b b a"), strings.Fields("c b a b a c"))
+	changes := 0
+	for _, e := range edits {
+		if e.op != opEqual {
+			changes++
+		}
+	}
+	if changes != 5 {
+		t.Errorf("ожидалось 5 правок, получено %d", changes)
+	}
+}